@@ -8,8 +8,6 @@ import (
 	"github.com/sweemingdow/gmicro_pkg/gateway/pkg/gserver"
 	"github.com/sweemingdow/gmicro_pkg/pkg/app"
 	"github.com/sweemingdow/gmicro_pkg/pkg/boot"
-	"github.com/sweemingdow/gmicro_pkg/pkg/regdis/disnacos"
-	"github.com/sweemingdow/gmicro_pkg/pkg/regdis/extra/enacos"
 	"github.com/sweemingdow/gmicro_pkg/pkg/routebinder"
 )
 
@@ -24,6 +22,8 @@ func main() {
 
 	booter.AddComponentStageOption(boot.WithNacosRegistry())
 
+	booter.AddComponentStageOption(boot.WithNacosDiscovery())
+
 	booter.AddComponentStageOption(boot.WithRpcClientFactory(nil))
 
 	booter.StartAndServe(func(ac *boot.AppContext) routebinder.AppRouterBinder {
@@ -40,7 +40,6 @@ func main() {
 		tables := gserver.Cfg2routerItems(tableCfg)
 
 		ta := app.GetTheApp()
-		cfg := ta.GetConfig()
 
 		gserver.NewConfigurableGatewayServer(
 			tables,
@@ -52,11 +51,13 @@ func main() {
 				WriteTimeoutMills:          45_000,
 			},
 			ac.GetEc(),
-			disnacos.NewNacosDiscovery(ac.GetNacosClient().GetNamingClient()),
-			enacos.PkgDiscoveryExtraParam(cfg.NacosCenterCfg.RegistryDiscoverCfg.ClusterName, cfg.NacosCenterCfg.RegistryDiscoverCfg.GroupName),
+			ac.GetDiscovery(),
+			ac.GetDiscoveryExtra(),
 			[]fiber.Handler{gmiddleware.AuthWithRpc(cauth.NewAuthRpcProvider(ac.GetArpcClientFactory()))},
 			[]fiber.Handler{gmiddleware.RespAttach()},
 			gmiddleware.RespInterceptWhenError(),
+			boot.LcKeyNacosClient,
+			boot.LcKeyRpcClientFactory,
 		)
 
 		return nil
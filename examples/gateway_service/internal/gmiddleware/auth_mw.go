@@ -38,6 +38,9 @@ func AuthWithRpc(provider cauth.AuthRpcProvider) fiber.Handler {
 			return err
 		}
 
+		// 供按用户限流
+		gserver.SetAuthUser(c, val.Uid)
+
 		// 写入鉴权信息到upstream server
 		c.Request().Header.Add("Info-From-Gateway", string(values))
 
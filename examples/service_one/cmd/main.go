@@ -8,6 +8,7 @@ import (
 	"github.com/sweemingdow/gmicro_pkg/pkg/mylog"
 	"github.com/sweemingdow/gmicro_pkg/pkg/routebinder"
 	"github.com/sweemingdow/gmicro_pkg/pkg/server/srpc/rclient"
+	"time"
 )
 
 func main() {
@@ -19,7 +20,10 @@ func main() {
 
 	booter.AddComponentStageOption(boot.WithNacosRegistry())
 
-	booter.AddComponentStageOption(boot.WithRpcClientFactory(rclient.NewRoundRobinLoadBalancer()))
+	booter.AddComponentStageOption(boot.WithRpcClientFactory(
+		rclient.NewRoundRobinLoadBalancer(),
+		rclient.WithInterceptors(rclient.StampRequestId(), rclient.CallLog(500*time.Millisecond)),
+	))
 
 	booter.AddServerOption(boot.WithHttpServer(func(c *fiber.Ctx, err error) error {
 		lg := mylog.AppLogger()
@@ -5,7 +5,10 @@ import (
 	"github.com/sweemingdow/gmicro_pkg/examples/service_two/internal/handlers/hrpc"
 	"github.com/sweemingdow/gmicro_pkg/examples/service_two/internal/routers"
 	"github.com/sweemingdow/gmicro_pkg/pkg/boot"
+	"github.com/sweemingdow/gmicro_pkg/pkg/health"
 	"github.com/sweemingdow/gmicro_pkg/pkg/routebinder"
+	"github.com/sweemingdow/gmicro_pkg/pkg/server/srpc"
+	"time"
 )
 
 func main() {
@@ -21,7 +24,11 @@ func main() {
 	booter.AddServerOption(boot.WithHttpServer(nil))
 
 	// 启动rpc服务
-	booter.AddServerOption(boot.WithRpcServer())
+	booter.AddServerOption(boot.WithRpcServer(
+		srpc.Recovery(),
+		srpc.AccessLog(health.LivePath, health.ReadyPath),
+		srpc.Timing(200*time.Millisecond),
+	))
 
 	booter.StartAndServe(func(ac *boot.AppContext) routebinder.AppRouterBinder {
 		return routers.NewTwoServiceRouteBinder(hrpc.NewTestHandler(), hhttp.NewApiTestHandler())
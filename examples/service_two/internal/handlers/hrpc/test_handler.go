@@ -1,12 +1,12 @@
 package hrpc
 
 import (
+	"context"
 	"fmt"
 	"github.com/lesismal/arpc"
 	"github.com/sweemingdow/gmicro_pkg/external/call/crpc/cauth"
 	"github.com/sweemingdow/gmicro_pkg/pkg/app"
 	"github.com/sweemingdow/gmicro_pkg/pkg/mylog"
-	"github.com/sweemingdow/gmicro_pkg/pkg/server/srpc"
 	"github.com/sweemingdow/gmicro_pkg/pkg/server/srpc/rpccall"
 )
 
@@ -43,17 +43,8 @@ func (th *TestHandler) HandleTest(c *arpc.Context) {
 	}
 }
 
-func (th *TestHandler) HandleAuth(c *arpc.Context) {
-	var req rpccall.RpcReqWrapper[cauth.AuthReq]
-
-	ok := srpc.BindAndWriteLoggedIfError(c, &req)
-	if !ok {
-		return
-	}
-
-	var resp = rpccall.Ok(cauth.AuthResp{
+func (th *TestHandler) HandleAuth(_ context.Context, req rpccall.RpcReqWrapper[cauth.AuthReq]) (rpccall.RpcRespWrapper[cauth.AuthResp], error) {
+	return rpccall.Ok(cauth.AuthResp{
 		Uid: "9527",
-	})
-
-	srpc.WriteLoggedIfError(c, &resp)
+	}), nil
 }
@@ -8,6 +8,7 @@ import (
 	"github.com/sweemingdow/gmicro_pkg/examples/service_two/internal/handlers/hrpc"
 	"github.com/sweemingdow/gmicro_pkg/pkg/app"
 	"github.com/sweemingdow/gmicro_pkg/pkg/routebinder"
+	"github.com/sweemingdow/gmicro_pkg/pkg/server/srpc"
 )
 
 type twoServiceRouteBinder struct {
@@ -31,5 +32,5 @@ func (rb *twoServiceRouteBinder) BindFiber(fa *fiber.App) {
 
 func (rb *twoServiceRouteBinder) BindArpc(srv *arpc.Server) {
 	srv.Handler.Handle("/two/test/12", rb.testHandler.HandleTest)
-	srv.Handler.Handle("/auth", rb.testHandler.HandleAuth)
+	srpc.Register(srv, "/auth", rb.testHandler.HandleAuth)
 }
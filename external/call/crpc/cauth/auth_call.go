@@ -31,9 +31,9 @@ type PingResp struct {
 
 // @rpc_server two_service
 //
-// #go:generate go run ../../../../tools/rpcgen/main.go -type AuthRpcProvider -pkg_prefix github.com/sweemingdow/gmicro_pkg
+// #go:generate go run ../../../../tools/rpcgen -type AuthRpcProvider -pkg_prefix github.com/sweemingdow/gmicro_pkg
 //
-//go:generate rpcgen -type AuthRpcProvider
+//go:generate rpcgen -type AuthRpcProvider -manifest
 type AuthRpcProvider interface {
 	// @path /auth
 	// @timeout 1s
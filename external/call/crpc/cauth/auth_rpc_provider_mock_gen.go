@@ -0,0 +1,93 @@
+// Code generated by rpcgen. DO NOT EDIT.
+
+package cauth
+
+import (
+	"github.com/sweemingdow/gmicro_pkg/pkg/server/srpc/rpccall"
+	"github.com/sweemingdow/gmicro_pkg/pkg/server/srpc/rpcmock"
+)
+
+var _ AuthRpcProvider = (*MockAuthRpcProvider)(nil)
+
+// MockAuthRpcProvider programmable mock of AuthRpcProvider, set the <Method>Func fields or use Stub<Method>,
+// calls are recorded by the embedded rpcmock.Recorder. Unstubbed methods return rpcmock.ErrNotStubbed
+type MockAuthRpcProvider struct {
+	rpcmock.Recorder
+
+	AuthFunc        func(req rpccall.RpcReqWrapper[AuthReq]) (rpccall.RpcRespWrapper[AuthResp], error)
+	ClearFunc       func(req rpccall.RpcReqWrapper[ClearReq]) (rpccall.RpcRespSimple, error)
+	UpdateTokenFunc func(req rpccall.RpcReqWrapper[UpdateReq]) (rpccall.RpcRespSimple, error)
+	PingFunc        func(req PingReq) (PingResp, error)
+}
+
+func NewMockAuthRpcProvider() *MockAuthRpcProvider {
+	return &MockAuthRpcProvider{}
+}
+
+// StubAuth makes Auth return resp and err
+func (m *MockAuthRpcProvider) StubAuth(resp rpccall.RpcRespWrapper[AuthResp], err error) *MockAuthRpcProvider {
+	m.AuthFunc = func(req rpccall.RpcReqWrapper[AuthReq]) (rpccall.RpcRespWrapper[AuthResp], error) {
+		return resp, err
+	}
+	return m
+}
+
+func (m *MockAuthRpcProvider) Auth(req rpccall.RpcReqWrapper[AuthReq]) (rpccall.RpcRespWrapper[AuthResp], error) {
+	m.Record("Auth", "/auth", req)
+	if m.AuthFunc == nil {
+		var resp rpccall.RpcRespWrapper[AuthResp]
+		return resp, rpcmock.NotStubbed("AuthRpcProvider", "Auth")
+	}
+	return m.AuthFunc(req)
+}
+
+// StubClear makes Clear return resp and err
+func (m *MockAuthRpcProvider) StubClear(resp rpccall.RpcRespSimple, err error) *MockAuthRpcProvider {
+	m.ClearFunc = func(req rpccall.RpcReqWrapper[ClearReq]) (rpccall.RpcRespSimple, error) {
+		return resp, err
+	}
+	return m
+}
+
+func (m *MockAuthRpcProvider) Clear(req rpccall.RpcReqWrapper[ClearReq]) (rpccall.RpcRespSimple, error) {
+	m.Record("Clear", "/clear", req)
+	if m.ClearFunc == nil {
+		var resp rpccall.RpcRespSimple
+		return resp, rpcmock.NotStubbed("AuthRpcProvider", "Clear")
+	}
+	return m.ClearFunc(req)
+}
+
+// StubUpdateToken makes UpdateToken return resp and err
+func (m *MockAuthRpcProvider) StubUpdateToken(resp rpccall.RpcRespSimple, err error) *MockAuthRpcProvider {
+	m.UpdateTokenFunc = func(req rpccall.RpcReqWrapper[UpdateReq]) (rpccall.RpcRespSimple, error) {
+		return resp, err
+	}
+	return m
+}
+
+func (m *MockAuthRpcProvider) UpdateToken(req rpccall.RpcReqWrapper[UpdateReq]) (rpccall.RpcRespSimple, error) {
+	m.Record("UpdateToken", "/update_token", req)
+	if m.UpdateTokenFunc == nil {
+		var resp rpccall.RpcRespSimple
+		return resp, rpcmock.NotStubbed("AuthRpcProvider", "UpdateToken")
+	}
+	return m.UpdateTokenFunc(req)
+}
+
+// StubPing makes Ping return resp and err
+func (m *MockAuthRpcProvider) StubPing(resp PingResp, err error) *MockAuthRpcProvider {
+	m.PingFunc = func(req PingReq) (PingResp, error) {
+		return resp, err
+	}
+	return m
+}
+
+func (m *MockAuthRpcProvider) Ping(req PingReq) (PingResp, error) {
+	m.Record("Ping", "/ping", req)
+	if m.PingFunc == nil {
+		var resp PingResp
+		return resp, rpcmock.NotStubbed("AuthRpcProvider", "Ping")
+	}
+	return m.PingFunc(req)
+}
@@ -0,0 +1,33 @@
+// Code generated by rpcgen. DO NOT EDIT.
+
+package cauth
+
+import (
+	"context"
+	"github.com/lesismal/arpc"
+	"github.com/sweemingdow/gmicro_pkg/pkg/server/srpc"
+	"github.com/sweemingdow/gmicro_pkg/pkg/server/srpc/rpccall"
+)
+
+// AuthRpcServer server side of AuthRpcProvider, ctx carries the caller's deadline, request id and baggage
+type AuthRpcServer interface {
+	// Auth /auth
+	Auth(ctx context.Context, req rpccall.RpcReqWrapper[AuthReq]) (rpccall.RpcRespWrapper[AuthResp], error)
+
+	// Clear /clear
+	Clear(ctx context.Context, req rpccall.RpcReqWrapper[ClearReq]) (rpccall.RpcRespSimple, error)
+
+	// UpdateToken /update_token
+	UpdateToken(ctx context.Context, req rpccall.RpcReqWrapper[UpdateReq]) (rpccall.RpcRespSimple, error)
+
+	// Ping /ping
+	Ping(ctx context.Context, req PingReq) (PingResp, error)
+}
+
+// BindArpcAuthRpcServer registers every path of AuthRpcProvider on srv, interceptors apply to all of them
+func BindArpcAuthRpcServer(srv *arpc.Server, impl AuthRpcServer, interceptors ...srpc.Interceptor) {
+	srv.Handler.Handle("/auth", srpc.Chain(srpc.Unary(impl.Auth), interceptors...))
+	srv.Handler.Handle("/clear", srpc.Chain(srpc.Unary(impl.Clear), interceptors...))
+	srv.Handler.Handle("/update_token", srpc.Chain(srpc.Unary(impl.UpdateToken), interceptors...))
+	srv.Handler.Handle("/ping", srpc.Chain(srpc.Unary(impl.Ping), interceptors...))
+}
@@ -1,6 +1,7 @@
 package cone
 
 import (
+	"context"
 	"github.com/sweemingdow/gmicro_pkg/pkg/server/srpc/rpccall"
 )
 
@@ -22,17 +23,31 @@ type OneOneOneReq struct {
 
 // @rpc_server one_service
 //
-//go:generate rpcgen -type OneRpcProvider
+//go:generate rpcgen -type OneRpcProvider -manifest
 type OneRpcProvider interface {
 	// @path /v1/one
 	// @timeout 3s
 	One(req rpccall.RpcReqWrapper[OneReq]) (rpccall.RpcRespWrapper[OneResp], error)
 
 	// @path /v1/one_one
+	// @retry 3 50ms 500ms
 	OneOne(req rpccall.RpcReqWrapper[OneOneReq]) (rpccall.RpcRespSimple, error)
 
 	// @path /v1/one_one_one
 	OneOneOne(req rpccall.RpcReqWrapper[OneOneOneReq]) (rpccall.RpcRespSimple, error)
+
+	// @path /v1/one_ctx
+	// @timeout 3s
+	OneCtx(ctx context.Context, req rpccall.RpcReqWrapper[OneReq]) (rpccall.RpcRespWrapper[OneResp], error)
+
+	// @path /v1/one_async
+	// @timeout 3s
+	// @async
+	OneAsync(ctx context.Context, req rpccall.RpcReqWrapper[OneReq], cb func(rpccall.RpcRespWrapper[OneResp], error)) error
+
+	// @path /v1/one_notify
+	// @notify
+	OneNotify(req rpccall.RpcReqWrapper[OneOneOneReq]) error
 }
 
 type OneStrongReq struct {
@@ -45,7 +60,7 @@ type OneStrongResp struct {
 
 // @rpc_server one_service
 //
-//go:generate rpcgen -type OneRpcStrongProvider
+//go:generate rpcgen -type OneRpcStrongProvider -manifest
 type OneRpcStrongProvider interface {
 	// @path /v1/one_strong
 	// @timeout 3s
@@ -3,6 +3,8 @@
 package cone
 
 import (
+	"context"
+	"github.com/lesismal/arpc"
 	"github.com/sweemingdow/gmicro_pkg/pkg/server/srpc/rclient"
 	"github.com/sweemingdow/gmicro_pkg/pkg/server/srpc/rclient/rcfactory"
 	"github.com/sweemingdow/gmicro_pkg/pkg/server/srpc/rpccall"
@@ -23,7 +25,11 @@ func NewOneRpcProvider(clientFactory rcfactory.ArpcClientFactory) OneRpcProvider
 func (p *oneRpcProvider) One(req rpccall.RpcReqWrapper[OneReq]) (rpccall.RpcRespWrapper[OneResp], error) {
 	cp := p.acquireClientProxy()
 	var resp rpccall.RpcRespWrapper[OneResp]
-	if err := cp.Call("/v1/one", &req, &resp, 3 * time.Second); err != nil {
+	var reqForCall interface{}
+	
+	reqForCall = &req
+	
+	if err := cp.Call("/v1/one", reqForCall, &resp, 3 * time.Second); err != nil {
 		return resp, err
 	}
 	return resp, nil
@@ -32,7 +38,11 @@ func (p *oneRpcProvider) One(req rpccall.RpcReqWrapper[OneReq]) (rpccall.RpcResp
 func (p *oneRpcProvider) OneOne(req rpccall.RpcReqWrapper[OneOneReq]) (rpccall.RpcRespSimple, error) {
 	cp := p.acquireClientProxy()
 	var resp rpccall.RpcRespSimple
-	if err := cp.Call("/v1/one_one", &req, &resp, 1 * time.Second); err != nil {
+	var reqForCall interface{}
+	
+	reqForCall = &req
+	
+	if err := cp.Call("/v1/one_one", reqForCall, &resp, 1 * time.Second); err != nil {
 		return resp, err
 	}
 	return resp, nil
@@ -41,13 +51,55 @@ func (p *oneRpcProvider) OneOne(req rpccall.RpcReqWrapper[OneOneReq]) (rpccall.R
 func (p *oneRpcProvider) OneOneOne(req rpccall.RpcReqWrapper[OneOneOneReq]) (rpccall.RpcRespSimple, error) {
 	cp := p.acquireClientProxy()
 	var resp rpccall.RpcRespSimple
-	if err := cp.Call("/v1/one_one_one", &req, &resp, 1 * time.Second); err != nil {
+	var reqForCall interface{}
+	
+	reqForCall = &req
+	
+	if err := cp.Call("/v1/one_one_one", reqForCall, &resp, 1 * time.Second); err != nil {
 		return resp, err
 	}
 	return resp, nil
 }
 
+func (p *oneRpcProvider) OneCtx(ctx context.Context, req rpccall.RpcReqWrapper[OneReq]) (rpccall.RpcRespWrapper[OneResp], error) {
+	cp := p.acquireClientProxy()
+	var resp rpccall.RpcRespWrapper[OneResp]
+	var reqForCall interface{}
+	
+	reqForCall = &req
+	
+	if err := cp.CallContext(ctx, "/v1/one_ctx", reqForCall, &resp, 3 * time.Second); err != nil {
+		return resp, err
+	}
+	return resp, nil
+}
+
+func (p *oneRpcProvider) OneAsync(ctx context.Context, req rpccall.RpcReqWrapper[OneReq], cb func(rpccall.RpcRespWrapper[OneResp], error)) error {
+	cp := p.acquireClientProxy()
+	handler := func(c *arpc.Context, err error) {
+		var resp rpccall.RpcRespWrapper[OneResp]
+		if err == nil {
+			err = c.Bind(&resp)
+		}
+		cb(resp, err)
+	}
+	return cp.CallAsyncContext(ctx, "/v1/one_async", &req, handler, 3 * time.Second)
+}
+
+func (p *oneRpcProvider) OneNotify(req rpccall.RpcReqWrapper[OneOneOneReq]) error {
+	cp := p.acquireClientProxy()
+	return cp.Notify("/v1/one_notify", &req, 1 * time.Second)
+}
+
 
 func (p *oneRpcProvider) acquireClientProxy() rclient.ArpcClientProxy {
 	return p.clientFactory.AcquireClient("one_service")
 }
+
+func init() {
+	rclient.RegisterRetryPolicy("one_service", "/v1/one_one", rclient.RetryPolicy{
+		MaxAttempts:    3,
+		InitialBackoff: 50 * time.Millisecond,
+		MaxBackoff:     500 * time.Millisecond,
+	})
+}
@@ -0,0 +1,129 @@
+// Code generated by rpcgen. DO NOT EDIT.
+
+package cone
+
+import (
+	"context"
+	"github.com/sweemingdow/gmicro_pkg/pkg/server/srpc/rpccall"
+	"github.com/sweemingdow/gmicro_pkg/pkg/server/srpc/rpcmock"
+)
+
+var _ OneRpcProvider = (*MockOneRpcProvider)(nil)
+
+// MockOneRpcProvider programmable mock of OneRpcProvider, set the <Method>Func fields or use Stub<Method>,
+// calls are recorded by the embedded rpcmock.Recorder. Unstubbed methods return rpcmock.ErrNotStubbed
+type MockOneRpcProvider struct {
+	rpcmock.Recorder
+
+	OneFunc       func(req rpccall.RpcReqWrapper[OneReq]) (rpccall.RpcRespWrapper[OneResp], error)
+	OneOneFunc    func(req rpccall.RpcReqWrapper[OneOneReq]) (rpccall.RpcRespSimple, error)
+	OneOneOneFunc func(req rpccall.RpcReqWrapper[OneOneOneReq]) (rpccall.RpcRespSimple, error)
+	OneCtxFunc    func(ctx context.Context, req rpccall.RpcReqWrapper[OneReq]) (rpccall.RpcRespWrapper[OneResp], error)
+	OneAsyncFunc  func(ctx context.Context, req rpccall.RpcReqWrapper[OneReq], cb func(rpccall.RpcRespWrapper[OneResp], error)) error
+	OneNotifyFunc func(req rpccall.RpcReqWrapper[OneOneOneReq]) error
+}
+
+func NewMockOneRpcProvider() *MockOneRpcProvider {
+	return &MockOneRpcProvider{}
+}
+
+// StubOne makes One return resp and err
+func (m *MockOneRpcProvider) StubOne(resp rpccall.RpcRespWrapper[OneResp], err error) *MockOneRpcProvider {
+	m.OneFunc = func(req rpccall.RpcReqWrapper[OneReq]) (rpccall.RpcRespWrapper[OneResp], error) {
+		return resp, err
+	}
+	return m
+}
+
+func (m *MockOneRpcProvider) One(req rpccall.RpcReqWrapper[OneReq]) (rpccall.RpcRespWrapper[OneResp], error) {
+	m.Record("One", "/v1/one", req)
+	if m.OneFunc == nil {
+		var resp rpccall.RpcRespWrapper[OneResp]
+		return resp, rpcmock.NotStubbed("OneRpcProvider", "One")
+	}
+	return m.OneFunc(req)
+}
+
+// StubOneOne makes OneOne return resp and err
+func (m *MockOneRpcProvider) StubOneOne(resp rpccall.RpcRespSimple, err error) *MockOneRpcProvider {
+	m.OneOneFunc = func(req rpccall.RpcReqWrapper[OneOneReq]) (rpccall.RpcRespSimple, error) {
+		return resp, err
+	}
+	return m
+}
+
+func (m *MockOneRpcProvider) OneOne(req rpccall.RpcReqWrapper[OneOneReq]) (rpccall.RpcRespSimple, error) {
+	m.Record("OneOne", "/v1/one_one", req)
+	if m.OneOneFunc == nil {
+		var resp rpccall.RpcRespSimple
+		return resp, rpcmock.NotStubbed("OneRpcProvider", "OneOne")
+	}
+	return m.OneOneFunc(req)
+}
+
+// StubOneOneOne makes OneOneOne return resp and err
+func (m *MockOneRpcProvider) StubOneOneOne(resp rpccall.RpcRespSimple, err error) *MockOneRpcProvider {
+	m.OneOneOneFunc = func(req rpccall.RpcReqWrapper[OneOneOneReq]) (rpccall.RpcRespSimple, error) {
+		return resp, err
+	}
+	return m
+}
+
+func (m *MockOneRpcProvider) OneOneOne(req rpccall.RpcReqWrapper[OneOneOneReq]) (rpccall.RpcRespSimple, error) {
+	m.Record("OneOneOne", "/v1/one_one_one", req)
+	if m.OneOneOneFunc == nil {
+		var resp rpccall.RpcRespSimple
+		return resp, rpcmock.NotStubbed("OneRpcProvider", "OneOneOne")
+	}
+	return m.OneOneOneFunc(req)
+}
+
+// StubOneCtx makes OneCtx return resp and err
+func (m *MockOneRpcProvider) StubOneCtx(resp rpccall.RpcRespWrapper[OneResp], err error) *MockOneRpcProvider {
+	m.OneCtxFunc = func(ctx context.Context, req rpccall.RpcReqWrapper[OneReq]) (rpccall.RpcRespWrapper[OneResp], error) {
+		return resp, err
+	}
+	return m
+}
+
+func (m *MockOneRpcProvider) OneCtx(ctx context.Context, req rpccall.RpcReqWrapper[OneReq]) (rpccall.RpcRespWrapper[OneResp], error) {
+	m.Record("OneCtx", "/v1/one_ctx", req)
+	if m.OneCtxFunc == nil {
+		var resp rpccall.RpcRespWrapper[OneResp]
+		return resp, rpcmock.NotStubbed("OneRpcProvider", "OneCtx")
+	}
+	return m.OneCtxFunc(ctx, req)
+}
+
+// StubOneAsync makes OneAsync invoke cb with resp and err synchronously
+func (m *MockOneRpcProvider) StubOneAsync(resp rpccall.RpcRespWrapper[OneResp], err error) *MockOneRpcProvider {
+	m.OneAsyncFunc = func(ctx context.Context, req rpccall.RpcReqWrapper[OneReq], cb func(rpccall.RpcRespWrapper[OneResp], error)) error {
+		cb(resp, err)
+		return nil
+	}
+	return m
+}
+
+func (m *MockOneRpcProvider) OneAsync(ctx context.Context, req rpccall.RpcReqWrapper[OneReq], cb func(rpccall.RpcRespWrapper[OneResp], error)) error {
+	m.Record("OneAsync", "/v1/one_async", req)
+	if m.OneAsyncFunc == nil {
+		return rpcmock.NotStubbed("OneRpcProvider", "OneAsync")
+	}
+	return m.OneAsyncFunc(ctx, req, cb)
+}
+
+// StubOneNotify makes OneNotify return err
+func (m *MockOneRpcProvider) StubOneNotify(err error) *MockOneRpcProvider {
+	m.OneNotifyFunc = func(req rpccall.RpcReqWrapper[OneOneOneReq]) error {
+		return err
+	}
+	return m
+}
+
+func (m *MockOneRpcProvider) OneNotify(req rpccall.RpcReqWrapper[OneOneOneReq]) error {
+	m.Record("OneNotify", "/v1/one_notify", req)
+	if m.OneNotifyFunc == nil {
+		return rpcmock.NotStubbed("OneRpcProvider", "OneNotify")
+	}
+	return m.OneNotifyFunc(req)
+}
@@ -0,0 +1,41 @@
+// Code generated by rpcgen. DO NOT EDIT.
+
+package cone
+
+import (
+	"context"
+	"github.com/lesismal/arpc"
+	"github.com/sweemingdow/gmicro_pkg/pkg/server/srpc"
+	"github.com/sweemingdow/gmicro_pkg/pkg/server/srpc/rpccall"
+)
+
+// OneRpcServer server side of OneRpcProvider, ctx carries the caller's deadline, request id and baggage
+type OneRpcServer interface {
+	// One /v1/one
+	One(ctx context.Context, req rpccall.RpcReqWrapper[OneReq]) (rpccall.RpcRespWrapper[OneResp], error)
+
+	// OneOne /v1/one_one
+	OneOne(ctx context.Context, req rpccall.RpcReqWrapper[OneOneReq]) (rpccall.RpcRespSimple, error)
+
+	// OneOneOne /v1/one_one_one
+	OneOneOne(ctx context.Context, req rpccall.RpcReqWrapper[OneOneOneReq]) (rpccall.RpcRespSimple, error)
+
+	// OneCtx /v1/one_ctx
+	OneCtx(ctx context.Context, req rpccall.RpcReqWrapper[OneReq]) (rpccall.RpcRespWrapper[OneResp], error)
+
+	// OneAsync /v1/one_async @async
+	OneAsync(ctx context.Context, req rpccall.RpcReqWrapper[OneReq]) (rpccall.RpcRespWrapper[OneResp], error)
+
+	// OneNotify /v1/one_notify @notify
+	OneNotify(ctx context.Context, req rpccall.RpcReqWrapper[OneOneOneReq]) error
+}
+
+// BindArpcOneRpcServer registers every path of OneRpcProvider on srv, interceptors apply to all of them
+func BindArpcOneRpcServer(srv *arpc.Server, impl OneRpcServer, interceptors ...srpc.Interceptor) {
+	srv.Handler.Handle("/v1/one", srpc.Chain(srpc.Unary(impl.One), interceptors...))
+	srv.Handler.Handle("/v1/one_one", srpc.Chain(srpc.Unary(impl.OneOne), interceptors...))
+	srv.Handler.Handle("/v1/one_one_one", srpc.Chain(srpc.Unary(impl.OneOneOne), interceptors...))
+	srv.Handler.Handle("/v1/one_ctx", srpc.Chain(srpc.Unary(impl.OneCtx), interceptors...))
+	srv.Handler.Handle("/v1/one_async", srpc.Chain(srpc.Unary(impl.OneAsync), interceptors...))
+	srv.Handler.Handle("/v1/one_notify", srpc.Chain(srpc.OneWay(impl.OneNotify), interceptors...))
+}
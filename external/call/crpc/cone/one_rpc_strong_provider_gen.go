@@ -23,7 +23,11 @@ func NewOneRpcStrongProvider(clientFactory rcfactory.ArpcClientFactory) OneRpcSt
 func (p *oneRpcStrongProvider) OneStrong(req rpccall.RpcReqWrapper[OneStrongReq]) (rpccall.RpcRespWrapper[OneStrongResp], error) {
 	cp := p.acquireClientProxy()
 	var resp rpccall.RpcRespWrapper[OneStrongResp]
-	if err := cp.Call("/v1/one_strong", &req, &resp, 3 * time.Second); err != nil {
+	var reqForCall interface{}
+	
+	reqForCall = &req
+	
+	if err := cp.Call("/v1/one_strong", reqForCall, &resp, 3 * time.Second); err != nil {
 		return resp, err
 	}
 	return resp, nil
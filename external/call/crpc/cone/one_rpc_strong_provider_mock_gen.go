@@ -0,0 +1,39 @@
+// Code generated by rpcgen. DO NOT EDIT.
+
+package cone
+
+import (
+	"github.com/sweemingdow/gmicro_pkg/pkg/server/srpc/rpccall"
+	"github.com/sweemingdow/gmicro_pkg/pkg/server/srpc/rpcmock"
+)
+
+var _ OneRpcStrongProvider = (*MockOneRpcStrongProvider)(nil)
+
+// MockOneRpcStrongProvider programmable mock of OneRpcStrongProvider, set the <Method>Func fields or use Stub<Method>,
+// calls are recorded by the embedded rpcmock.Recorder. Unstubbed methods return rpcmock.ErrNotStubbed
+type MockOneRpcStrongProvider struct {
+	rpcmock.Recorder
+
+	OneStrongFunc func(req rpccall.RpcReqWrapper[OneStrongReq]) (rpccall.RpcRespWrapper[OneStrongResp], error)
+}
+
+func NewMockOneRpcStrongProvider() *MockOneRpcStrongProvider {
+	return &MockOneRpcStrongProvider{}
+}
+
+// StubOneStrong makes OneStrong return resp and err
+func (m *MockOneRpcStrongProvider) StubOneStrong(resp rpccall.RpcRespWrapper[OneStrongResp], err error) *MockOneRpcStrongProvider {
+	m.OneStrongFunc = func(req rpccall.RpcReqWrapper[OneStrongReq]) (rpccall.RpcRespWrapper[OneStrongResp], error) {
+		return resp, err
+	}
+	return m
+}
+
+func (m *MockOneRpcStrongProvider) OneStrong(req rpccall.RpcReqWrapper[OneStrongReq]) (rpccall.RpcRespWrapper[OneStrongResp], error) {
+	m.Record("OneStrong", "/v1/one_strong", req)
+	if m.OneStrongFunc == nil {
+		var resp rpccall.RpcRespWrapper[OneStrongResp]
+		return resp, rpcmock.NotStubbed("OneRpcStrongProvider", "OneStrong")
+	}
+	return m.OneStrongFunc(req)
+}
@@ -0,0 +1,21 @@
+// Code generated by rpcgen. DO NOT EDIT.
+
+package cone
+
+import (
+	"context"
+	"github.com/lesismal/arpc"
+	"github.com/sweemingdow/gmicro_pkg/pkg/server/srpc"
+	"github.com/sweemingdow/gmicro_pkg/pkg/server/srpc/rpccall"
+)
+
+// OneRpcStrongServer server side of OneRpcStrongProvider, ctx carries the caller's deadline, request id and baggage
+type OneRpcStrongServer interface {
+	// OneStrong /v1/one_strong
+	OneStrong(ctx context.Context, req rpccall.RpcReqWrapper[OneStrongReq]) (rpccall.RpcRespWrapper[OneStrongResp], error)
+}
+
+// BindArpcOneRpcStrongServer registers every path of OneRpcStrongProvider on srv, interceptors apply to all of them
+func BindArpcOneRpcStrongServer(srv *arpc.Server, impl OneRpcStrongServer, interceptors ...srpc.Interceptor) {
+	srv.Handler.Handle("/v1/one_strong", srpc.Chain(srpc.Unary(impl.OneStrong), interceptors...))
+}
@@ -35,12 +35,18 @@ type TableItem struct {
 	Id                string               `json:"id,omitempty"`
 	MatchRule         RouteMatchRule       `json:"matchRule,omitempty"`
 	UpstreamClientCfg UpstreamClientConfig `json:"upstreamClientConfig,omitempty"`
+	Filters           []FilterSpec         `json:"filters,omitempty"`
+	RateLimits        []RateLimitSpec      `json:"rateLimits,omitempty"`
+	Traffic           TrafficSplitSpec     `json:"traffic,omitempty"`
+	Retry             RetrySpec            `json:"retry,omitempty"`
 }
 
 type RouteMatchRule struct {
-	Type string         `json:"type,omitempty"`
-	Path string         `json:"path,omitempty"`
-	Args map[string]any `json:"args,omitempty"`
+	Type       string          `json:"type,omitempty"`
+	Path       string          `json:"path,omitempty"`
+	Args       map[string]any  `json:"args,omitempty"`
+	Predicates RoutePredicates `json:"predicates,omitempty"`
+	Priority   int             `json:"priority,omitempty"`
 }
 
 type UpstreamClientConfig struct {
@@ -51,6 +57,7 @@ type UpstreamClientConfig struct {
 	WriteTimeoutMills        int `json:"writeTimeoutMills,omitempty"`
 	MaxResponseBodySize      int `json:"maxResponseBodySize,omitempty"`
 	MaxConnWaitTimeoutMills  int `json:"maxConnWaitTimeoutMills,omitempty"`
+	RequestTimeoutMills      int `json:"requestTimeoutMills,omitempty"` // 整个请求(含重试)的超时, 可被路由覆盖
 }
 
 type ConfigurableGatewayServer struct {
@@ -65,6 +72,7 @@ func NewConfigurableGatewayServer(
 	disExtraMap map[string]any,
 	modifyReqs, modifyResps []fiber.Handler,
 	errHandler fiber.ErrorHandler,
+	deps ...string, // 依赖的组件key, 停机时网关先于它们释放
 ) *ConfigurableGatewayServer {
 	cgs := &ConfigurableGatewayServer{
 		gwSrv: NewGatewayServer(
@@ -79,7 +87,7 @@ func NewConfigurableGatewayServer(
 		),
 	}
 
-	lifetime.GetAppFinalizer().Collect("configurable_gw_server", cgs)
+	lifetime.GetAppFinalizer().Collect("configurable_gw_server", cgs, deps...)
 
 	dnacos.RegisterObserver(
 		GatewayRouterTableConfigName,
@@ -87,8 +95,9 @@ func NewConfigurableGatewayServer(
 			cfg := val.(RouterTableConfig)
 			tabItems := Cfg2routerItems(cfg)
 
+			// 校验失败时保留原路由表, 已记录日志
 			if len(tabItems) > 0 {
-				cgs.gwSrv.OnRouterTableRefresh(tabItems)
+				_ = cgs.gwSrv.OnRouterTableRefresh(tabItems)
 			}
 		},
 	)
@@ -122,14 +131,26 @@ func Cfg2routerItems(cfg RouterTableConfig) []RouterTableItem {
 
 	tabItems := make([]RouterTableItem, len(cfg.Tables))
 	for idx, tab := range cfg.Tables {
+		var requestTimeoutMills = tab.UpstreamClientCfg.RequestTimeoutMills
+		if requestTimeoutMills == 0 {
+			requestTimeoutMills = commCliCfg.RequestTimeoutMills
+		}
+
 		tabItems[idx] = RouterTableItem{
 			ServiceName: tab.Id,
 			MatchRule: MatchRule{
-				Type: tab.MatchRule.Type,
-				Path: tab.MatchRule.Path,
-				Args: tab.MatchRule.Args,
+				Type:       tab.MatchRule.Type,
+				Path:       tab.MatchRule.Path,
+				Args:       tab.MatchRule.Args,
+				Predicates: tab.MatchRule.Predicates,
+				Priority:   tab.MatchRule.Priority,
 			},
-			HostClientCfg: convertHostClientConfig(commCliCfg, tab.UpstreamClientCfg),
+			HostClientCfg:  convertHostClientConfig(commCliCfg, tab.UpstreamClientCfg),
+			Filters:        tab.Filters,
+			RateLimits:     tab.RateLimits,
+			Traffic:        tab.Traffic,
+			Retry:          tab.Retry,
+			RequestTimeout: time.Duration(requestTimeoutMills) * time.Millisecond,
 		}
 	}
 
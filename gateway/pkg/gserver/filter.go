@@ -0,0 +1,414 @@
+package gserver
+
+import (
+	"errors"
+	"fmt"
+	"github.com/gofiber/fiber/v2"
+	"github.com/sweemingdow/gmicro_pkg/pkg/server/shttp/revproxy"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	FilterStripPrefix          = "strip_prefix"
+	FilterAddPrefix            = "add_prefix"
+	FilterRegexRewrite         = "regex_rewrite"
+	FilterSetRequestHeader     = "set_request_header"
+	FilterRemoveRequestHeader  = "remove_request_header"
+	FilterSetResponseHeader    = "set_response_header"
+	FilterRemoveResponseHeader = "remove_response_header"
+	FilterRedirect             = "redirect"
+	FilterStaticResponse       = "static_response"
+	FilterRequestSizeLimit     = "request_size_limit"
+)
+
+// FilterSpec 路由表中引用的过滤器
+type FilterSpec struct {
+	Name string         `json:"name,omitempty"`
+	Args map[string]any `json:"args,omitempty"`
+}
+
+// RouteFilter Request在转发前执行, 返回revproxy.ErrRequestHandled时不再转发; Response在转发后执行; 为nil时跳过
+type RouteFilter struct {
+	Request  fiber.Handler
+	Response fiber.Handler
+}
+
+// FilterFactory 以路由表中的参数创建过滤器, 参数有误时返回错误, 路由表将被拒绝
+type FilterFactory func(args FilterArgs) (RouteFilter, error)
+
+var (
+	filterMu        sync.RWMutex
+	filterFactories = map[string]FilterFactory{
+		FilterStripPrefix:          stripPrefixFilter,
+		FilterAddPrefix:            addPrefixFilter,
+		FilterRegexRewrite:         regexRewriteFilter,
+		FilterSetRequestHeader:     setRequestHeaderFilter,
+		FilterRemoveRequestHeader:  removeRequestHeaderFilter,
+		FilterSetResponseHeader:    setResponseHeaderFilter,
+		FilterRemoveResponseHeader: removeResponseHeaderFilter,
+		FilterRedirect:             redirectFilter,
+		FilterStaticResponse:       staticResponseFilter,
+		FilterRequestSizeLimit:     requestSizeLimitFilter,
+	}
+)
+
+// RegisterFilter 注册自定义过滤器, 同名覆盖
+func RegisterFilter(name string, factory FilterFactory) {
+	filterMu.Lock()
+	defer filterMu.Unlock()
+
+	filterFactories[name] = factory
+}
+
+func compileFilters(specs []FilterSpec) ([]RouteFilter, error) {
+	filters := make([]RouteFilter, 0, len(specs))
+	for idx, spec := range specs {
+		filterMu.RLock()
+		factory, ok := filterFactories[spec.Name]
+		filterMu.RUnlock()
+
+		if !ok {
+			return nil, fmt.Errorf("unknown filter:%q at %d", spec.Name, idx)
+		}
+
+		rf, err := factory(spec.Args)
+		if err != nil {
+			return nil, fmt.Errorf("invalid filter:%s at %d:%w", spec.Name, idx, err)
+		}
+
+		filters = append(filters, rf)
+	}
+
+	return filters, nil
+}
+
+// FilterArgs 过滤器参数, 来自json, 数字为float64
+type FilterArgs map[string]any
+
+func (fa FilterArgs) String(key string, required bool) (string, error) {
+	val, ok := fa[key]
+	if !ok || val == nil {
+		if required {
+			return "", fmt.Errorf("%s is required", key)
+		}
+		return "", nil
+	}
+
+	s, ok := val.(string)
+	if !ok {
+		return "", fmt.Errorf("%s must be a string", key)
+	}
+
+	if required && s == "" {
+		return "", fmt.Errorf("%s is required", key)
+	}
+
+	return s, nil
+}
+
+func (fa FilterArgs) Int(key string, def int) (int, error) {
+	switch v := fa[key].(type) {
+	case nil:
+		return def, nil
+	case float64:
+		return int(v), nil
+	case int:
+		return v, nil
+	case string:
+		i, err := strconv.Atoi(v)
+		if err != nil {
+			return 0, fmt.Errorf("%s must be an integer", key)
+		}
+		return i, nil
+	default:
+		return 0, fmt.Errorf("%s must be an integer", key)
+	}
+}
+
+func (fa FilterArgs) Strings(key string) ([]string, error) {
+	switch v := fa[key].(type) {
+	case nil:
+		return nil, nil
+	case []string:
+		return v, nil
+	case []any:
+		ss := make([]string, 0, len(v))
+		for _, item := range v {
+			s, ok := item.(string)
+			if !ok {
+				return nil, fmt.Errorf("%s must be a string array", key)
+			}
+			ss = append(ss, s)
+		}
+		return ss, nil
+	default:
+		return nil, fmt.Errorf("%s must be a string array", key)
+	}
+}
+
+func (fa FilterArgs) StringMap(key string) (map[string]string, error) {
+	switch v := fa[key].(type) {
+	case nil:
+		return nil, nil
+	case map[string]string:
+		return v, nil
+	case map[string]any:
+		sm := make(map[string]string, len(v))
+		for k, item := range v {
+			s, ok := item.(string)
+			if !ok {
+				return nil, fmt.Errorf("%s.%s must be a string", key, k)
+			}
+			sm[k] = s
+		}
+		return sm, nil
+	default:
+		return nil, fmt.Errorf("%s must be an object", key)
+	}
+}
+
+func requestFilter(h fiber.Handler) RouteFilter {
+	return RouteFilter{Request: h}
+}
+
+// args: prefix, 路径以prefix开头时去掉
+func stripPrefixFilter(args FilterArgs) (RouteFilter, error) {
+	prefix, err := args.String("prefix", true)
+	if err != nil {
+		return RouteFilter{}, err
+	}
+
+	prefix = strings.TrimSuffix(prefix, "/")
+
+	return requestFilter(func(c *fiber.Ctx) error {
+		uri := c.Request().URI()
+
+		path, ok := strings.CutPrefix(string(uri.Path()), prefix)
+		if !ok || (path != "" && path[0] != '/') {
+			return nil
+		}
+
+		if path == "" {
+			path = "/"
+		}
+
+		uri.SetPath(path)
+		return nil
+	}), nil
+}
+
+// args: prefix
+func addPrefixFilter(args FilterArgs) (RouteFilter, error) {
+	prefix, err := args.String("prefix", true)
+	if err != nil {
+		return RouteFilter{}, err
+	}
+
+	prefix = "/" + strings.Trim(prefix, "/")
+
+	return requestFilter(func(c *fiber.Ctx) error {
+		uri := c.Request().URI()
+		uri.SetPath(prefix + string(uri.Path()))
+		return nil
+	}), nil
+}
+
+// args: regex, replacement, replacement中可使用$1等引用分组
+func regexRewriteFilter(args FilterArgs) (RouteFilter, error) {
+	expr, err := args.String("regex", true)
+	if err != nil {
+		return RouteFilter{}, err
+	}
+
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return RouteFilter{}, err
+	}
+
+	replacement, err := args.String("replacement", false)
+	if err != nil {
+		return RouteFilter{}, err
+	}
+
+	return requestFilter(func(c *fiber.Ctx) error {
+		uri := c.Request().URI()
+
+		path := re.ReplaceAllString(string(uri.Path()), replacement)
+		if path == "" {
+			path = "/"
+		}
+
+		uri.SetPath(path)
+		return nil
+	}), nil
+}
+
+// args: headers, 如{"X-From":"gateway"}
+func setRequestHeaderFilter(args FilterArgs) (RouteFilter, error) {
+	headers, err := requiredHeaders(args)
+	if err != nil {
+		return RouteFilter{}, err
+	}
+
+	return requestFilter(func(c *fiber.Ctx) error {
+		for k, v := range headers {
+			c.Request().Header.Set(k, v)
+		}
+		return nil
+	}), nil
+}
+
+// args: names
+func removeRequestHeaderFilter(args FilterArgs) (RouteFilter, error) {
+	names, err := requiredNames(args)
+	if err != nil {
+		return RouteFilter{}, err
+	}
+
+	return requestFilter(func(c *fiber.Ctx) error {
+		for _, name := range names {
+			c.Request().Header.Del(name)
+		}
+		return nil
+	}), nil
+}
+
+// args: headers
+func setResponseHeaderFilter(args FilterArgs) (RouteFilter, error) {
+	headers, err := requiredHeaders(args)
+	if err != nil {
+		return RouteFilter{}, err
+	}
+
+	return RouteFilter{Response: func(c *fiber.Ctx) error {
+		for k, v := range headers {
+			c.Response().Header.Set(k, v)
+		}
+		return nil
+	}}, nil
+}
+
+// args: names
+func removeResponseHeaderFilter(args FilterArgs) (RouteFilter, error) {
+	names, err := requiredNames(args)
+	if err != nil {
+		return RouteFilter{}, err
+	}
+
+	return RouteFilter{Response: func(c *fiber.Ctx) error {
+		for _, name := range names {
+			c.Response().Header.Del(name)
+		}
+		return nil
+	}}, nil
+}
+
+func requiredHeaders(args FilterArgs) (map[string]string, error) {
+	headers, err := args.StringMap("headers")
+	if err != nil {
+		return nil, err
+	}
+
+	if len(headers) == 0 {
+		return nil, errors.New("headers is required")
+	}
+
+	return headers, nil
+}
+
+func requiredNames(args FilterArgs) ([]string, error) {
+	names, err := args.Strings("names")
+	if err != nil {
+		return nil, err
+	}
+
+	if len(names) == 0 {
+		return nil, errors.New("names is required")
+	}
+
+	return names, nil
+}
+
+// args: url, status(默认302)
+func redirectFilter(args FilterArgs) (RouteFilter, error) {
+	url, err := args.String("url", true)
+	if err != nil {
+		return RouteFilter{}, err
+	}
+
+	status, err := args.Int("status", http.StatusFound)
+	if err != nil {
+		return RouteFilter{}, err
+	}
+
+	if status < 300 || status > 399 {
+		return RouteFilter{}, fmt.Errorf("redirect status must be 3xx, got:%d", status)
+	}
+
+	return requestFilter(func(c *fiber.Ctx) error {
+		if err := c.Redirect(url, status); err != nil {
+			return err
+		}
+		return revproxy.ErrRequestHandled
+	}), nil
+}
+
+// args: status(默认200), body, contentType(默认text/plain)
+func staticResponseFilter(args FilterArgs) (RouteFilter, error) {
+	status, err := args.Int("status", http.StatusOK)
+	if err != nil {
+		return RouteFilter{}, err
+	}
+
+	if http.StatusText(status) == "" {
+		return RouteFilter{}, fmt.Errorf("invalid status:%d", status)
+	}
+
+	body, err := args.String("body", false)
+	if err != nil {
+		return RouteFilter{}, err
+	}
+
+	contentType, err := args.String("contentType", false)
+	if err != nil {
+		return RouteFilter{}, err
+	}
+
+	if contentType == "" {
+		contentType = fiber.MIMETextPlainCharsetUTF8
+	}
+
+	return requestFilter(func(c *fiber.Ctx) error {
+		c.Set(fiber.HeaderContentType, contentType)
+		if err := c.Status(status).SendString(body); err != nil {
+			return err
+		}
+		return revproxy.ErrRequestHandled
+	}), nil
+}
+
+// args: maxBytes, 超过时返回413
+func requestSizeLimitFilter(args FilterArgs) (RouteFilter, error) {
+	maxBytes, err := args.Int("maxBytes", 0)
+	if err != nil {
+		return RouteFilter{}, err
+	}
+
+	if maxBytes <= 0 {
+		return RouteFilter{}, errors.New("maxBytes must be positive")
+	}
+
+	return requestFilter(func(c *fiber.Ctx) error {
+		if c.Request().Header.ContentLength() > maxBytes || len(c.Body()) > maxBytes {
+			if err := c.Status(fiber.StatusRequestEntityTooLarge).SendString("Request entity too large"); err != nil {
+				return err
+			}
+			return revproxy.ErrRequestHandled
+		}
+		return nil
+	}), nil
+}
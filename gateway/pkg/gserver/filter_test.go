@@ -0,0 +1,126 @@
+package gserver
+
+import (
+	"errors"
+	"github.com/gofiber/fiber/v2"
+	"github.com/sweemingdow/gmicro_pkg/pkg/parser/json"
+	"github.com/sweemingdow/gmicro_pkg/pkg/server/shttp/revproxy"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// 与revproxy相同的执行方式, 以echo代替上游
+func filterApp(t *testing.T, specsJson string) *fiber.App {
+	t.Helper()
+
+	var specs []FilterSpec
+	if err := json.Parse([]byte(specsJson), &specs); err != nil {
+		t.Fatal(err)
+	}
+
+	filters, err := compileFilters(specs)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fa := fiber.New()
+	fa.All("/*", func(c *fiber.Ctx) error {
+		for _, rf := range filters {
+			if rf.Request == nil {
+				continue
+			}
+
+			if err := rf.Request(c); err != nil {
+				if errors.Is(err, revproxy.ErrRequestHandled) {
+					return nil
+				}
+				return err
+			}
+		}
+
+		c.Set("X-Upstream", "1")
+		if err := c.SendString(string(c.Request().URI().Path()) + "|" + c.Get("X-From") + "|" + c.Get("Authorization")); err != nil {
+			return err
+		}
+
+		for _, rf := range filters {
+			if rf.Response != nil {
+				if err := rf.Response(c); err != nil {
+					return err
+				}
+			}
+		}
+
+		return nil
+	})
+
+	return fa
+}
+
+func doReq(t *testing.T, fa *fiber.App, method, target, body string) (int, string, map[string][]string) {
+	t.Helper()
+
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	req.Header.Set("Authorization", "token")
+
+	rsp, err := fa.Test(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, _ := io.ReadAll(rsp.Body)
+	return rsp.StatusCode, string(data), rsp.Header
+}
+
+func TestRouteFilters(t *testing.T) {
+	fa := filterApp(t, `[
+		{"name":"strip_prefix","args":{"prefix":"/api"}},
+		{"name":"regex_rewrite","args":{"regex":"^/v1/(.*)$","replacement":"/internal/$1"}},
+		{"name":"add_prefix","args":{"prefix":"svc"}},
+		{"name":"set_request_header","args":{"headers":{"X-From":"gateway"}}},
+		{"name":"remove_request_header","args":{"names":["Authorization"]}},
+		{"name":"set_response_header","args":{"headers":{"X-Route":"one"}}},
+		{"name":"remove_response_header","args":{"names":["X-Upstream"]}},
+		{"name":"request_size_limit","args":{"maxBytes":8}}
+	]`)
+
+	status, body, header := doReq(t, fa, "GET", "/api/v1/users", "")
+	if status != 200 || body != "/svc/internal/users|gateway|" {
+		t.Fatalf("unexpected rewrite result, status:%d, body:%s", status, body)
+	}
+
+	if header["X-Route"][0] != "one" || len(header["X-Upstream"]) != 0 {
+		t.Fatalf("unexpected response headers:%v", header)
+	}
+
+	if status, _, _ = doReq(t, fa, "POST", "/api/v1/users", "more than 8 bytes"); status != fiber.StatusRequestEntityTooLarge {
+		t.Fatalf("expect 413, got:%d", status)
+	}
+
+	fa = filterApp(t, `[{"name":"redirect","args":{"url":"https://example.com","status":301}}]`)
+	if status, _, header = doReq(t, fa, "GET", "/a", ""); status != 301 || header["Location"][0] != "https://example.com" {
+		t.Fatalf("expect redirect, status:%d, header:%v", status, header)
+	}
+
+	fa = filterApp(t, `[{"name":"static_response","args":{"status":503,"body":"maintaining"}}]`)
+	if status, body, _ = doReq(t, fa, "GET", "/a", ""); status != 503 || body != "maintaining" {
+		t.Fatalf("expect static response, status:%d, body:%s", status, body)
+	}
+
+	invalids := []FilterSpec{
+		{Name: "unknown"},
+		{Name: FilterStripPrefix},
+		{Name: FilterRegexRewrite, Args: map[string]any{"regex": "("}},
+		{Name: FilterRedirect, Args: map[string]any{"url": "/a", "status": float64(200)}},
+		{Name: FilterSetRequestHeader, Args: map[string]any{"headers": map[string]any{"a": float64(1)}}},
+		{Name: FilterRequestSizeLimit, Args: map[string]any{"maxBytes": "x"}},
+	}
+
+	for _, spec := range invalids {
+		if _, err := compileFilters([]FilterSpec{spec}); err == nil {
+			t.Fatalf("expect %+v rejected", spec)
+		}
+	}
+}
@@ -1,25 +1,48 @@
 package gserver
 
 import (
+	"cmp"
 	"context"
+	"fmt"
 	"github.com/gofiber/fiber/v2"
 	"github.com/sweemingdow/gmicro_pkg/pkg/mylog"
 	"github.com/sweemingdow/gmicro_pkg/pkg/regdis"
 	"github.com/sweemingdow/gmicro_pkg/pkg/server/shttp/revproxy"
 	"github.com/sweemingdow/gmicro_pkg/pkg/utils/usli"
+	"slices"
 	"sync"
+	"time"
 )
 
 type RouterTableItem struct {
 	ServiceName   string
 	MatchRule     MatchRule
 	HostClientCfg revproxy.HostClientConfig
+
+	// 按顺序执行, 请求过滤器在全局modifyReqs之前, 响应过滤器在全局modifyResps之后
+	Filters []FilterSpec
+
+	// 在全局modifyReqs之后执行, 以便按鉴权后的用户限流
+	RateLimits []RateLimitSpec
+
+	// 按实例metadata分组分流, 如灰度发布
+	Traffic TrafficSplitSpec
+
+	// 整个请求(含重试)的超时, 为0时由HostClientCfg的读写超时计算
+	RequestTimeout time.Duration
+
+	Retry RetrySpec
 }
 
 type GatewayServer struct {
 	mu          sync.Mutex
 	name2proxy  map[string]*revproxy.HttpServerReverseProxy
 	name2item   map[string]RouterTableItem
+	tables      []RouterTableItem       // 保留路由表顺序, 优先级相同时按此顺序匹配
+	matchers    map[string]routeMatcher // 编译后的路由断言
+	filters     map[string][]RouteFilter
+	rateLimits  map[string]fiber.Handler
+	budgets     map[string]retryBudget
 	discovery   regdis.Discovery
 	disExtraMap map[string]any
 	hlSrv       *HotLoadServer
@@ -45,25 +68,73 @@ func NewGatewayServer(
 		modifyResps: modifyResps,
 	}
 
-	gs.OnRouterTableRefresh(tables)
+	if err := gs.OnRouterTableRefresh(tables); err != nil {
+		panic(err)
+	}
 
 	// copy
 	gs.mu.Lock()
-	path2handler := gs.createPath2handler()
+	routes := gs.createRouteHandlers()
 	gs.mu.Unlock()
 
-	gs.hlSrv = NewHotLoadServer(ec, hsCfg, path2handler, errHandler)
+	gs.hlSrv = NewHotLoadServer(ec, hsCfg, routes, errHandler)
 
 	return gs
 }
 
-// 提供对外接口, 动态更新路由表
-func (gs *GatewayServer) OnRouterTableRefresh(tables []RouterTableItem) {
+// 提供对外接口, 动态更新路由表, 断言配置有误时返回错误并保留原路由表
+func (gs *GatewayServer) OnRouterTableRefresh(tables []RouterTableItem) error {
 	if len(tables) == 0 {
 		panic("tables is required")
 	}
 
 	lg := mylog.AppLoggerWithListen()
+
+	matchers := make(map[string]routeMatcher, len(tables))
+	filters := make(map[string][]RouteFilter, len(tables))
+	rateLimits := make(map[string]fiber.Handler, len(tables))
+	splits := make(map[string]*revproxy.TrafficSplit, len(tables))
+	policies := make(map[string]revproxy.RoutePolicy, len(tables))
+	budgets := make(map[string]retryBudget, len(tables))
+
+	gs.mu.Lock()
+	prevBudgets := gs.budgets
+	gs.mu.Unlock()
+
+	for _, tab := range tables {
+		// 编译结果按服务名索引, 同名的表项会互相覆盖
+		if _, ok := matchers[tab.ServiceName]; ok {
+			err := fmt.Errorf("duplicate router table for %s", tab.ServiceName)
+			lg.Error().Err(err).Msg("router tables refresh rejected")
+			return err
+		}
+
+		m, err := compilePredicates(tab.MatchRule.Predicates)
+		if err == nil {
+			filters[tab.ServiceName], err = compileFilters(tab.Filters)
+		}
+
+		if err == nil {
+			rateLimits[tab.ServiceName], err = compileRateLimits(tab.ServiceName, tab.RateLimits)
+		}
+
+		if err == nil {
+			splits[tab.ServiceName], err = compileTrafficSplit(tab.Traffic)
+		}
+
+		if err == nil {
+			policies[tab.ServiceName], budgets[tab.ServiceName], err = compileRoutePolicy(tab, prevBudgets[tab.ServiceName])
+		}
+
+		if err != nil {
+			err = fmt.Errorf("router table for %s is invalid:%w", tab.ServiceName, err)
+			lg.Error().Stack().Err(err).Msg("router tables refresh rejected")
+			return err
+		}
+
+		matchers[tab.ServiceName] = m
+	}
+
 	lg.Info().Msgf("router tables refreshed, tables:%+v", tables)
 
 	name2item := usli.ToItMap(tables, func(tab RouterTableItem) string {
@@ -72,6 +143,11 @@ func (gs *GatewayServer) OnRouterTableRefresh(tables []RouterTableItem) {
 
 	gs.mu.Lock()
 	gs.name2item = name2item
+	gs.tables = tables
+	gs.matchers = matchers
+	gs.filters = filters
+	gs.rateLimits = rateLimits
+	gs.budgets = budgets
 
 	name2proxy := gs.name2proxy
 
@@ -107,11 +183,19 @@ func (gs *GatewayServer) OnRouterTableRefresh(tables []RouterTableItem) {
 		name2proxy[name] = revproxy.NewHttpServerReverseProxy(name, gs.discovery, gs.disExtraMap, gs.name2item[name].HostClientCfg)
 	}
 
+	// 已有的proxy也需更新分流和重试策略
+	for name, proxy := range name2proxy {
+		proxy.SetTrafficSplit(splits[name])
+		proxy.SetRoutePolicy(policies[name])
+	}
+
 	if gs.hlSrv != nil {
-		gs.hlSrv.Reload(gs.createPath2handler())
+		gs.hlSrv.Reload(gs.createRouteHandlers())
 	}
 
 	gs.mu.Unlock()
+
+	return nil
 }
 
 func (gs *GatewayServer) Shutdown(ctx context.Context) error {
@@ -129,20 +213,97 @@ func (gs *GatewayServer) Shutdown(ctx context.Context) error {
 	return nil
 }
 
-func (gs *GatewayServer) createPath2handler() map[string]fiber.Handler {
-	path2handler := make(map[string]fiber.Handler, len(gs.name2proxy))
+type route struct {
+	priority int
+	match    routeMatcher
+	handler  fiber.Handler
+}
+
+// 相同Path的路由合并为一个handler, 按优先级依次判断断言, 都不满足时交由后续Path
+// Path按其中最高的优先级注册, fiber按注册顺序匹配
+func (gs *GatewayServer) createRouteHandlers() []RouteHandler {
+	var (
+		paths      []string
+		path2route = make(map[string][]route)
+	)
+
+	for _, tab := range gs.tables {
+		name := tab.ServiceName
+		proxy, ok := gs.name2proxy[name]
+		if !ok {
+			continue
+		}
 
-	for name, proxy := range gs.name2proxy {
 		item := gs.name2item[name]
 
-		reqHandlers := make([]fiber.Handler, 0, len(gs.modifyReqs)+1)
+		filters := gs.filters[name]
+
+		reqHandlers := make([]fiber.Handler, 0, len(gs.modifyReqs)+len(filters)+2)
 		if pathHandler := createRuleHandler(name, item.MatchRule); pathHandler != nil {
 			reqHandlers = append(reqHandlers, pathHandler)
 		}
+
+		respHandlers := make([]fiber.Handler, 0, len(gs.modifyResps)+len(filters))
+		respHandlers = append(respHandlers, gs.modifyResps...)
+
+		for _, rf := range filters {
+			if rf.Request != nil {
+				reqHandlers = append(reqHandlers, rf.Request)
+			}
+
+			if rf.Response != nil {
+				respHandlers = append(respHandlers, rf.Response)
+			}
+		}
 		reqHandlers = append(reqHandlers, gs.modifyReqs...)
 
-		path2handler[item.MatchRule.Path] = proxy.ReverseProxy(reqHandlers, gs.modifyResps)
+		if rl := gs.rateLimits[name]; rl != nil {
+			reqHandlers = append(reqHandlers, rl)
+		}
+
+		path := item.MatchRule.routePath()
+		if _, ok = path2route[path]; !ok {
+			paths = append(paths, path)
+		}
+
+		path2route[path] = append(path2route[path], route{
+			priority: item.MatchRule.Priority,
+			match:    gs.matchers[name],
+			handler:  proxy.ReverseProxy(reqHandlers, respHandlers),
+		})
 	}
 
-	return path2handler
+	byPriority := func(a, b route) int {
+		return cmp.Compare(b.priority, a.priority)
+	}
+
+	for _, routes := range path2route {
+		slices.SortStableFunc(routes, byPriority)
+	}
+
+	slices.SortStableFunc(paths, func(a, b string) int {
+		return byPriority(path2route[a][0], path2route[b][0])
+	})
+
+	routeHandlers := make([]RouteHandler, 0, len(paths))
+	for _, path := range paths {
+		routeHandlers = append(routeHandlers, RouteHandler{
+			Path:    path,
+			Handler: dispatchRoutes(path2route[path]),
+		})
+	}
+
+	return routeHandlers
+}
+
+func dispatchRoutes(routes []route) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		for _, r := range routes {
+			if r.match(c) {
+				return r.handler(c)
+			}
+		}
+
+		return c.Next()
+	}
 }
@@ -34,6 +34,12 @@ type HotLoadServerConfig struct {
 	Concurrency                int
 }
 
+// RouteHandler 按注册顺序匹配, handler中调用c.Next()交由后续路由处理
+type RouteHandler struct {
+	Path    string
+	Handler fiber.Handler
+}
+
 type HotLoadServer struct {
 	curFa      *fiber.App
 	cfg        HotLoadServerConfig
@@ -43,7 +49,7 @@ type HotLoadServer struct {
 	errHandler fiber.ErrorHandler
 }
 
-func NewHotLoadServer(ec chan<- error, cfg HotLoadServerConfig, path2handler map[string]fiber.Handler, errHandler fiber.ErrorHandler) *HotLoadServer {
+func NewHotLoadServer(ec chan<- error, cfg HotLoadServerConfig, routes []RouteHandler, errHandler fiber.ErrorHandler) *HotLoadServer {
 	hs := &HotLoadServer{
 		cfg:        cfg,
 		errHandler: errHandler,
@@ -54,8 +60,8 @@ func NewHotLoadServer(ec chan<- error, cfg HotLoadServerConfig, path2handler map
 	fa := hs.createFiber()
 	hs.curFa = fa
 
-	for path, handler := range path2handler {
-		fa.All(path, handler)
+	for _, rh := range routes {
+		fa.All(rh.Path, rh.Handler)
 	}
 
 	hs.lh = newHotLoadHandler(fa.Handler())
@@ -83,7 +89,7 @@ func NewHotLoadServer(ec chan<- error, cfg HotLoadServerConfig, path2handler map
 	return hs
 }
 
-func (hs *HotLoadServer) Reload(path2handler map[string]fiber.Handler) {
+func (hs *HotLoadServer) Reload(routes []RouteHandler) {
 	hs.mu.Lock()
 	defer hs.mu.Unlock()
 
@@ -92,8 +98,8 @@ func (hs *HotLoadServer) Reload(path2handler map[string]fiber.Handler) {
 	newFa := hs.createFiber()
 	hs.curFa = newFa
 
-	for path, handler := range path2handler {
-		newFa.All(path, handler)
+	for _, rh := range routes {
+		newFa.All(rh.Path, rh.Handler)
 	}
 
 	hs.lh.swap(newFa.Handler())
@@ -12,8 +12,25 @@ const (
 
 type MatchRule struct {
 	Type string
+
+	// fiber路由, 为空时为/*
 	Path string
+
 	Args map[string]any
+
+	// 同一Path下可按host, method等区分上游服务
+	Predicates RoutePredicates
+
+	// 越大越先匹配, 相同时按路由表中的顺序
+	Priority int
+}
+
+func (mr MatchRule) routePath() string {
+	if mr.Path == "" {
+		return "/*"
+	}
+
+	return mr.Path
 }
 
 // Match Rule create factory
@@ -0,0 +1,352 @@
+package gserver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/gofiber/fiber/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/sweemingdow/gmicro_pkg/pkg/component/credis"
+	"github.com/sweemingdow/gmicro_pkg/pkg/mylog"
+	"github.com/sweemingdow/gmicro_pkg/pkg/server/shttp/revproxy"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	LocalRateLimiterName = "local"
+
+	rateLimitKeyIp     = "ip"
+	rateLimitKeyUser   = "user"
+	rateLimitKeyGlobal = "global"
+	rateLimitKeyHeader = "header:"
+
+	localBucketSweepInterval = time.Minute
+)
+
+// RateLimitSpec 路由表中的限流配置, 令牌桶算法
+type RateLimitSpec struct {
+	// ip(默认), user(见SetAuthUser), header:<name>, global(整个路由共用)
+	// 取不到key(如未登录, 无header)时不受该项限制
+	Key string `json:"key,omitempty"`
+
+	// 每秒生成的令牌数
+	Rate float64 `json:"rate,omitempty"`
+
+	// 桶容量, 默认为ceil(rate)
+	Burst int `json:"burst,omitempty"`
+
+	// 使用的限流器, 默认local, 分布式限流需先RegisterRateLimiter, 如NewRedisRateLimiter
+	Limiter string `json:"limiter,omitempty"`
+}
+
+type RateLimit struct {
+	Rate  float64
+	Burst int
+}
+
+// RateLimiter 取一个令牌, 不足时返回需等待的时长
+type RateLimiter interface {
+	Take(ctx context.Context, key string, limit RateLimit) (bool, time.Duration, error)
+}
+
+var (
+	limiterMu sync.RWMutex
+	limiters  = map[string]RateLimiter{
+		LocalRateLimiterName: NewLocalRateLimiter(),
+	}
+)
+
+// RegisterRateLimiter 注册限流器, 同名覆盖, 需在加载路由表之前注册
+func RegisterRateLimiter(name string, rl RateLimiter) {
+	limiterMu.Lock()
+	defer limiterMu.Unlock()
+
+	limiters[name] = rl
+}
+
+func rateLimiterOf(name string) (RateLimiter, bool) {
+	limiterMu.RLock()
+	defer limiterMu.RUnlock()
+
+	rl, ok := limiters[name]
+	return rl, ok
+}
+
+type authUserCtxKey struct {
+}
+
+// SetAuthUser 由鉴权中间件写入当前用户, 用于按用户限流
+func SetAuthUser(c *fiber.Ctx, uid string) {
+	c.Locals(authUserCtxKey{}, uid)
+}
+
+func GetAuthUserFromCtx(c *fiber.Ctx) string {
+	uid, _ := c.Locals(authUserCtxKey{}).(string)
+	return uid
+}
+
+type compiledRateLimit struct {
+	keyFn   func(c *fiber.Ctx) string
+	prefix  string
+	limit   RateLimit
+	limiter RateLimiter
+}
+
+// 返回nil表示未配置限流, 超过限制时响应429
+func compileRateLimits(route string, specs []RateLimitSpec) (fiber.Handler, error) {
+	if len(specs) == 0 {
+		return nil, nil
+	}
+
+	crls := make([]compiledRateLimit, 0, len(specs))
+	seen := make(map[string]struct{}, len(specs))
+	for idx, spec := range specs {
+		crl, err := compileRateLimit(route, spec)
+		if err != nil {
+			return nil, fmt.Errorf("invalid rate limit at %d:%w", idx, err)
+		}
+
+		// 相同配置共用一个桶, 重复配置会使每次请求多取令牌
+		if _, ok := seen[crl.prefix]; ok {
+			return nil, fmt.Errorf("duplicate rate limit at %d", idx)
+		}
+		seen[crl.prefix] = struct{}{}
+
+		crls = append(crls, crl)
+	}
+
+	return func(c *fiber.Ctx) error {
+		for _, crl := range crls {
+			key := crl.keyFn(c)
+			if key == "" {
+				continue
+			}
+
+			allowed, retryAfter, err := crl.limiter.Take(c.UserContext(), crl.prefix+key, crl.limit)
+			if err != nil {
+				// 限流器不可用时放行
+				lg := mylog.AppLoggerWithListen()
+				lg.Warn().Err(err).Str("route", route).Msg("rate limiter unavailable, request allowed")
+				continue
+			}
+
+			if !allowed {
+				c.Set(fiber.HeaderRetryAfter, strconv.Itoa(int(math.Ceil(max(retryAfter, time.Second).Seconds()))))
+				if err = c.Status(fiber.StatusTooManyRequests).SendString("Too many requests"); err != nil {
+					return err
+				}
+
+				return revproxy.ErrRequestHandled
+			}
+		}
+
+		return nil
+	}, nil
+}
+
+func compileRateLimit(route string, spec RateLimitSpec) (compiledRateLimit, error) {
+	if spec.Rate <= 0 {
+		return compiledRateLimit{}, errors.New("rate must be positive")
+	}
+
+	if spec.Burst < 0 {
+		return compiledRateLimit{}, errors.New("burst must not be negative")
+	}
+
+	if spec.Burst == 0 {
+		spec.Burst = int(math.Ceil(spec.Rate))
+	}
+
+	name := spec.Limiter
+	if name == "" {
+		name = LocalRateLimiterName
+	}
+
+	rl, ok := rateLimiterOf(name)
+	if !ok {
+		return compiledRateLimit{}, fmt.Errorf("unknown limiter:%q", name)
+	}
+
+	if spec.Key == "" {
+		spec.Key = rateLimitKeyIp
+	}
+
+	var keyFn func(c *fiber.Ctx) string
+	switch key := spec.Key; {
+	case key == rateLimitKeyIp:
+		keyFn = func(c *fiber.Ctx) string {
+			return c.IP()
+		}
+	case key == rateLimitKeyUser:
+		keyFn = GetAuthUserFromCtx
+	case key == rateLimitKeyGlobal:
+		keyFn = func(_ *fiber.Ctx) string {
+			return rateLimitKeyGlobal
+		}
+	case strings.HasPrefix(key, rateLimitKeyHeader) && len(key) > len(rateLimitKeyHeader):
+		header := key[len(rateLimitKeyHeader):]
+		keyFn = func(c *fiber.Ctx) string {
+			return c.Get(header)
+		}
+	default:
+		return compiledRateLimit{}, fmt.Errorf("unknown key:%q", key)
+	}
+
+	return compiledRateLimit{
+		keyFn: keyFn,
+		// 按路由和配置内容计数, 热更新调整配置顺序不影响已有的桶
+		prefix:  fmt.Sprintf("%s:%s:%s:%g:%d:", route, name, spec.Key, spec.Rate, spec.Burst),
+		limit:   RateLimit{Rate: spec.Rate, Burst: spec.Burst},
+		limiter: rl,
+	}, nil
+}
+
+// LocalRateLimiter 进程内令牌桶, 多实例部署时各自计数
+type LocalRateLimiter struct {
+	mu        sync.Mutex
+	buckets   map[string]*tokenBucket
+	lastSweep time.Time
+}
+
+type tokenBucket struct {
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+}
+
+func NewLocalRateLimiter() *LocalRateLimiter {
+	return &LocalRateLimiter{
+		buckets:   make(map[string]*tokenBucket),
+		lastSweep: time.Now(),
+	}
+}
+
+func (lrl *LocalRateLimiter) Take(_ context.Context, key string, limit RateLimit) (bool, time.Duration, error) {
+	now := time.Now()
+
+	lrl.mu.Lock()
+	if now.Sub(lrl.lastSweep) > localBucketSweepInterval {
+		lrl.sweep(now)
+	}
+
+	tb, ok := lrl.buckets[key]
+	if !ok {
+		tb = &tokenBucket{tokens: float64(limit.Burst), last: now}
+		lrl.buckets[key] = tb
+	}
+	lrl.mu.Unlock()
+
+	allowed, wait := tb.take(now, limit)
+	return allowed, wait, nil
+}
+
+// 清理已空闲超过一个周期的桶, 再次使用时按满桶创建
+func (lrl *LocalRateLimiter) sweep(now time.Time) {
+	lrl.lastSweep = now
+
+	for key, tb := range lrl.buckets {
+		tb.mu.Lock()
+		idle := now.Sub(tb.last) > localBucketSweepInterval
+		tb.mu.Unlock()
+
+		if idle {
+			delete(lrl.buckets, key)
+		}
+	}
+}
+
+func (tb *tokenBucket) take(now time.Time, limit RateLimit) (bool, time.Duration) {
+	tb.mu.Lock()
+	defer tb.mu.Unlock()
+
+	if elapsed := now.Sub(tb.last); elapsed > 0 {
+		tb.tokens = min(float64(limit.Burst), tb.tokens+elapsed.Seconds()*limit.Rate)
+		tb.last = now
+	}
+
+	if tb.tokens >= 1 {
+		tb.tokens--
+		return true, 0
+	}
+
+	return false, time.Duration((1 - tb.tokens) / limit.Rate * float64(time.Second))
+}
+
+// 以redis服务端时间计算, 避免各网关实例时钟不一致
+var tokenBucketScript = redis.NewScript(`
+if redis.replicate_commands then
+	redis.replicate_commands()
+end
+
+local rate = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+
+local t = redis.call('TIME')
+local now = tonumber(t[1]) * 1000 + math.floor(tonumber(t[2]) / 1000)
+
+local st = redis.call('HMGET', KEYS[1], 'tokens', 'ts')
+local tokens = tonumber(st[1])
+local ts = tonumber(st[2])
+if tokens == nil or ts == nil then
+	tokens = burst
+	ts = now
+end
+
+tokens = math.min(burst, tokens + math.max(0, now - ts) * rate / 1000)
+
+local allowed = 0
+local wait = 0
+if tokens >= 1 then
+	tokens = tokens - 1
+	allowed = 1
+else
+	wait = math.ceil((1 - tokens) * 1000 / rate)
+end
+
+redis.call('HSET', KEYS[1], 'tokens', tostring(tokens), 'ts', now)
+redis.call('PEXPIRE', KEYS[1], math.ceil(burst * 1000 / rate) + 1000)
+
+return {allowed, wait}
+`)
+
+// RedisRateLimiter 基于redis的令牌桶, 多个网关实例共享计数
+type RedisRateLimiter struct {
+	rc     *credis.RedisClient
+	prefix string
+}
+
+// NewRedisRateLimiter prefix为空时使用gw:rl:
+func NewRedisRateLimiter(rc *credis.RedisClient, prefix string) *RedisRateLimiter {
+	if prefix == "" {
+		prefix = "gw:rl:"
+	}
+
+	return &RedisRateLimiter{
+		rc:     rc,
+		prefix: prefix,
+	}
+}
+
+func (rrl *RedisRateLimiter) Take(ctx context.Context, key string, limit RateLimit) (bool, time.Duration, error) {
+	var res []int64
+
+	err := rrl.rc.With(func(cli redis.UniversalClient) error {
+		var err error
+		res, err = tokenBucketScript.Run(ctx, cli, []string{rrl.prefix + key}, limit.Rate, limit.Burst).Int64Slice()
+		return err
+	})
+
+	if err != nil {
+		return false, 0, err
+	}
+
+	if len(res) != 2 {
+		return false, 0, fmt.Errorf("unexpected token bucket result:%v", res)
+	}
+
+	return res[0] == 1, time.Duration(res[1]) * time.Millisecond, nil
+}
@@ -0,0 +1,116 @@
+package gserver
+
+import (
+	"context"
+	"errors"
+	"github.com/gofiber/fiber/v2"
+	"github.com/sweemingdow/gmicro_pkg/pkg/server/shttp/revproxy"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRateLimit(t *testing.T) {
+	rl, err := compileRateLimits("one_service", []RateLimitSpec{
+		{Key: "header:X-Api-Key", Rate: 1, Burst: 2},
+		{Key: "user", Rate: 1000},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fa := fiber.New()
+	fa.All("/*", func(c *fiber.Ctx) error {
+		SetAuthUser(c, c.Get("X-Uid"))
+
+		if err := rl(c); err != nil {
+			if errors.Is(err, revproxy.ErrRequestHandled) {
+				return nil
+			}
+			return err
+		}
+
+		return c.SendString("ok")
+	})
+
+	call := func(apiKey string) (int, string) {
+		req := httptest.NewRequest("GET", "/a", nil)
+		req.Header.Set("X-Api-Key", apiKey)
+
+		rsp, err := fa.Test(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return rsp.StatusCode, rsp.Header.Get(fiber.HeaderRetryAfter)
+	}
+
+	for i := 0; i < 2; i++ {
+		if status, _ := call("k1"); status != 200 {
+			t.Fatalf("expect burst allowed, got:%d", status)
+		}
+	}
+
+	if status, retryAfter := call("k1"); status != fiber.StatusTooManyRequests || retryAfter != "1" {
+		t.Fatalf("expect 429 with retry-after, got:%d, %q", status, retryAfter)
+	}
+
+	// key之间独立计数, 取不到key时不限流
+	if status, _ := call("k2"); status != 200 {
+		t.Fatalf("expect another key allowed, got:%d", status)
+	}
+
+	for i := 0; i < 5; i++ {
+		if status, _ := call(""); status != 200 {
+			t.Fatalf("expect request without key allowed, got:%d", status)
+		}
+	}
+
+	// 热更新调整配置顺序后沿用原有的桶
+	if rl, err = compileRateLimits("one_service", []RateLimitSpec{
+		{Key: "user", Rate: 1000},
+		{Key: "header:X-Api-Key", Rate: 1, Burst: 2},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if status, _ := call("k1"); status != fiber.StatusTooManyRequests {
+		t.Fatalf("expect bucket kept after reorder, got:%d", status)
+	}
+
+	if _, err = compileRateLimits("one_service", []RateLimitSpec{{Rate: 1}, {Key: "ip", Rate: 1}}); err == nil {
+		t.Fatal("expect duplicate rate limit rejected")
+	}
+
+	invalids := []RateLimitSpec{
+		{Rate: 0},
+		{Rate: 1, Burst: -1},
+		{Rate: 1, Key: "cookie"},
+		{Rate: 1, Limiter: "redis"},
+	}
+
+	for _, spec := range invalids {
+		if _, err = compileRateLimits("one_service", []RateLimitSpec{spec}); err == nil {
+			t.Fatalf("expect %+v rejected", spec)
+		}
+	}
+}
+
+func TestLocalRateLimiterRefill(t *testing.T) {
+	lrl := NewLocalRateLimiter()
+	limit := RateLimit{Rate: 100, Burst: 1}
+
+	if ok, _, _ := lrl.Take(context.Background(), "k", limit); !ok {
+		t.Fatal("expect first token")
+	}
+
+	ok, wait, _ := lrl.Take(context.Background(), "k", limit)
+	if ok || wait <= 0 || wait > 10*time.Millisecond {
+		t.Fatalf("expect rejected with wait<=10ms, got:%v, %v", ok, wait)
+	}
+
+	time.Sleep(wait + time.Millisecond)
+	if ok, _, _ = lrl.Take(context.Background(), "k", limit); !ok {
+		t.Fatal("expect token refilled")
+	}
+}
@@ -0,0 +1,87 @@
+package gserver
+
+import (
+	"errors"
+	"fmt"
+	"github.com/sweemingdow/gmicro_pkg/pkg/server/shttp/revproxy"
+	"strings"
+)
+
+const (
+	defaultRetryBudgetRatio     = 0.2
+	defaultRetryBudgetMinPerSec = 10
+)
+
+// RetrySpec 路由表中的重试配置, 连接失败或命中状态码时换一个实例重试
+type RetrySpec struct {
+	// 包含首次请求, 小于2时不重试
+	MaxAttempts int `json:"maxAttempts,omitempty"`
+
+	// 默认GET, HEAD, OPTIONS, PUT, DELETE, TRACE
+	Methods []string `json:"methods,omitempty"`
+
+	// 如[502, 503, 504], 为空时只在连接失败时重试
+	RetryOnStatus []int `json:"retryOnStatus,omitempty"`
+
+	// 重试量不超过请求量的比例, 默认0.2
+	BudgetRatio float64 `json:"budgetRatio,omitempty"`
+
+	// 每秒最少允许的重试次数, 默认10
+	BudgetMinPerSec float64 `json:"budgetMinPerSec,omitempty"`
+}
+
+// 路由的重试预算, 参数未变时热更新沿用原预算, 避免每次更新路由表都重置
+type retryBudget struct {
+	ratio     float64
+	minPerSec float64
+	budget    *revproxy.RetryBudget
+}
+
+// prev为该路由当前使用的预算, 返回更新后使用的预算, 未配置重试时为零值
+func compileRoutePolicy(item RouterTableItem, prev retryBudget) (revproxy.RoutePolicy, retryBudget, error) {
+	rp := revproxy.RoutePolicy{Timeout: item.RequestTimeout}
+
+	if rp.Timeout < 0 {
+		return rp, retryBudget{}, errors.New("request timeout must not be negative")
+	}
+
+	spec := item.Retry
+	if spec.MaxAttempts < 2 {
+		return rp, retryBudget{}, nil
+	}
+
+	methods := make([]string, 0, len(spec.Methods))
+	for _, m := range spec.Methods {
+		methods = append(methods, strings.ToUpper(strings.TrimSpace(m)))
+	}
+
+	for _, status := range spec.RetryOnStatus {
+		if status < 100 || status > 599 {
+			return rp, retryBudget{}, fmt.Errorf("invalid retry status:%d", status)
+		}
+	}
+
+	if spec.BudgetRatio < 0 || spec.BudgetMinPerSec < 0 {
+		return rp, retryBudget{}, errors.New("retry budget must not be negative")
+	}
+
+	rb := retryBudget{ratio: spec.BudgetRatio, minPerSec: spec.BudgetMinPerSec}
+	if rb.ratio == 0 && rb.minPerSec == 0 {
+		rb.ratio, rb.minPerSec = defaultRetryBudgetRatio, defaultRetryBudgetMinPerSec
+	}
+
+	if prev.budget != nil && prev.ratio == rb.ratio && prev.minPerSec == rb.minPerSec {
+		rb.budget = prev.budget
+	} else {
+		rb.budget = revproxy.NewRetryBudget(rb.ratio, rb.minPerSec)
+	}
+
+	rp.Retry = &revproxy.RetryPolicy{
+		MaxAttempts:   spec.MaxAttempts,
+		Methods:       methods,
+		RetryOnStatus: spec.RetryOnStatus,
+		Budget:        rb.budget,
+	}
+
+	return rp, rb, nil
+}
@@ -0,0 +1,42 @@
+package gserver
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCompileRoutePolicy(t *testing.T) {
+	rp, _, err := compileRoutePolicy(RouterTableItem{RequestTimeout: time.Second, Retry: RetrySpec{MaxAttempts: 1}}, retryBudget{})
+	if err != nil || rp.Timeout != time.Second || rp.Retry != nil {
+		t.Fatalf("expect timeout only, got:%+v, %v", rp, err)
+	}
+
+	item := RouterTableItem{Retry: RetrySpec{MaxAttempts: 3, Methods: []string{"post"}, RetryOnStatus: []int{503}}}
+	rp, rb, err := compileRoutePolicy(item, retryBudget{})
+	if err != nil || rp.Retry == nil || rp.Retry.Methods[0] != "POST" || rp.Retry.Budget == nil || rb.budget != rp.Retry.Budget {
+		t.Fatalf("expect retry policy with default budget, got:%+v, %v", rp, err)
+	}
+
+	// 热更新时预算参数不变则沿用, 变化时重建
+	item.Retry.MaxAttempts = 2
+	if rp, _, _ = compileRoutePolicy(item, rb); rp.Retry.Budget != rb.budget {
+		t.Fatal("expect budget kept when unchanged")
+	}
+
+	item.Retry.BudgetRatio = 0.5
+	if rp, _, _ = compileRoutePolicy(item, rb); rp.Retry.Budget == rb.budget {
+		t.Fatal("expect budget rebuilt when changed")
+	}
+
+	invalids := []RouterTableItem{
+		{RequestTimeout: -time.Second},
+		{Retry: RetrySpec{MaxAttempts: 2, RetryOnStatus: []int{600}}},
+		{Retry: RetrySpec{MaxAttempts: 2, BudgetRatio: -0.1}},
+	}
+
+	for _, item := range invalids {
+		if _, _, err = compileRoutePolicy(item, retryBudget{}); err == nil {
+			t.Fatalf("expect %+v rejected", item)
+		}
+	}
+}
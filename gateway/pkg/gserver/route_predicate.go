@@ -0,0 +1,175 @@
+package gserver
+
+import (
+	"fmt"
+	"github.com/gofiber/fiber/v2"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// RoutePredicates 路由断言, 所有配置的条件都满足时才命中路由, 未配置的条件不参与判断
+type RoutePredicates struct {
+	// 支持通配符前缀, 如*.example.com, 不区分大小写, 满足其一即可
+	Hosts []string `json:"hosts,omitempty"`
+
+	// 如GET, POST, 满足其一即可
+	Methods []string `json:"methods,omitempty"`
+
+	// 对原始请求路径(重写前)的正则匹配
+	PathRegex string `json:"pathRegex,omitempty"`
+
+	Headers []ValuePredicate `json:"headers,omitempty"`
+
+	Queries []ValuePredicate `json:"queries,omitempty"`
+
+	Cookies []ValuePredicate `json:"cookies,omitempty"`
+}
+
+// ValuePredicate Value与Regex都为空时只要求存在, 都配置时优先Value
+type ValuePredicate struct {
+	Name  string `json:"name,omitempty"`
+	Value string `json:"value,omitempty"`
+	Regex string `json:"regex,omitempty"`
+}
+
+type routeMatcher func(c *fiber.Ctx) bool
+
+func compilePredicates(rp RoutePredicates) (routeMatcher, error) {
+	var matchers []routeMatcher
+
+	if len(rp.Hosts) > 0 {
+		hosts := make([]string, len(rp.Hosts))
+		for i, h := range rp.Hosts {
+			hosts[i] = strings.ToLower(strings.TrimSpace(h))
+		}
+
+		matchers = append(matchers, func(c *fiber.Ctx) bool {
+			return matchHost(hosts, c.Hostname())
+		})
+	}
+
+	if len(rp.Methods) > 0 {
+		methods := make([]string, len(rp.Methods))
+		for i, m := range rp.Methods {
+			methods[i] = strings.ToUpper(strings.TrimSpace(m))
+		}
+
+		matchers = append(matchers, func(c *fiber.Ctx) bool {
+			return slices.Contains(methods, c.Method())
+		})
+	}
+
+	if rp.PathRegex != "" {
+		re, err := regexp.Compile(rp.PathRegex)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pathRegex:%w", err)
+		}
+
+		matchers = append(matchers, func(c *fiber.Ctx) bool {
+			return re.MatchString(c.Path())
+		})
+	}
+
+	kinds := []struct {
+		name  string
+		preds []ValuePredicate
+		get   func(c *fiber.Ctx, name string) (string, bool)
+	}{
+		{"header", rp.Headers, headerValue},
+		{"query", rp.Queries, queryValue},
+		{"cookie", rp.Cookies, cookieValue},
+	}
+
+	for _, kind := range kinds {
+		for _, vp := range kind.preds {
+			m, err := compileValuePredicate(vp, kind.get)
+			if err != nil {
+				return nil, fmt.Errorf("invalid %s predicate:%w", kind.name, err)
+			}
+
+			matchers = append(matchers, m)
+		}
+	}
+
+	return func(c *fiber.Ctx) bool {
+		for _, m := range matchers {
+			if !m(c) {
+				return false
+			}
+		}
+
+		return true
+	}, nil
+}
+
+func compileValuePredicate(vp ValuePredicate, get func(c *fiber.Ctx, name string) (string, bool)) (routeMatcher, error) {
+	if vp.Name == "" {
+		return nil, fmt.Errorf("name is required")
+	}
+
+	if vp.Value != "" {
+		return func(c *fiber.Ctx) bool {
+			val, ok := get(c, vp.Name)
+			return ok && val == vp.Value
+		}, nil
+	}
+
+	if vp.Regex != "" {
+		re, err := regexp.Compile(vp.Regex)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%w", vp.Name, err)
+		}
+
+		return func(c *fiber.Ctx) bool {
+			val, ok := get(c, vp.Name)
+			return ok && re.MatchString(val)
+		}, nil
+	}
+
+	return func(c *fiber.Ctx) bool {
+		_, ok := get(c, vp.Name)
+		return ok
+	}, nil
+}
+
+func matchHost(hosts []string, host string) bool {
+	host = strings.ToLower(host)
+
+	// 去掉端口, 兼容ipv6
+	if idx := strings.LastIndexByte(host, ':'); idx > strings.LastIndexByte(host, ']') {
+		host = host[:idx]
+	}
+
+	for _, h := range hosts {
+		if h == host {
+			return true
+		}
+
+		// *.example.com匹配a.example.com, 不匹配example.com
+		if suffix, ok := strings.CutPrefix(h, "*"); ok && strings.HasSuffix(host, suffix) && len(host) > len(suffix) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func headerValue(c *fiber.Ctx, name string) (string, bool) {
+	val := c.Request().Header.Peek(name)
+	return string(val), val != nil
+}
+
+func queryValue(c *fiber.Ctx, name string) (string, bool) {
+	args := c.Context().QueryArgs()
+	if !args.Has(name) {
+		return "", false
+	}
+
+	return string(args.Peek(name)), true
+}
+
+func cookieValue(c *fiber.Ctx, name string) (string, bool) {
+	val := c.Request().Header.Cookie(name)
+	return string(val), val != nil
+}
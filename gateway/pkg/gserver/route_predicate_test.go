@@ -0,0 +1,76 @@
+package gserver
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"io"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRoutePredicatesDispatch(t *testing.T) {
+	newRoute := func(name string, priority int, rp RoutePredicates) route {
+		m, err := compilePredicates(rp)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return route{
+			priority: priority,
+			match:    m,
+			handler: func(c *fiber.Ctx) error {
+				return c.SendString(name)
+			},
+		}
+	}
+
+	fa := fiber.New()
+	fa.All("/v1/*", dispatchRoutes([]route{
+		newRoute("admin", 10, RoutePredicates{Hosts: []string{"admin.example.com"}}),
+		newRoute("beta", 5, RoutePredicates{
+			Hosts:   []string{"*.example.com"},
+			Methods: []string{"get"},
+			Headers: []ValuePredicate{{Name: "X-Beta"}},
+			Queries: []ValuePredicate{{Name: "ver", Regex: `^2\.`}},
+			Cookies: []ValuePredicate{{Name: "tester", Value: "1"}},
+		}),
+		newRoute("api", 0, RoutePredicates{Hosts: []string{"api.example.com"}, PathRegex: `^/v1/(users|orders)`}),
+	}))
+	fa.All("/*", func(c *fiber.Ctx) error {
+		return c.SendString("fallback")
+	})
+
+	cases := []struct {
+		method, host, target string
+		headers              map[string]string
+		expect               string
+	}{
+		{"POST", "admin.example.com:8080", "/v1/users", nil, "admin"},
+		{"GET", "api.example.com", "/v1/users/1", nil, "api"},
+		{"GET", "api.example.com", "/v1/goods", nil, "fallback"},
+		{"GET", "api.example.com", "/v1/users?ver=2.1", map[string]string{"X-Beta": "", "Cookie": "tester=1"}, "beta"},
+		{"POST", "api.example.com", "/v1/goods?ver=2.1", map[string]string{"X-Beta": "", "Cookie": "tester=1"}, "fallback"},
+		{"GET", "api.example.com", "/v1/users?ver=1.9", map[string]string{"X-Beta": "", "Cookie": "tester=1"}, "api"},
+		{"GET", "example.com", "/v1/users", nil, "fallback"},
+	}
+
+	for _, cs := range cases {
+		req := httptest.NewRequest(cs.method, "http://"+cs.host+cs.target, nil)
+		for k, v := range cs.headers {
+			req.Header.Set(k, v)
+		}
+
+		rsp, err := fa.Test(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		body, _ := io.ReadAll(rsp.Body)
+		if string(body) != cs.expect {
+			t.Fatalf("%s %s%s expect %s, got %s", cs.method, cs.host, cs.target, cs.expect, body)
+		}
+	}
+
+	if _, err := compilePredicates(RoutePredicates{Headers: []ValuePredicate{{Name: "x", Regex: "("}}}); err == nil {
+		t.Fatal("expect invalid regex rejected")
+	}
+}
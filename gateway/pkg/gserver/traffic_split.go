@@ -0,0 +1,138 @@
+package gserver
+
+import (
+	"errors"
+	"fmt"
+	"github.com/gofiber/fiber/v2"
+	"github.com/sweemingdow/gmicro_pkg/pkg/server/shttp/revproxy"
+)
+
+// TrafficSplitSpec 路由表中的分流配置, 按实例metadata将服务分为多个分组, 按权重分流
+// 如stable(version=v1, 90), canary(version=v2, 10)
+type TrafficSplitSpec struct {
+	Subsets []SubsetSpec `json:"subsets,omitempty"`
+
+	// 按顺序判断, 命中时固定访问对应分组, 如测试人员带上X-Canary: 1访问灰度版本; 该分组没有实例时返回503
+	Overrides []OverrideSpec `json:"overrides,omitempty"`
+}
+
+type SubsetSpec struct {
+	Name     string            `json:"name,omitempty"`
+	Metadata map[string]string `json:"metadata,omitempty"`
+	Weight   int               `json:"weight,omitempty"`
+}
+
+// OverrideSpec Header和Cookie二选一
+// Subset为空时以header/cookie的值作为分组名; 否则值等于Value(为空时只要求存在)时使用Subset
+type OverrideSpec struct {
+	Header string `json:"header,omitempty"`
+	Cookie string `json:"cookie,omitempty"`
+	Value  string `json:"value,omitempty"`
+	Subset string `json:"subset,omitempty"`
+}
+
+// 未配置分组时返回nil
+func compileTrafficSplit(spec TrafficSplitSpec) (*revproxy.TrafficSplit, error) {
+	if len(spec.Subsets) == 0 {
+		if len(spec.Overrides) > 0 {
+			return nil, errors.New("overrides require subsets")
+		}
+		return nil, nil
+	}
+
+	var (
+		names       = make(map[string]struct{}, len(spec.Subsets))
+		subsets     = make([]revproxy.Subset, 0, len(spec.Subsets))
+		totalWeight int
+	)
+
+	for _, sub := range spec.Subsets {
+		if sub.Name == "" {
+			return nil, errors.New("subset name is required")
+		}
+
+		if _, ok := names[sub.Name]; ok {
+			return nil, fmt.Errorf("duplicate subset:%s", sub.Name)
+		}
+		names[sub.Name] = struct{}{}
+
+		if sub.Weight < 0 {
+			return nil, fmt.Errorf("weight of subset:%s must not be negative", sub.Name)
+		}
+		totalWeight += sub.Weight
+
+		subsets = append(subsets, revproxy.Subset{
+			Name:     sub.Name,
+			Metadata: sub.Metadata,
+			Weight:   sub.Weight,
+		})
+	}
+
+	if totalWeight == 0 {
+		return nil, errors.New("at least one subset must have a positive weight")
+	}
+
+	pins := make([]func(c *fiber.Ctx) string, 0, len(spec.Overrides))
+	for idx, ov := range spec.Overrides {
+		pin, err := compileOverride(ov, names)
+		if err != nil {
+			return nil, fmt.Errorf("invalid override at %d:%w", idx, err)
+		}
+
+		pins = append(pins, pin)
+	}
+
+	ts := &revproxy.TrafficSplit{Subsets: subsets}
+	if len(pins) > 0 {
+		ts.Pin = func(c *fiber.Ctx) string {
+			for _, pin := range pins {
+				if name := pin(c); name != "" {
+					return name
+				}
+			}
+
+			return ""
+		}
+	}
+
+	return ts, nil
+}
+
+func compileOverride(ov OverrideSpec, names map[string]struct{}) (func(c *fiber.Ctx) string, error) {
+	var get func(c *fiber.Ctx, name string) (string, bool)
+	switch {
+	case ov.Header != "" && ov.Cookie == "":
+		get = headerValue
+	case ov.Cookie != "" && ov.Header == "":
+		get = cookieValue
+	default:
+		return nil, errors.New("exactly one of header and cookie is required")
+	}
+
+	name := ov.Header + ov.Cookie
+
+	if ov.Subset == "" {
+		// 值为未知分组时不固定
+		return func(c *fiber.Ctx) string {
+			if val, ok := get(c, name); ok {
+				if _, exists := names[val]; exists {
+					return val
+				}
+			}
+
+			return ""
+		}, nil
+	}
+
+	if _, ok := names[ov.Subset]; !ok {
+		return nil, fmt.Errorf("unknown subset:%s", ov.Subset)
+	}
+
+	return func(c *fiber.Ctx) string {
+		if val, ok := get(c, name); ok && (ov.Value == "" || val == ov.Value) {
+			return ov.Subset
+		}
+
+		return ""
+	}, nil
+}
@@ -0,0 +1,76 @@
+package gserver
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/valyala/fasthttp"
+	"testing"
+)
+
+func TestCompileTrafficSplit(t *testing.T) {
+	subsets := []SubsetSpec{
+		{Name: "stable", Metadata: map[string]string{"version": "v1"}, Weight: 90},
+		{Name: "canary", Metadata: map[string]string{"version": "v2"}, Weight: 10},
+	}
+
+	ts, err := compileTrafficSplit(TrafficSplitSpec{
+		Subsets: subsets,
+		Overrides: []OverrideSpec{
+			{Header: "X-Canary", Value: "1", Subset: "canary"},
+			{Cookie: "version"},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fa := fiber.New()
+	pin := func(header, cookie string) string {
+		c := fa.AcquireCtx(&fasthttp.RequestCtx{})
+		defer fa.ReleaseCtx(c)
+
+		if header != "" {
+			c.Request().Header.Set("X-Canary", header)
+		}
+		if cookie != "" {
+			c.Request().Header.SetCookie("version", cookie)
+		}
+
+		return ts.Pin(c)
+	}
+
+	cases := []struct {
+		header, cookie, expect string
+	}{
+		{"1", "", "canary"},
+		{"0", "", ""},
+		{"", "stable", "stable"},
+		{"", "unknown", ""},
+		{"1", "stable", "canary"},
+	}
+
+	for _, cs := range cases {
+		if got := pin(cs.header, cs.cookie); got != cs.expect {
+			t.Fatalf("header:%q cookie:%q expect %q, got %q", cs.header, cs.cookie, cs.expect, got)
+		}
+	}
+
+	if ts, err = compileTrafficSplit(TrafficSplitSpec{}); ts != nil || err != nil {
+		t.Fatalf("expect no split, got:%v, %v", ts, err)
+	}
+
+	invalids := []TrafficSplitSpec{
+		{Overrides: []OverrideSpec{{Header: "X-Canary"}}},
+		{Subsets: []SubsetSpec{{Name: "a"}}},
+		{Subsets: []SubsetSpec{{Name: "a", Weight: 1}, {Name: "a", Weight: 1}}},
+		{Subsets: []SubsetSpec{{Weight: 1}}},
+		{Subsets: []SubsetSpec{{Name: "a", Weight: -1}, {Name: "b", Weight: 2}}},
+		{Subsets: subsets, Overrides: []OverrideSpec{{Header: "h", Cookie: "c"}}},
+		{Subsets: subsets, Overrides: []OverrideSpec{{Header: "h", Subset: "unknown"}}},
+	}
+
+	for _, spec := range invalids {
+		if _, err = compileTrafficSplit(spec); err == nil {
+			t.Fatalf("expect %+v rejected", spec)
+		}
+	}
+}
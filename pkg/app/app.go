@@ -70,10 +70,19 @@ func GetTheApp() *App {
 }
 
 func (app *App) GetAppId() string {
+	if app == nil {
+		return ""
+	}
+
 	return app.appId
 }
 
 func (app *App) GetAppName() string {
+	// 未初始化时(如单元测试)返回空
+	if app == nil {
+		return ""
+	}
+
 	return app.appName
 }
 
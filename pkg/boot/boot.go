@@ -10,11 +10,15 @@ import (
 	"github.com/sweemingdow/gmicro_pkg/pkg/component/cnacos"
 	"github.com/sweemingdow/gmicro_pkg/pkg/decorate/dlog"
 	"github.com/sweemingdow/gmicro_pkg/pkg/decorate/dnacos"
+	"github.com/sweemingdow/gmicro_pkg/pkg/decorate/dregdis"
 	"github.com/sweemingdow/gmicro_pkg/pkg/graceful"
+	"github.com/sweemingdow/gmicro_pkg/pkg/health"
 	"github.com/sweemingdow/gmicro_pkg/pkg/lifetime"
 	"github.com/sweemingdow/gmicro_pkg/pkg/mylog"
 	"github.com/sweemingdow/gmicro_pkg/pkg/parser/cmd"
+	"github.com/sweemingdow/gmicro_pkg/pkg/regdis"
 	"github.com/sweemingdow/gmicro_pkg/pkg/regdis/disnacos"
+	"github.com/sweemingdow/gmicro_pkg/pkg/regdis/extra/enacos"
 	"github.com/sweemingdow/gmicro_pkg/pkg/regdis/regnacos"
 	"github.com/sweemingdow/gmicro_pkg/pkg/routebinder"
 	"github.com/sweemingdow/gmicro_pkg/pkg/server/shttp"
@@ -35,16 +39,32 @@ type AppContext struct {
 
 	arpcClientFactory rcfactory.ArpcClientFactory
 
+	discovery regdis.Discovery
+
+	// extra data for discovery
+	discoveryExtra map[string]any
+
 	cmdParser *cmd.CmdParser
 
 	routeBinder routebinder.AppRouterBinder
 
 	configureReceiver dnacos.ConfigurationReceiver
 
+	health *health.Registry
+
 	preHooks []ShutdownHook
 
 	postHooks []ShutdownHook
 
+	// 创建rpc服务时的选项, 如编解码器
+	rpcServerOpts []srpc.ArpcServerOption
+
+	// rpc服务端和客户端共用, 未启用tls时为nil
+	rpcTls *srpc.TlsReloader
+
+	// 注册时附加的metadata, 服务阶段才确定的信息(如rpc编解码器)写入这里
+	registryMetadata map[string]string
+
 	mu sync.Mutex
 
 	extraStore map[string]any
@@ -52,6 +72,23 @@ type AppContext struct {
 
 type ShutdownHook func(ac *AppContext, ctx context.Context) error
 
+// 内置组件在AppFinalizer中的key, 可用于声明依赖
+const (
+	LcKeyLogWriter        = "log_writer"
+	LcKeyNacosClient      = "cnacos"
+	LcKeyNacosConfig      = "nacos_config"
+	LcKeyNacosRegistry    = "nacos_registry"
+	LcKeyHttpServer       = "http_server"
+	LcKeyArpcServer       = "arpc_server"
+	LcKeyRpcClientFactory = "rpc_client_factory"
+	LcKeyRegistry         = "registry"
+	LcKeyDiscovery        = "discovery"
+	LcKeyRpcTls           = "rpc_tls"
+)
+
+// 下游就绪检查项的名称, 见WithDownstreamReadiness
+const healthKeyDownstream = "downstream"
+
 //func (ac *AppContext) GetFinalizer() *lifetime.AppFinalizer {
 //	return ac.finalizer
 //}
@@ -68,10 +105,64 @@ func (ac *AppContext) GetArpcClientFactory() rcfactory.ArpcClientFactory {
 	return ac.arpcClientFactory
 }
 
+func (ac *AppContext) GetDiscovery() regdis.Discovery {
+	return ac.discovery
+}
+
+func (ac *AppContext) GetDiscoveryExtra() map[string]any {
+	return ac.discoveryExtra
+}
+
 func (ac *AppContext) GetConfigureReceiver() dnacos.ConfigurationReceiver {
 	return ac.configureReceiver
 }
 
+func (ac *AppContext) GetHealth() *health.Registry {
+	return ac.health
+}
+
+// SetRegistryMetadata 注册到注册中心时附加的metadata, 需在组件启动(注册)前设置
+func (ac *AppContext) SetRegistryMetadata(key, val string) {
+	ac.mu.Lock()
+	defer ac.mu.Unlock()
+
+	ac.registryMetadata[key] = val
+}
+
+func (ac *AppContext) registryMetadataSnapshot() map[string]string {
+	ac.mu.Lock()
+	defer ac.mu.Unlock()
+
+	md := make(map[string]string, len(ac.registryMetadata))
+	for k, v := range ac.registryMetadata {
+		md[k] = v
+	}
+
+	return md
+}
+
+// 按配置创建rpc的tls证书加载器, 服务端和客户端共用同一个, 未启用时返回nil
+func (ac *AppContext) rpcTlsReloader() (*srpc.TlsReloader, error) {
+	if ac.rpcTls != nil {
+		return ac.rpcTls, nil
+	}
+
+	cfg := app.GetTheApp().GetConfig().RpcTlsCfg
+	if !cfg.Enabled {
+		return nil, nil
+	}
+
+	tr, err := srpc.NewTlsReloader(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("create rpc tls reloader failed:%w", err)
+	}
+
+	ac.rpcTls = tr
+	ac.CollectLifecycle(LcKeyRpcTls, tr, ac.collectedOf(LcKeyLogWriter)...)
+
+	return tr, nil
+}
+
 func (ac *AppContext) StoreExtra(tag string, val any) {
 	ac.extraStore[tag] = val
 }
@@ -80,8 +171,22 @@ func (ac *AppContext) GetStored(tag string) any {
 	return ac.extraStore[tag]
 }
 
-func (ac *AppContext) CollectLifecycle(tag string, lc lifetime.LifeCycle) {
-	ac.finalizer.Collect(tag, lc)
+// 收集组件, 若组件实现了health.Checker则同时注册为就绪检查项
+func (ac *AppContext) CollectLifecycle(tag string, lc lifetime.LifeCycle, deps ...string) {
+	ac.health.RegisterIfChecker(tag, lc)
+	ac.finalizer.Collect(tag, lc, deps...)
+}
+
+// 过滤出已收集的组件key, 用于依赖前面阶段中可能未启用的组件
+func (ac *AppContext) collectedOf(keys ...string) []string {
+	deps := make([]string, 0, len(keys))
+	for _, key := range keys {
+		if ac.finalizer.Contains(key) {
+			deps = append(deps, key)
+		}
+	}
+
+	return deps
 }
 
 // 可选功能的初始化参数签名
@@ -128,7 +233,10 @@ func (b *Booter) StartAndServe(ready ReadyForRouterMount) {
 	ac := &AppContext{
 		finalizer:  finalizer,
 		exitChan:   ec,
+		health:     health.NewRegistry(0),
 		extraStore: make(map[string]any),
+
+		registryMetadata: make(map[string]string),
 	}
 
 	ac.cmdParser = cp
@@ -170,6 +278,12 @@ func (b *Booter) StartAndServe(ready ReadyForRouterMount) {
 
 	lg.Debug().Msg("server stage completed")
 
+	// 启动剩余组件, 拒绝循环依赖
+	if err := finalizer.Seal(); err != nil {
+		ec <- err
+		return
+	}
+
 	graceful.ListenExitSignal(ec)
 
 	// blocking until receive exit error signal
@@ -193,6 +307,9 @@ func (b *Booter) shutdown(ac *AppContext, exitErr error) {
 	lg := mylog.AppLoggerWithStop()
 	lg.Error().Stack().Err(exitErr).Msg("received signal, exit now")
 
+	// 停机开始, 不再就绪
+	ac.health.MarkShutdown()
+
 	ta := app.GetTheApp()
 
 	timeout := time.Duration(ta.GetConfig().AppCfg.GracefulExitTimeoutMills) * time.Millisecond
@@ -267,7 +384,7 @@ func WithLogger(nameGenFunc mylog.LogFileNameGenerator) AppOption {
 
 		remoteWriter := mylog.InitLogger(ta.GetConfig().LogCfg, ta.IsDevProfile(), ta.GetAppName(), nameGenFunc)
 
-		ac.finalizer.Collect("log_writer", dlog.NewLogRemoteWriter(remoteWriter))
+		ac.CollectLifecycle(LcKeyLogWriter, dlog.NewLogRemoteWriter(remoteWriter))
 		return nil
 	}
 }
@@ -283,7 +400,7 @@ func WithNacosClient() AppOption {
 		}
 
 		ac.nacosClient = nc
-		ac.finalizer.Collect("cnacos", nc)
+		ac.CollectLifecycle(LcKeyNacosClient, nc, ac.collectedOf(LcKeyLogWriter)...)
 		return nil
 	}
 }
@@ -305,7 +422,7 @@ func WithNacosConfig(receiver dnacos.ConfigurationReceiver) AppOption {
 			receiver,
 		)
 
-		ac.finalizer.Collect("nacos_config", autoConfig)
+		ac.CollectLifecycle(LcKeyNacosConfig, autoConfig, LcKeyNacosClient)
 		return nil
 	}
 }
@@ -318,12 +435,78 @@ func WithNacosRegistry() AppOption {
 		}
 		ta := app.GetTheApp()
 
-		autoRegistry := dnacos.NewNacosAutoRegistry(
+		cfg := dnacos.NacosAutoRegistryConfig(ta.GetConfig().NacosCenterCfg.RegistryDiscoverCfg)
+		cfg.MetadataProvider = ac.registryMetadataSnapshot
+
+		autoRegistry := dregdis.NewAutoRegistry(
 			regnacos.NewNacosRegistry(ac.nacosClient.GetNamingClient()),
-			ta.GetConfig().NacosCenterCfg.RegistryDiscoverCfg,
+			cfg,
+			ac.health,
 		)
 
-		ac.finalizer.Collect("nacos_registry", autoRegistry)
+		// 服务启动且就绪检查通过后才注册, 停机时先于服务注销
+		ac.CollectLifecycle(
+			LcKeyNacosRegistry,
+			autoRegistry,
+			LcKeyNacosClient,
+			lifetime.Optional(LcKeyHttpServer),
+			lifetime.Optional(LcKeyArpcServer),
+		)
+		return nil
+	}
+}
+
+// nacos服务发现, 供rpc客户端等使用
+func WithNacosDiscovery() AppOption {
+	return func(ac *AppContext) error {
+		if ac.nacosClient == nil {
+			return errors.New("nacos Client is required for Nacos Discovery but not found in AppContext")
+		}
+
+		regDisCfg := app.GetTheApp().GetConfig().NacosCenterCfg.RegistryDiscoverCfg
+
+		ac.discovery = disnacos.NewNacosDiscovery(ac.nacosClient.GetNamingClient())
+		ac.discoveryExtra = enacos.PkgDiscoveryExtraParam(regDisCfg.ClusterName, regDisCfg.GroupName)
+		return nil
+	}
+}
+
+// 任意注册中心实现, deps为其依赖的组件key(如对应的客户端)
+func WithRegistry(registry regdis.Registry, cfg dregdis.AutoRegistryConfig, deps ...string) AppOption {
+	return func(ac *AppContext) error {
+		if registry == nil {
+			return errors.New("registry is required")
+		}
+
+		deps = append(
+			deps,
+			lifetime.Optional(LcKeyHttpServer),
+			lifetime.Optional(LcKeyArpcServer),
+		)
+
+		if cfg.MetadataProvider == nil {
+			cfg.MetadataProvider = ac.registryMetadataSnapshot
+		}
+
+		ac.CollectLifecycle(LcKeyRegistry, dregdis.NewAutoRegistry(registry, cfg, ac.health), deps...)
+		return nil
+	}
+}
+
+// 任意服务发现实现, 若discovery实现了LifeCycle则一并收集
+func WithDiscovery(discovery regdis.Discovery, extra map[string]any, deps ...string) AppOption {
+	return func(ac *AppContext) error {
+		if discovery == nil {
+			return errors.New("discovery is required")
+		}
+
+		ac.discovery = discovery
+		ac.discoveryExtra = extra
+
+		if lc, ok := discovery.(lifetime.LifeCycle); ok {
+			ac.CollectLifecycle(LcKeyDiscovery, lc, deps...)
+		}
+
 		return nil
 	}
 }
@@ -333,28 +516,57 @@ func WithHttpServer(errHandler fiber.ErrorHandler) AppOption {
 	return func(ac *AppContext) error {
 		fhs := shttp.NewFiberHttpServer(shttp.DefaultFiberServerConfig(app.GetTheApp().GetHttpPort()), errHandler)
 
+		health.BindFiber(fhs.GetFiber(), ac.health)
+
 		if ac.routeBinder != nil {
 			ac.routeBinder.BindFiber(fhs.GetFiber())
 		}
 
-		ac.finalizer.Collect("http_server", fhs)
+		ac.CollectLifecycle(LcKeyHttpServer, fhs, ac.collectedOf(LcKeyLogWriter, LcKeyRpcClientFactory)...)
+
+		return nil
+	}
+}
 
+// rpc服务的创建选项, 如srpc.WithCodecs, 需在WithRpcServer之前添加
+func WithRpcServerOptions(opts ...srpc.ArpcServerOption) AppOption {
+	return func(ac *AppContext) error {
+		ac.rpcServerOpts = append(ac.rpcServerOpts, opts...)
 		return nil
 	}
 }
 
 // 启动rpc服务
-func WithRpcServer() AppOption {
+// interceptors为全局拦截器, 作用于包括健康检查在内的所有路由, 如srpc.Recovery, srpc.AccessLog
+func WithRpcServer(interceptors ...srpc.Interceptor) AppOption {
 	return func(ac *AppContext) error {
 		srpc.InitArpcLogAdapter()
 
-		as := srpc.NewArpcServer(app.GetTheApp().GetRpcPort())
+		tr, err := ac.rpcTlsReloader()
+		if err != nil {
+			return err
+		}
+
+		opts := ac.rpcServerOpts
+		if tr != nil {
+			opts = append(opts, srpc.WithTls(tr))
+		}
+
+		as := srpc.NewArpcServer(app.GetTheApp().GetRpcPort(), opts...)
+
+		// 客户端据此选择编解码器
+		ac.SetRegistryMetadata(regdis.MetadataRpcCodecs, as.CodecNames())
+
+		// 需在绑定路由前注册
+		as.Use(interceptors...)
+
+		health.BindArpc(as.GetArpcSrv(), ac.health)
 
 		if ac.routeBinder != nil {
 			ac.routeBinder.BindArpc(as.GetArpcSrv())
 		}
 
-		ac.finalizer.Collect("arpc_server", as)
+		ac.CollectLifecycle(LcKeyArpcServer, as, ac.collectedOf(LcKeyLogWriter, LcKeyRpcClientFactory, LcKeyRpcTls)...)
 
 		return nil
 	}
@@ -374,24 +586,62 @@ func WithConfigureLoaded(cl ConfigureLoaded) AppOption {
 }
 
 // 启动rpc客户端
-func WithRpcClientFactory(lb rclient.LoadBalancer) AppOption {
-	if lb == nil {
-		lb = rclient.NewRoundRobinLoadBalancer()
-	}
-
+// lb为nil时按配置rpc-client-config.load-balancer选择, 未配置则使用轮询
+// opts作用于每个服务的client proxy, 如rclient.WithBreaker, rclient.WithInterceptors, rclient.WithConnPool
+func WithRpcClientFactory(lb rclient.LoadBalancer, opts ...rclient.ProxyOption) AppOption {
 	return func(ac *AppContext) error {
+		// 未指定服务发现时默认使用nacos
+		if ac.discovery == nil {
+			if err := WithNacosDiscovery()(ac); err != nil {
+				return err
+			}
+		}
+
 		srpc.InitArpcLogAdapter()
 
-		clientFactory := rcfactory.NewNacosArpcClientFactory(
-			disnacos.NewNacosDiscovery(ac.nacosClient.GetNamingClient()),
+		rpcCliCfg := app.GetTheApp().GetConfig().RpcClientCfg
+
+		if lb == nil {
+			var err error
+			if lb, err = rclient.NewLoadBalancer(rpcCliCfg.LoadBalancer); err != nil {
+				return err
+			}
+		}
+
+		tr, err := ac.rpcTlsReloader()
+		if err != nil {
+			return err
+		}
+
+		if tr != nil {
+			opts = append(opts, rclient.WithTls(tr))
+		}
+
+		clientFactory := rcfactory.NewArpcClientFactory(
+			ac.discovery,
 			lb,
-			app.GetTheApp().GetConfig().NacosCenterCfg.RegistryDiscoverCfg,
+			ac.discoveryExtra,
+			time.Duration(rpcCliCfg.DialTimeoutMills)*time.Millisecond,
+			opts...,
 		)
 
 		ac.arpcClientFactory = clientFactory
 
-		ac.finalizer.Collect("rpc_client_factory", clientFactory)
+		ac.CollectLifecycle(LcKeyRpcClientFactory, clientFactory, ac.collectedOf(LcKeyDiscovery, LcKeyNacosClient, LcKeyRpcTls)...)
+
+		return nil
+	}
+}
+
+// 下游服务没有可用实例时本服务视为未就绪, 需在WithRpcClientFactory之后
+// 仅用于强依赖的下游, 相互依赖的服务不能同时使用, 否则都无法就绪
+func WithDownstreamReadiness(serviceNames ...string) AppOption {
+	return func(ac *AppContext) error {
+		if ac.arpcClientFactory == nil {
+			return errors.New("rpc client factory is required for downstream readiness but not found in AppContext")
+		}
 
+		ac.health.RegisterReady(healthKeyDownstream, rcfactory.RequireReady(ac.arpcClientFactory, serviceNames...))
 		return nil
 	}
 }
@@ -0,0 +1,90 @@
+package cetcd
+
+import (
+	"context"
+	"errors"
+	"github.com/sweemingdow/gmicro_pkg/pkg/mylog"
+	clientv3 "go.etcd.io/etcd/client/v3"
+	"sync/atomic"
+	"time"
+)
+
+const defaultDialTimeout = 3 * time.Second
+
+type EtcdCfg struct {
+	Endpoints   []string // [192.168.1.101:2379]
+	Username    string
+	Password    string
+	DialTimeout time.Duration
+}
+
+type EtcdClient struct {
+	cli       *clientv3.Client
+	endpoints []string
+	closed    atomic.Bool
+}
+
+func NewEtcdClient(cfg EtcdCfg) (*EtcdClient, error) {
+	if len(cfg.Endpoints) == 0 {
+		panic("etcd endpoints is required")
+	}
+
+	dialTimeout := cfg.DialTimeout
+	if dialTimeout == 0 {
+		dialTimeout = defaultDialTimeout
+	}
+
+	cli, err := clientv3.New(clientv3.Config{
+		Endpoints:   cfg.Endpoints,
+		Username:    cfg.Username,
+		Password:    cfg.Password,
+		DialTimeout: dialTimeout,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &EtcdClient{
+		cli:       cli,
+		endpoints: cfg.Endpoints,
+	}, nil
+}
+
+func (ec *EtcdClient) GetClient() *clientv3.Client {
+	return ec.cli
+}
+
+func (ec *EtcdClient) HealthCheck(ctx context.Context) error {
+	if ec.closed.Load() {
+		return errors.New("etcd client closed")
+	}
+
+	var errs []error
+	for _, ep := range ec.endpoints {
+		if _, err := ec.cli.Status(ctx, ep); err == nil {
+			return nil
+		} else {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+func (ec *EtcdClient) OnCreated(_ chan<- error) {
+}
+
+func (ec *EtcdClient) OnDispose(_ context.Context) error {
+	if !ec.closed.CompareAndSwap(false, true) {
+		return nil
+	}
+
+	if err := ec.cli.Close(); err != nil {
+		return err
+	}
+
+	lg := mylog.AppLoggerWithStop()
+	lg.Info().Msg("etcd client stopped successfully")
+
+	return nil
+}
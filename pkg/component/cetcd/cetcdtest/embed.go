@@ -0,0 +1,72 @@
+// Package cetcdtest 启动进程内的etcd, 供注册/发现的测试使用
+package cetcdtest
+
+import (
+	"fmt"
+	clientv3 "go.etcd.io/etcd/client/v3"
+	"go.etcd.io/etcd/server/v3/embed"
+	"net"
+	"net/url"
+	"testing"
+	"time"
+)
+
+// Embed 单节点etcd, 测试结束时关闭
+type Embed struct {
+	Etcd *embed.Etcd
+	Cli  *clientv3.Client
+}
+
+// -short时跳过, 启动和租约相关的等待需数秒
+func StartEmbed(t testing.TB) *Embed {
+	t.Helper()
+
+	if testing.Short() {
+		t.Skip("skip embed etcd in short mode")
+	}
+
+	cfg := embed.NewConfig()
+	cfg.Dir = t.TempDir()
+	cfg.LogLevel = "error"
+
+	clientUrl, peerUrl := freeUrl(t), freeUrl(t)
+	cfg.ListenClientUrls = []url.URL{clientUrl}
+	cfg.AdvertiseClientUrls = []url.URL{clientUrl}
+	cfg.ListenPeerUrls = []url.URL{peerUrl}
+	cfg.AdvertisePeerUrls = []url.URL{peerUrl}
+	cfg.InitialCluster = cfg.InitialClusterFromName(cfg.Name)
+
+	e, err := embed.StartEtcd(cfg)
+	if err != nil {
+		t.Fatalf("start embed etcd failed: %v", err)
+	}
+	t.Cleanup(e.Close)
+
+	select {
+	case <-e.Server.ReadyNotify():
+	case <-time.After(10 * time.Second):
+		t.Fatal("embed etcd not ready")
+	}
+
+	cli, err := clientv3.New(clientv3.Config{
+		Endpoints:   []string{clientUrl.Host},
+		DialTimeout: 3 * time.Second,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = cli.Close() })
+
+	return &Embed{Etcd: e, Cli: cli}
+}
+
+func freeUrl(t testing.TB) url.URL {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	u, _ := url.Parse(fmt.Sprintf("http://%s", ln.Addr().String()))
+	return *u
+}
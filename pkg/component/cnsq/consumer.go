@@ -2,6 +2,7 @@ package cnsq
 
 import (
 	"context"
+	"fmt"
 	"github.com/nsqio/go-nsq"
 	"github.com/sweemingdow/gmicro_pkg/pkg/mylog"
 	"sync"
@@ -139,6 +140,17 @@ func (ncs *NsqConsumer) OnDispose(ctx context.Context) error {
 	}
 }
 
+func (ncs *NsqConsumer) HealthCheck(_ context.Context) error {
+	for idx, cs := range ncs.css {
+		if cs.Stats().Connections == 0 {
+			item := ncs.cfg.Items[idx]
+			return fmt.Errorf("nsq consumer has no connection, topic:%s, channel:%s", item.Topic, item.Channel)
+		}
+	}
+
+	return nil
+}
+
 func (mh msgHandler) HandleMessage(m *nsq.Message) error {
 	return mh.csFactory.MsgHandle(mh.topic, mh.channel, m)
 }
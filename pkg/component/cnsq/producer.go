@@ -42,6 +42,10 @@ func (npd *NsqProducer) Publish(pp PublishParam) error {
 	return nil
 }
 
+func (npd *NsqProducer) HealthCheck(_ context.Context) error {
+	return npd.pd.Ping()
+}
+
 func (npd *NsqProducer) OnCreated(_ chan<- error) {
 }
 
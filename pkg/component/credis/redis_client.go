@@ -2,6 +2,7 @@ package credis
 
 import (
 	"context"
+	"errors"
 	"github.com/redis/go-redis/v9"
 	"github.com/sweemingdow/gmicro_pkg/pkg/mylog"
 	"sync/atomic"
@@ -68,6 +69,14 @@ func (rc *RedisClient) OnDispose(ctx context.Context) error {
 	return nil
 }
 
+func (rc *RedisClient) HealthCheck(ctx context.Context) error {
+	if rc.closed.Load() {
+		return errors.New("redis client closed")
+	}
+
+	return rc.cli.Ping(ctx).Err()
+}
+
 func NewRedisClient(cfg RedisCfg) *RedisClient {
 	if len(cfg.Addresses) == 0 {
 		panic("addresses is required")
@@ -63,6 +63,10 @@ func (sc *SqlClient) OnDispose(ctx context.Context) error {
 	return nil
 }
 
+func (sc *SqlClient) HealthCheck(ctx context.Context) error {
+	return sc.conn.PingContext(ctx)
+}
+
 func NewSqlClient(cfg SqlCfg) (*SqlClient, error) {
 	var dsn = buildDsn(cfg)
 
@@ -10,6 +10,8 @@ type Config struct {
 	NacosCfg       NacosConfig       `yaml:"nacos-config"`
 	NacosCenterCfg NacosCenterConfig `yaml:"nacos-center-config"`
 	LogCfg         LogConfig         `yaml:"log-config"`
+	RpcTlsCfg      RpcTlsConfig      `yaml:"rpc-tls-config"`
+	RpcClientCfg   RpcClientConfig   `yaml:"rpc-client-config"`
 }
 
 func New(cfgPath string) (*Config, error) {
@@ -60,9 +62,27 @@ type NacosConfigConfig struct {
 }
 
 type NacosRegistryDiscoverConfig struct {
-	ClusterName              string `yaml:"cluster-name"`
-	GroupName                string `yaml:"group-name"`
-	DiscoverDialTimeoutMills int    `yaml:"discover-dial-timeout-mills"`
+	ClusterName             string `yaml:"cluster-name"`
+	GroupName               string `yaml:"group-name"`
+	ReadyTimeoutMills       int    `yaml:"ready-timeout-mills"`        // 注册前等待就绪的最长时间
+	ReadyCheckIntervalMills int    `yaml:"ready-check-interval-mills"` // 就绪检查轮询间隔
+}
+
+// rpc客户端配置, 与注册中心实现无关
+type RpcClientConfig struct {
+	LoadBalancer     string `yaml:"load-balancer"`      // 负载均衡策略, 见rclient.NewLoadBalancer
+	DialTimeoutMills int    `yaml:"dial-timeout-mills"` // 连接实例的超时, 默认2000
+}
+
+// rpc服务端和客户端共用的tls配置, 证书文件变化后自动重新加载
+type RpcTlsConfig struct {
+	Enabled             bool   `yaml:"enabled"`
+	CertFile            string `yaml:"cert-file"`
+	KeyFile             string `yaml:"key-file"`
+	CaFile              string `yaml:"ca-file"`               // 校验对端证书的CA, 为空时使用系统CA
+	ClientAuth          bool   `yaml:"client-auth"`           // mTLS, 服务端要求并校验客户端证书
+	ServerName          string `yaml:"server-name"`           // 客户端校验服务端证书时使用的名称, 为空时使用实例ip
+	ReloadIntervalMills int    `yaml:"reload-interval-mills"` // 检查证书文件变化的间隔
 }
 
 type LogConfig struct {
@@ -1,97 +1,32 @@
 package dnacos
 
 import (
-	"context"
-	"fmt"
-	"github.com/sweemingdow/gmicro_pkg/pkg/app"
 	"github.com/sweemingdow/gmicro_pkg/pkg/component/cnacos"
 	"github.com/sweemingdow/gmicro_pkg/pkg/config"
+	"github.com/sweemingdow/gmicro_pkg/pkg/decorate/dregdis"
+	"github.com/sweemingdow/gmicro_pkg/pkg/health"
 	"github.com/sweemingdow/gmicro_pkg/pkg/lifetime"
-	"github.com/sweemingdow/gmicro_pkg/pkg/mylog"
 	"github.com/sweemingdow/gmicro_pkg/pkg/regdis"
 	"github.com/sweemingdow/gmicro_pkg/pkg/regdis/extra/enacos"
-	"github.com/sweemingdow/gmicro_pkg/pkg/utils"
 )
 
-type nacosAutoRegistry struct {
-	registry    regdis.Registry
-	registryCfg config.NacosRegistryDiscoverConfig
-}
-
-func NewNacosAutoRegistry(registry regdis.Registry, registryCfg config.NacosRegistryDiscoverConfig) lifetime.LifeCycle {
-	return &nacosAutoRegistry{
-		registry:    registry,
-		registryCfg: registryCfg,
-	}
-}
-
-func (nar *nacosAutoRegistry) OnCreated(ec chan<- error) {
-	regCfg := nar.registryCfg
-
-	ta := app.GetTheApp()
-	var (
-		appName  = ta.GetAppName()
-		appId    = ta.GetAppId()
-		ip       = ta.GetLocalIp()
-		metadata = make(map[string]string)
-		httpPort = ta.GetHttpPort()
-		rpcPort  = ta.GetRpcPort()
+// readiness不为nil时, 就绪检查通过后才注册到nacos
+func NewNacosAutoRegistry(registry regdis.Registry, registryCfg config.NacosRegistryDiscoverConfig, readiness *health.Registry) lifetime.LifeCycle {
+	return dregdis.NewAutoRegistry(
+		registry,
+		NacosAutoRegistryConfig(registryCfg),
+		readiness,
 	)
-
-	metadata["app_id"] = appId
-
-	var regPort int
-	if httpPort != 0 {
-		metadata["http_port"] = utils.I2a(httpPort)
-		regPort = httpPort
-	}
-
-	if rpcPort != 0 {
-		if regPort == 0 {
-			regPort = rpcPort
-		}
-
-		metadata["rpc_port"] = utils.I2a(rpcPort)
-	}
-
-	rp := regdis.RegisterParam{
-		ServiceName: appName,
-		Addr:        fmt.Sprintf("%s:%d", ip, regPort),
-		Weight:      enacos.NacosDefaultWeight,
-		Metadata:    metadata,
-		Extra:       enacos.PkgRegisterExtraParam(regCfg.ClusterName, regCfg.GroupName),
-	}
-
-	err := nar.registry.Register(rp)
-	if err != nil {
-		ec <- err
-	}
 }
 
-func (nar *nacosAutoRegistry) OnDispose(_ context.Context) error {
-	regCfg := nar.registryCfg
-
-	ta := app.GetTheApp()
-	var (
-		appName = ta.GetAppName()
-		ip      = ta.GetLocalIp()
-	)
-
-	dp := regdis.DeregisterParam{
-		ServiceName: appName,
-		Addr:        fmt.Sprintf("%s:%d", ip, ta.GetHttpPort()),
-		Extra:       enacos.PkgDeregisterExtraParam(regCfg.ClusterName, regCfg.GroupName),
-	}
-
-	err := nar.registry.Deregister(dp)
-	if err != nil {
-		return err
+func NacosAutoRegistryConfig(registryCfg config.NacosRegistryDiscoverConfig) dregdis.AutoRegistryConfig {
+	return dregdis.AutoRegistryConfig{
+		Weight:                  enacos.NacosDefaultWeight,
+		RegisterExtra:           enacos.PkgRegisterExtraParam(registryCfg.ClusterName, registryCfg.GroupName),
+		DeregisterExtra:         enacos.PkgDeregisterExtraParam(registryCfg.ClusterName, registryCfg.GroupName),
+		ReadyTimeoutMills:       registryCfg.ReadyTimeoutMills,
+		ReadyCheckIntervalMills: registryCfg.ReadyCheckIntervalMills,
 	}
-
-	lg := mylog.AppLoggerWithStop()
-	lg.Info().Msg("instance had be deregister in nacos")
-
-	return nil
 }
 
 func ToNacosCfg(nc config.NacosConfig) cnacos.NacosCfg {
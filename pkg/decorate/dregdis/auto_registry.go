@@ -0,0 +1,201 @@
+package dregdis
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/sweemingdow/gmicro_pkg/pkg/app"
+	"github.com/sweemingdow/gmicro_pkg/pkg/health"
+	"github.com/sweemingdow/gmicro_pkg/pkg/lifetime"
+	"github.com/sweemingdow/gmicro_pkg/pkg/mylog"
+	"github.com/sweemingdow/gmicro_pkg/pkg/regdis"
+	"github.com/sweemingdow/gmicro_pkg/pkg/utils"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	DefaultWeight = 10.0
+
+	defaultReadyTimeoutMills       = 60_000
+	defaultReadyCheckIntervalMills = 500
+)
+
+type AutoRegistryConfig struct {
+	Weight                  float32
+	RegisterExtra           map[string]any // 注册时透传给具体实现的参数
+	DeregisterExtra         map[string]any // 注销时透传给具体实现的参数
+	Metadata                map[string]string
+	MetadataProvider        func() map[string]string // 注册时取值, 用于启动后期才确定的metadata, 如rpc编解码器
+	ReadyTimeoutMills       int                      // 注册前等待就绪的最长时间
+	ReadyCheckIntervalMills int                      // 就绪检查轮询间隔
+}
+
+// 与注册中心实现无关的自动注册/注销
+type autoRegistry struct {
+	registry   regdis.Registry
+	cfg        AutoRegistryConfig
+	readiness  *health.Registry
+	registered atomic.Bool
+	regAddr    string
+	cancelWait context.CancelFunc
+	disposed   bool // 已销毁后不再注册, 需持有mu
+	mu         sync.Mutex
+}
+
+// readiness不为nil时, 就绪检查通过后才注册
+func NewAutoRegistry(registry regdis.Registry, cfg AutoRegistryConfig, readiness *health.Registry) lifetime.LifeCycle {
+	return &autoRegistry{
+		registry:  registry,
+		cfg:       cfg,
+		readiness: readiness,
+	}
+}
+
+func (ar *autoRegistry) OnCreated(ec chan<- error) {
+	cfg := ar.cfg
+
+	ta := app.GetTheApp()
+	var (
+		appName  = ta.GetAppName()
+		appId    = ta.GetAppId()
+		ip       = ta.GetLocalIp()
+		metadata = make(map[string]string, len(cfg.Metadata)+3)
+		httpPort = ta.GetHttpPort()
+		rpcPort  = ta.GetRpcPort()
+	)
+
+	for k, v := range cfg.Metadata {
+		metadata[k] = v
+	}
+
+	if cfg.MetadataProvider != nil {
+		for k, v := range cfg.MetadataProvider() {
+			metadata[k] = v
+		}
+	}
+
+	metadata[regdis.MetadataAppId] = appId
+
+	var regPort int
+	if httpPort != 0 {
+		metadata[regdis.MetadataHttpPort] = utils.I2a(httpPort)
+		regPort = httpPort
+	}
+
+	if rpcPort != 0 {
+		if regPort == 0 {
+			regPort = rpcPort
+		}
+
+		metadata[regdis.MetadataRpcPort] = utils.I2a(rpcPort)
+	}
+
+	weight := cfg.Weight
+	if weight == 0 {
+		weight = DefaultWeight
+	}
+
+	ar.regAddr = fmt.Sprintf("%s:%d", ip, regPort)
+
+	rp := regdis.RegisterParam{
+		ServiceName: appName,
+		Addr:        ar.regAddr,
+		Weight:      weight,
+		Metadata:    metadata,
+		Extra:       cfg.RegisterExtra,
+	}
+
+	if ar.readiness == nil {
+		ar.register(rp, ec)
+		return
+	}
+
+	readyTimeoutMills := cfg.ReadyTimeoutMills
+	if readyTimeoutMills == 0 {
+		readyTimeoutMills = defaultReadyTimeoutMills
+	}
+
+	intervalMills := cfg.ReadyCheckIntervalMills
+	if intervalMills == 0 {
+		intervalMills = defaultReadyCheckIntervalMills
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(readyTimeoutMills)*time.Millisecond)
+
+	ar.mu.Lock()
+	ar.cancelWait = cancel
+	ar.mu.Unlock()
+
+	// 等待就绪后再注册, 避免流量进入未就绪的实例
+	go func() {
+		defer cancel()
+
+		lg := mylog.AppLoggerWithInit()
+
+		report, err := ar.readiness.WaitReady(ctx, time.Duration(intervalMills)*time.Millisecond)
+		if err != nil {
+			if errors.Is(err, context.Canceled) {
+				return
+			}
+
+			ec <- fmt.Errorf("instance was not ready within %dms, failed checks:%v", readyTimeoutMills, report.FailedNames())
+			return
+		}
+
+		lg.Debug().Msgf("instance is ready, register now, addr:%s", ar.regAddr)
+
+		ar.register(rp, ec)
+	}()
+}
+
+func (ar *autoRegistry) register(rp regdis.RegisterParam, ec chan<- error) {
+	ar.mu.Lock()
+	defer ar.mu.Unlock()
+
+	// 等待就绪期间已销毁, 此时注册将无人注销
+	if ar.disposed {
+		return
+	}
+
+	err := ar.registry.Register(rp)
+	if err != nil {
+		ec <- err
+		return
+	}
+
+	ar.registered.Store(true)
+}
+
+func (ar *autoRegistry) OnDispose(_ context.Context) error {
+	ar.mu.Lock()
+	defer ar.mu.Unlock()
+
+	ar.disposed = true
+
+	if ar.cancelWait != nil {
+		ar.cancelWait()
+	}
+
+	// 未注册成功则无需注销
+	if !ar.registered.CompareAndSwap(true, false) {
+		return nil
+	}
+
+	dp := regdis.DeregisterParam{
+		ServiceName: app.GetTheApp().GetAppName(),
+		Addr:        ar.regAddr,
+		Extra:       ar.cfg.DeregisterExtra,
+	}
+
+	err := ar.registry.Deregister(dp)
+	if err != nil {
+		return err
+	}
+
+	lg := mylog.AppLoggerWithStop()
+	lg.Info().Msg("instance had be deregister")
+
+	return nil
+}
@@ -0,0 +1,227 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	StatusUp   = "UP"
+	StatusDown = "DOWN"
+
+	defaultCheckTimeout = 2 * time.Second
+)
+
+var ErrShuttingDown = errors.New("application is shutting down")
+
+// Checker 组件可选实现, 用于上报自身健康状态
+type Checker interface {
+	HealthCheck(ctx context.Context) error
+}
+
+type CheckFunc func(ctx context.Context) error
+
+func (cf CheckFunc) HealthCheck(ctx context.Context) error {
+	return cf(ctx)
+}
+
+type CheckResult struct {
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+	TookMs int64  `json:"tookMs"`
+}
+
+type Report struct {
+	Status string                 `json:"status"`
+	Checks map[string]CheckResult `json:"checks,omitempty"`
+}
+
+func (r Report) IsUp() bool {
+	return r.Status == StatusUp
+}
+
+// FailedNames 失败的检查项名称(有序)
+func (r Report) FailedNames() []string {
+	names := make([]string, 0)
+	for name, res := range r.Checks {
+		if res.Status != StatusUp {
+			names = append(names, name)
+		}
+	}
+
+	sort.Strings(names)
+	return names
+}
+
+// Registry 健康检查注册表
+// live: 进程是否存活(默认无检查项), ready: 是否可以接收流量
+type Registry struct {
+	rwMu          sync.RWMutex
+	liveCheckers  map[string]Checker
+	readyCheckers map[string]Checker
+	checkTimeout  time.Duration
+	shutdown      atomic.Bool
+}
+
+func NewRegistry(checkTimeout time.Duration) *Registry {
+	if checkTimeout <= 0 {
+		checkTimeout = defaultCheckTimeout
+	}
+
+	return &Registry{
+		liveCheckers:  make(map[string]Checker),
+		readyCheckers: make(map[string]Checker),
+		checkTimeout:  checkTimeout,
+	}
+}
+
+// RegisterReady 注册就绪检查项, 同名覆盖
+func (r *Registry) RegisterReady(name string, c Checker) {
+	r.rwMu.Lock()
+	r.readyCheckers[name] = c
+	r.rwMu.Unlock()
+}
+
+// RegisterLive 注册存活检查项, 同名覆盖
+func (r *Registry) RegisterLive(name string, c Checker) {
+	r.rwMu.Lock()
+	r.liveCheckers[name] = c
+	r.rwMu.Unlock()
+}
+
+// RegisterIfChecker 若val实现了Checker, 注册为就绪检查项
+func (r *Registry) RegisterIfChecker(name string, val any) bool {
+	c, ok := val.(Checker)
+	if !ok {
+		return false
+	}
+
+	r.RegisterReady(name, c)
+	return true
+}
+
+func (r *Registry) Unregister(name string) {
+	r.rwMu.Lock()
+	delete(r.readyCheckers, name)
+	delete(r.liveCheckers, name)
+	r.rwMu.Unlock()
+}
+
+// MarkShutdown 停机开始时调用, 之后ready始终为DOWN
+func (r *Registry) MarkShutdown() {
+	r.shutdown.Store(true)
+}
+
+func (r *Registry) Live(ctx context.Context) Report {
+	r.rwMu.RLock()
+	checkers := copyCheckers(r.liveCheckers)
+	r.rwMu.RUnlock()
+
+	return r.runChecks(ctx, checkers)
+}
+
+func (r *Registry) Ready(ctx context.Context) Report {
+	if r.shutdown.Load() {
+		return Report{
+			Status: StatusDown,
+			Checks: map[string]CheckResult{
+				"shutdown": {Status: StatusDown, Error: ErrShuttingDown.Error()},
+			},
+		}
+	}
+
+	r.rwMu.RLock()
+	checkers := copyCheckers(r.readyCheckers)
+	r.rwMu.RUnlock()
+
+	return r.runChecks(ctx, checkers)
+}
+
+// WaitReady 以interval轮询直到就绪或ctx结束
+func (r *Registry) WaitReady(ctx context.Context, interval time.Duration) (Report, error) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		report := r.Ready(ctx)
+		if report.IsUp() {
+			return report, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return report, ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// 并发执行所有检查项, 任一失败则整体DOWN
+func (r *Registry) runChecks(ctx context.Context, checkers map[string]Checker) Report {
+	report := Report{
+		Status: StatusUp,
+		Checks: make(map[string]CheckResult, len(checkers)),
+	}
+
+	if len(checkers) == 0 {
+		return report
+	}
+
+	var (
+		mu sync.Mutex
+		wg sync.WaitGroup
+	)
+
+	wg.Add(len(checkers))
+	for name, c := range checkers {
+		go func(name string, c Checker) {
+			defer wg.Done()
+
+			res := runCheck(ctx, c, r.checkTimeout)
+
+			mu.Lock()
+			report.Checks[name] = res
+			if res.Status != StatusUp {
+				report.Status = StatusDown
+			}
+			mu.Unlock()
+		}(name, c)
+	}
+
+	wg.Wait()
+
+	return report
+}
+
+func runCheck(ctx context.Context, c Checker, timeout time.Duration) (res CheckResult) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	start := time.Now()
+	defer func() {
+		if rv := recover(); rv != nil {
+			res = CheckResult{Status: StatusDown, Error: "health check panic"}
+		}
+
+		res.TookMs = time.Since(start).Milliseconds()
+	}()
+
+	if err := c.HealthCheck(ctx); err != nil {
+		return CheckResult{Status: StatusDown, Error: err.Error()}
+	}
+
+	return CheckResult{Status: StatusUp}
+}
+
+func copyCheckers(src map[string]Checker) map[string]Checker {
+	dst := make(map[string]Checker, len(src))
+	for k, v := range src {
+		dst[k] = v
+	}
+
+	return dst
+}
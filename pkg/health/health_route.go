@@ -0,0 +1,55 @@
+package health
+
+import (
+	"context"
+	"github.com/gofiber/fiber/v2"
+	"github.com/lesismal/arpc"
+	"github.com/sweemingdow/gmicro_pkg/pkg/mylog"
+	"github.com/sweemingdow/gmicro_pkg/pkg/server/srpc"
+)
+
+const (
+	LivePath  = "/health/live"
+	ReadyPath = "/health/ready"
+)
+
+func BindFiber(fa *fiber.App, r *Registry) {
+	fa.Get(LivePath, fiberHandler(r.Live))
+	fa.Get(ReadyPath, fiberHandler(r.Ready))
+}
+
+func BindArpc(srv *arpc.Server, r *Registry) {
+	srv.Handler.Handle(LivePath, arpcHandler(r.Live))
+	srv.Handler.Handle(ReadyPath, arpcHandler(r.Ready))
+}
+
+type reportFunc func(ctx context.Context) Report
+
+func fiberHandler(rf reportFunc) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		report := rf(c.UserContext())
+
+		if report.IsUp() {
+			c.Status(fiber.StatusOK)
+		} else {
+			c.Status(fiber.StatusServiceUnavailable)
+		}
+
+		return c.JSON(&report)
+	}
+}
+
+func arpcHandler(rf reportFunc) arpc.HandlerFunc {
+	return func(c *arpc.Context) {
+		// 检查受调用方的超时约束
+		ctx, cancel := srpc.ContextFrom(c)
+		defer cancel()
+
+		report := rf(ctx)
+
+		if err := c.Write(&report); err != nil {
+			lg := mylog.AppLoggerWithWriteBack()
+			lg.Error().Stack().Err(err).Msg("write back health report failed")
+		}
+	}
+}
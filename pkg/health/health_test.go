@@ -0,0 +1,117 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"github.com/lesismal/arpc"
+	"github.com/sweemingdow/gmicro_pkg/pkg/server/srpc"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestReadyAggregatesChecks(t *testing.T) {
+	r := NewRegistry(time.Second)
+
+	r.RegisterReady("redis", CheckFunc(func(ctx context.Context) error { return nil }))
+	if report := r.Ready(context.Background()); !report.IsUp() {
+		t.Fatalf("expected UP, got: %+v", report)
+	}
+
+	r.RegisterReady("sql", CheckFunc(func(ctx context.Context) error { return errors.New("ping failed") }))
+	report := r.Ready(context.Background())
+	if report.IsUp() {
+		t.Fatalf("expected DOWN, got: %+v", report)
+	}
+
+	if failed := report.FailedNames(); len(failed) != 1 || failed[0] != "sql" {
+		t.Fatalf("unexpected failed checks: %v", failed)
+	}
+}
+
+func TestReadyDownAfterShutdown(t *testing.T) {
+	r := NewRegistry(time.Second)
+	r.MarkShutdown()
+
+	if r.Ready(context.Background()).IsUp() {
+		t.Fatal("expected DOWN after shutdown")
+	}
+
+	if !r.Live(context.Background()).IsUp() {
+		t.Fatal("liveness should not depend on shutdown")
+	}
+}
+
+func TestWaitReady(t *testing.T) {
+	r := NewRegistry(time.Second)
+
+	readyAt := time.Now().Add(30 * time.Millisecond)
+	r.RegisterReady("server", CheckFunc(func(ctx context.Context) error {
+		if time.Now().Before(readyAt) {
+			return errors.New("not listening")
+		}
+		return nil
+	}))
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	if _, err := r.WaitReady(ctx, 5*time.Millisecond); err != nil {
+		t.Fatalf("wait ready failed: %v", err)
+	}
+}
+
+// arpc就绪检查使用调用方传递的超时
+func TestArpcReadyUsesCallerDeadline(t *testing.T) {
+	r := NewRegistry(time.Minute)
+
+	var remain atomic.Int64
+	r.RegisterReady("server", CheckFunc(func(ctx context.Context) error {
+		dl, ok := ctx.Deadline()
+		if !ok {
+			return errors.New("no deadline")
+		}
+
+		remain.Store(int64(time.Until(dl)))
+		return nil
+	}))
+
+	srv := arpc.NewServer()
+	srv.Handler.UseCoder(srpc.MetaCoder{})
+	BindArpc(srv, r)
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	go func() {
+		_ = srv.Serve(ln)
+	}()
+	defer ln.Close()
+
+	cli, err := arpc.NewClient(func() (net.Conn, error) {
+		return net.Dial("tcp", ln.Addr().String())
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cli.Stop()
+
+	cli.Handler.UseCoder(srpc.MetaCoder{})
+
+	var report Report
+	values := map[interface{}]interface{}{srpc.MetaKeyTimeoutMills: "500"}
+	if err = cli.Call(ReadyPath, nil, &report, time.Second, values); err != nil {
+		t.Fatal(err)
+	}
+
+	if !report.IsUp() {
+		t.Fatalf("expected UP, got: %+v", report)
+	}
+
+	if d := time.Duration(remain.Load()); d <= 0 || d > 500*time.Millisecond {
+		t.Fatalf("expected caller deadline, remain: %v", d)
+	}
+}
@@ -3,6 +3,8 @@ package lifetime
 import (
 	"context"
 	"errors"
+	"fmt"
+	"strings"
 	"sync"
 	"sync/atomic"
 )
@@ -21,18 +23,37 @@ type LifeCycle interface {
 	Disposable
 }
 
+// 可选依赖的前缀, 被依赖方不存在时忽略, 存在时保证顺序
+const optionalDepPrefix = "?"
+
+// Optional 包装一个可选依赖的key
+func Optional(key string) string {
+	return optionalDepPrefix + key
+}
+
+func parseDep(dep string) (string, bool) {
+	if strings.HasPrefix(dep, optionalDepPrefix) {
+		return dep[len(optionalDepPrefix):], true
+	}
+
+	return dep, false
+}
+
 type lifeCycleEntry struct {
-	key string
-	lc  LifeCycle
+	key     string
+	lc      LifeCycle
+	deps    []string
+	started bool
 }
 
 type AppFinalizer struct {
-	// stack impl
-	entries  []lifeCycleEntry
+	// 依赖图, entries保持收集顺序
+	entries  []*lifeCycleEntry
 	mapIndex map[string]int
 
 	mu       sync.Mutex
 	ec       chan<- error
+	sealed   bool
 	released atomic.Bool
 }
 
@@ -53,17 +74,23 @@ func GetAppFinalizer() *AppFinalizer {
 
 func NewFinalizer(ec chan<- error) *AppFinalizer {
 	once.Do(func() {
-		finalizer = &AppFinalizer{
-			entries:  make([]lifeCycleEntry, 0),
-			mapIndex: make(map[string]int),
-			ec:       ec,
-		}
+		finalizer = newAppFinalizer(ec)
 	})
 
 	return finalizer
 }
 
-func (cl *AppFinalizer) Collect(key string, lc LifeCycle) {
+func newAppFinalizer(ec chan<- error) *AppFinalizer {
+	return &AppFinalizer{
+		entries:  make([]*lifeCycleEntry, 0),
+		mapIndex: make(map[string]int),
+		ec:       ec,
+	}
+}
+
+// Collect 收集组件, deps为依赖的组件key(可用Optional包装)
+// 所有依赖都已启动时立即OnCreated, 否则等待依赖被收集后再按拓扑顺序启动
+func (cl *AppFinalizer) Collect(key string, lc LifeCycle, deps ...string) {
 	cl.mu.Lock()
 	defer cl.mu.Unlock()
 
@@ -71,14 +98,10 @@ func (cl *AppFinalizer) Collect(key string, lc LifeCycle) {
 		return
 	}
 
-	cl.entries = append(cl.entries, lifeCycleEntry{key: key, lc: lc})
-	cl.mapIndex[key] = len(cl.entries) - 1 // 记录索引
-
-	// 初始化
-	lc.OnCreated(cl.ec)
+	cl.add(key, lc, deps)
 }
 
-func (cl *AppFinalizer) CollectLazy(key string, lf LazyFunc) {
+func (cl *AppFinalizer) CollectLazy(key string, lf LazyFunc, deps ...string) {
 	cl.mu.Lock()
 	defer cl.mu.Unlock()
 
@@ -91,14 +114,162 @@ func (cl *AppFinalizer) CollectLazy(key string, lf LazyFunc) {
 		return
 	}
 
-	cl.entries = append(cl.entries, lifeCycleEntry{key: key, lc: lc})
-	cl.mapIndex[key] = len(cl.entries) - 1
+	cl.add(key, lc, deps)
+}
+
+// Contains 组件是否已被收集(不论是否已启动)
+func (cl *AppFinalizer) Contains(key string) bool {
+	cl.mu.Lock()
+	_, ok := cl.mapIndex[key]
+	cl.mu.Unlock()
 
-	lc.OnCreated(cl.ec)
+	return ok
 }
 
-// warning: 释放是栈逻辑: LIFO
-// 确保先释放的是最后搜集的
+// Seal 启动阶段结束时调用: 忽略缺失的可选依赖并启动剩余组件,
+// 存在循环依赖或缺失必需依赖时返回error
+func (cl *AppFinalizer) Seal() error {
+	cl.mu.Lock()
+	defer cl.mu.Unlock()
+
+	cl.sealed = true
+	cl.startReady()
+
+	pending := make(map[string]*lifeCycleEntry)
+	for _, entry := range cl.entries {
+		if !entry.started {
+			pending[entry.key] = entry
+		}
+	}
+
+	if len(pending) == 0 {
+		return nil
+	}
+
+	if cycle := cl.findCycle(pending); len(cycle) > 0 {
+		return fmt.Errorf("lifecycle dependency cycle detected: %s", strings.Join(cycle, " -> "))
+	}
+
+	var errs []error
+	for _, entry := range cl.entries {
+		if entry.started {
+			continue
+		}
+
+		for _, dep := range entry.deps {
+			depKey, optional := parseDep(dep)
+			if _, ok := cl.mapIndex[depKey]; !ok && !optional {
+				errs = append(errs, fmt.Errorf("lifecycle %s depends on %s, but it was never collected", entry.key, depKey))
+			}
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+func (cl *AppFinalizer) add(key string, lc LifeCycle, deps []string) {
+	cl.entries = append(cl.entries, &lifeCycleEntry{key: key, lc: lc, deps: deps})
+	cl.mapIndex[key] = len(cl.entries) - 1 // 记录索引
+
+	cl.startReady()
+}
+
+// 反复启动依赖已就绪的组件, 直到没有进展
+func (cl *AppFinalizer) startReady() {
+	for {
+		progressed := false
+
+		for _, entry := range cl.entries {
+			if entry.started || !cl.depsReady(entry) {
+				continue
+			}
+
+			entry.started = true
+			progressed = true
+
+			// 初始化
+			entry.lc.OnCreated(cl.ec)
+		}
+
+		if !progressed {
+			return
+		}
+	}
+}
+
+func (cl *AppFinalizer) depsReady(entry *lifeCycleEntry) bool {
+	for _, dep := range entry.deps {
+		depKey, optional := parseDep(dep)
+
+		idx, ok := cl.mapIndex[depKey]
+		if !ok {
+			if optional && cl.sealed {
+				continue
+			}
+
+			return false
+		}
+
+		if !cl.entries[idx].started {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (cl *AppFinalizer) findCycle(pending map[string]*lifeCycleEntry) []string {
+	const (
+		visiting = 1
+		visited  = 2
+	)
+
+	state := make(map[string]int, len(pending))
+	var path []string
+
+	var visit func(key string) []string
+	visit = func(key string) []string {
+		state[key] = visiting
+		path = append(path, key)
+
+		for _, dep := range pending[key].deps {
+			depKey, _ := parseDep(dep)
+			if _, ok := pending[depKey]; !ok {
+				continue
+			}
+
+			switch state[depKey] {
+			case visiting:
+				for i, k := range path {
+					if k == depKey {
+						return append(append([]string{}, path[i:]...), depKey)
+					}
+				}
+			case 0:
+				if cycle := visit(depKey); len(cycle) > 0 {
+					return cycle
+				}
+			}
+		}
+
+		path = path[:len(path)-1]
+		state[key] = visited
+		return nil
+	}
+
+	for _, entry := range cl.entries {
+		if _, ok := pending[entry.key]; ok && state[entry.key] == 0 {
+			if cycle := visit(entry.key); len(cycle) > 0 {
+				return cycle
+			}
+		}
+	}
+
+	return nil
+}
+
+// warning: 释放按依赖的逆序进行
+// 组件在所有依赖它的组件释放完成后才会释放, 互不依赖的分支并行释放
 func (cl *AppFinalizer) Release(ctx context.Context) ([]error, bool) {
 	if !cl.released.CompareAndSwap(false, true) {
 		return nil, true
@@ -109,39 +280,93 @@ func (cl *AppFinalizer) Release(ctx context.Context) ([]error, bool) {
 	}
 
 	cl.mu.Lock()
-	entries := cl.entries
+	entries := make([]*lifeCycleEntry, 0, len(cl.entries))
+	for _, entry := range cl.entries {
+		// 未启动的组件无需释放
+		if entry.started {
+			entries = append(entries, entry)
+		}
+	}
 	cl.mu.Unlock()
 
 	if len(entries) == 0 {
 		return nil, true
 	}
 
-	var errs []error
-	var aborted bool
-
-	// LIFO (后进先出) 清理顺序
-	for i := len(entries) - 1; i >= 0; i-- {
-		lc := entries[i].lc
-
-		select {
-		case <-ctx.Done():
-			aborted = true
-			errs = append(errs, ctx.Err())
-			return errs, aborted
-		default:
-		}
+	// key -> 依赖它的组件
+	dependents := make(map[string][]string, len(entries))
+	done := make(map[string]chan struct{}, len(entries))
+	for _, entry := range entries {
+		done[entry.key] = make(chan struct{})
+	}
 
-		if err := lc.OnDispose(ctx); err != nil {
-			if errors.Is(err, context.DeadlineExceeded) ||
-				errors.Is(err, context.Canceled) {
-				aborted = true
-				errs = append(errs, err)
-				return errs, aborted
+	for _, entry := range entries {
+		for _, dep := range entry.deps {
+			depKey, _ := parseDep(dep)
+			if _, ok := done[depKey]; ok {
+				dependents[depKey] = append(dependents[depKey], entry.key)
 			}
+		}
+	}
 
+	var (
+		mu      sync.Mutex
+		errs    []error
+		aborted atomic.Bool
+		wg      sync.WaitGroup
+	)
+
+	abort := func(err error) {
+		mu.Lock()
+		if !aborted.Load() {
+			aborted.Store(true)
 			errs = append(errs, err)
 		}
+		mu.Unlock()
 	}
 
-	return errs, aborted
+	wg.Add(len(entries))
+	for _, entry := range entries {
+		go func(entry *lifeCycleEntry) {
+			defer wg.Done()
+			defer close(done[entry.key])
+
+			// 等待所有依赖方先释放
+			for _, dependent := range dependents[entry.key] {
+				select {
+				case <-done[dependent]:
+				case <-ctx.Done():
+					abort(ctx.Err())
+					return
+				}
+			}
+
+			if aborted.Load() {
+				return
+			}
+
+			select {
+			case <-ctx.Done():
+				abort(ctx.Err())
+				return
+			default:
+			}
+
+			if err := entry.lc.OnDispose(ctx); err != nil {
+				if errors.Is(err, context.DeadlineExceeded) ||
+					errors.Is(err, context.Canceled) {
+					abort(err)
+					return
+				}
+
+				mu.Lock()
+				errs = append(errs, err)
+				mu.Unlock()
+			}
+		}(entry)
+	}
+
+	wg.Wait()
+
+	return errs, aborted.Load()
 }
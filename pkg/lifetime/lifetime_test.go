@@ -0,0 +1,166 @@
+package lifetime
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type recorder struct {
+	mu     sync.Mutex
+	events []string
+}
+
+func (r *recorder) add(ev string) {
+	r.mu.Lock()
+	r.events = append(r.events, ev)
+	r.mu.Unlock()
+}
+
+func (r *recorder) indexOf(ev string) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i, e := range r.events {
+		if e == ev {
+			return i
+		}
+	}
+
+	return -1
+}
+
+type recordLc struct {
+	key       string
+	rec       *recorder
+	onDispose func()
+}
+
+func (rl *recordLc) OnCreated(_ chan<- error) {
+	rl.rec.add("start:" + rl.key)
+}
+
+func (rl *recordLc) OnDispose(_ context.Context) error {
+	if rl.onDispose != nil {
+		rl.onDispose()
+	}
+	rl.rec.add("stop:" + rl.key)
+	return nil
+}
+
+func TestCollectStartsInDependencyOrder(t *testing.T) {
+	rec := &recorder{}
+	af := newAppFinalizer(make(chan error, 1))
+
+	af.Collect("registry", &recordLc{key: "registry", rec: rec}, "client", Optional("server"))
+	af.Collect("server", &recordLc{key: "server", rec: rec}, "client")
+	af.Collect("client", &recordLc{key: "client", rec: rec})
+
+	if err := af.Seal(); err != nil {
+		t.Fatalf("seal failed: %v", err)
+	}
+
+	if !(rec.indexOf("start:client") < rec.indexOf("start:server") &&
+		rec.indexOf("start:server") < rec.indexOf("start:registry")) {
+		t.Fatalf("unexpected start order: %v", rec.events)
+	}
+}
+
+func TestSealIgnoresMissingOptionalDeps(t *testing.T) {
+	rec := &recorder{}
+	af := newAppFinalizer(make(chan error, 1))
+
+	af.Collect("registry", &recordLc{key: "registry", rec: rec}, Optional("http_server"))
+
+	if rec.indexOf("start:registry") != -1 {
+		t.Fatal("registry should wait for optional dependency before seal")
+	}
+
+	if err := af.Seal(); err != nil {
+		t.Fatalf("seal failed: %v", err)
+	}
+
+	if rec.indexOf("start:registry") == -1 {
+		t.Fatal("registry should be started after seal")
+	}
+}
+
+func TestSealRejectsCycle(t *testing.T) {
+	rec := &recorder{}
+	af := newAppFinalizer(make(chan error, 1))
+
+	af.Collect("a", &recordLc{key: "a", rec: rec}, "b")
+	af.Collect("b", &recordLc{key: "b", rec: rec}, "c")
+	af.Collect("c", &recordLc{key: "c", rec: rec}, "a")
+
+	err := af.Seal()
+	if err == nil || !strings.Contains(err.Error(), "cycle") {
+		t.Fatalf("expected cycle error, got: %v", err)
+	}
+}
+
+func TestSealRejectsMissingRequiredDep(t *testing.T) {
+	af := newAppFinalizer(make(chan error, 1))
+
+	af.Collect("a", &recordLc{key: "a", rec: &recorder{}}, "missing")
+
+	if err := af.Seal(); err == nil {
+		t.Fatal("expected missing dependency error")
+	}
+}
+
+func TestReleaseInReverseDependencyOrder(t *testing.T) {
+	rec := &recorder{}
+	af := newAppFinalizer(make(chan error, 1))
+
+	// independent branches are released in parallel: each one waits until the other has started
+	var (
+		httpStopping = make(chan struct{})
+		rpcStopping  = make(chan struct{})
+		sequential   atomic.Bool
+	)
+
+	rendezvous := func(self, other chan struct{}) func() {
+		return func() {
+			close(self)
+
+			select {
+			case <-other:
+			case <-time.After(3 * time.Second):
+				sequential.Store(true)
+			}
+		}
+	}
+
+	// client <- http_server <- registry, client <- rpc_server <- registry
+	af.Collect("client", &recordLc{key: "client", rec: rec})
+	af.Collect("http_server", &recordLc{key: "http_server", rec: rec, onDispose: rendezvous(httpStopping, rpcStopping)}, "client")
+	af.Collect("rpc_server", &recordLc{key: "rpc_server", rec: rec, onDispose: rendezvous(rpcStopping, httpStopping)}, "client")
+	af.Collect("registry", &recordLc{key: "registry", rec: rec}, "http_server", "rpc_server")
+
+	if err := af.Seal(); err != nil {
+		t.Fatalf("seal failed: %v", err)
+	}
+
+	errs, aborted := af.Release(context.Background())
+
+	if len(errs) > 0 || aborted {
+		t.Fatalf("release failed, errs:%v, aborted:%t", errs, aborted)
+	}
+
+	registry := rec.indexOf("stop:registry")
+	http := rec.indexOf("stop:http_server")
+	rpc := rec.indexOf("stop:rpc_server")
+	client := rec.indexOf("stop:client")
+
+	if !(registry < http && registry < rpc && http < client && rpc < client) {
+		t.Fatalf("unexpected stop order: %v", rec.events)
+	}
+
+	if sequential.Load() {
+		t.Fatal("independent branches should be released in parallel")
+	}
+}
@@ -75,6 +75,10 @@ func AppLoggerWithRpc() zerolog.Logger {
 	return AttachMarker("rpc_call", AppLogger())
 }
 
+func AppLoggerWithAccess() zerolog.Logger {
+	return AttachMarker("access", AppLogger())
+}
+
 func AppLoggerWithBind() zerolog.Logger {
 	return AttachMarker("bind", AppLogger())
 }
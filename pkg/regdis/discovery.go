@@ -3,6 +3,7 @@ package regdis
 import (
 	"fmt"
 	"github.com/sweemingdow/gmicro_pkg/pkg/parser/json"
+	"strconv"
 )
 
 type DiscoverType uint8
@@ -12,6 +13,15 @@ const (
 	ForRpc  DiscoverType = 2
 )
 
+const (
+	MetadataHttpPort = "http_port"
+	MetadataRpcPort  = "rpc_port"
+	MetadataAppId    = "app_id"
+
+	// rpc服务端接受的编解码器, 逗号分隔, 首个为响应使用的编码, 未设置时为json
+	MetadataRpcCodecs = "rpc_codecs"
+)
+
 type DiscoverParam struct {
 	ServiceName string
 	DisType     DiscoverType
@@ -43,6 +53,27 @@ type Discovery interface {
 	Unwatch(dp DiscoverParam, uwf UnwatchFunc) error
 }
 
+// ResolvePort 按发现类型从metadata中取对应端口, 取不到时使用注册端口
+func ResolvePort(port int, md map[string]string, disType DiscoverType) int {
+	var key string
+	if disType == ForHttp {
+		key = MetadataHttpPort
+	} else if disType == ForRpc {
+		key = MetadataRpcPort
+	}
+
+	if key == "" {
+		return port
+	}
+
+	p, err := strconv.Atoi(md[key])
+	if err != nil {
+		return port
+	}
+
+	return p
+}
+
 func PrettyOutput(instances []*Instance) string {
 	var output string
 	data, err := json.Fmt(&instances)
@@ -0,0 +1,149 @@
+package disetcd
+
+import (
+	"context"
+	"fmt"
+	"github.com/sweemingdow/gmicro_pkg/pkg/parser/json"
+	"github.com/sweemingdow/gmicro_pkg/pkg/regdis"
+	"github.com/sweemingdow/gmicro_pkg/pkg/regdis/extra/eetcd"
+	clientv3 "go.etcd.io/etcd/client/v3"
+	"sync"
+	"time"
+)
+
+const (
+	defaultOpTimeout = 3 * time.Second
+	rewatchInterval  = time.Second
+)
+
+type EtcdDiscoveryConfig struct {
+	RootPrefix string // key前缀, 需与注册端一致
+	OpTimeout  time.Duration
+}
+
+type etcdDiscovery struct {
+	cli     *clientv3.Client
+	cfg     EtcdDiscoveryConfig
+	mu      sync.Mutex
+	watches map[string]context.CancelFunc
+}
+
+func NewEtcdDiscovery(cli *clientv3.Client, cfg EtcdDiscoveryConfig) regdis.Discovery {
+	if cfg.OpTimeout <= 0 {
+		cfg.OpTimeout = defaultOpTimeout
+	}
+
+	return &etcdDiscovery{
+		cli:     cli,
+		cfg:     cfg,
+		watches: make(map[string]context.CancelFunc),
+	}
+}
+
+func (ed *etcdDiscovery) Discover(dp regdis.DiscoverParam) ([]*regdis.Instance, error) {
+	instances, _, err := ed.discover(dp)
+	return instances, err
+}
+
+// 同时返回读取时的版本, 用于重新监听
+func (ed *etcdDiscovery) discover(dp regdis.DiscoverParam) ([]*regdis.Instance, int64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), ed.cfg.OpTimeout)
+	defer cancel()
+
+	resp, err := ed.cli.Get(ctx, eetcd.ServicePrefix(ed.cfg.RootPrefix, dp.ServiceName), clientv3.WithPrefix())
+	if err != nil {
+		return nil, 0, err
+	}
+
+	instances := make([]*regdis.Instance, 0, len(resp.Kvs))
+	for _, kv := range resp.Kvs {
+		var ins regdis.Instance
+		if err = json.Parse(kv.Value, &ins); err != nil {
+			return nil, 0, fmt.Errorf("parse instance from etcd failed, key:%s, err:%w", kv.Key, err)
+		}
+
+		ins.Port = regdis.ResolvePort(ins.Port, ins.Metadata, dp.DisType)
+		instances = append(instances, &ins)
+	}
+
+	return instances, resp.Header.Revision, nil
+}
+
+// 前缀监听, 任意实例变化时回调当前全量实例
+func (ed *etcdDiscovery) Watch(dp regdis.DiscoverParam, wf regdis.WatchFunc) error {
+	wk := watchKey(dp)
+
+	ed.mu.Lock()
+	defer ed.mu.Unlock()
+
+	if _, ok := ed.watches[wk]; ok {
+		return fmt.Errorf("service:%s already watched", dp.ServiceName)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	ed.watches[wk] = cancel
+
+	go ed.watchLoop(ctx, dp, wf)
+
+	return nil
+}
+
+// 失去leader(WithRequireLeader)或版本被压缩时watch通道会关闭
+// 关闭后回调一次全量实例, 并从读取时的版本继续监听, 直到Unwatch
+func (ed *etcdDiscovery) watchLoop(ctx context.Context, dp regdis.DiscoverParam, wf regdis.WatchFunc) {
+	prefix := eetcd.ServicePrefix(ed.cfg.RootPrefix, dp.ServiceName)
+
+	var rev int64
+	for {
+		opts := []clientv3.OpOption{clientv3.WithPrefix()}
+		if rev > 0 {
+			opts = append(opts, clientv3.WithRev(rev+1))
+		}
+
+		for resp := range ed.cli.Watch(clientv3.WithRequireLeader(ctx), prefix, opts...) {
+			if err := resp.Err(); err != nil {
+				wf(nil, err)
+				continue
+			}
+
+			rev = resp.Header.Revision
+			wf(ed.Discover(dp))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(rewatchInterval):
+		}
+
+		// 中断期间的变化(含已被压缩的版本)以全量实例补上
+		instances, discoverRev, err := ed.discover(dp)
+		if err == nil {
+			rev = discoverRev
+		}
+		wf(instances, err)
+	}
+}
+
+func (ed *etcdDiscovery) Unwatch(dp regdis.DiscoverParam, uwf regdis.UnwatchFunc) error {
+	wk := watchKey(dp)
+
+	ed.mu.Lock()
+	cancel, ok := ed.watches[wk]
+	delete(ed.watches, wk)
+	ed.mu.Unlock()
+
+	if ok {
+		cancel()
+	}
+
+	if uwf != nil {
+		uwf(nil)
+	}
+
+	return nil
+}
+
+func watchKey(dp regdis.DiscoverParam) string {
+	return fmt.Sprintf("%s#%d", dp.ServiceName, dp.DisType)
+}
@@ -0,0 +1,261 @@
+package disetcd
+
+import (
+	"context"
+	"github.com/sweemingdow/gmicro_pkg/pkg/component/cetcd/cetcdtest"
+	"github.com/sweemingdow/gmicro_pkg/pkg/parser/json"
+	"github.com/sweemingdow/gmicro_pkg/pkg/regdis"
+	"github.com/sweemingdow/gmicro_pkg/pkg/regdis/regetcd"
+	"go.etcd.io/etcd/api/v3/etcdserverpb"
+	"go.etcd.io/etcd/api/v3/mvccpb"
+	clientv3 "go.etcd.io/etcd/client/v3"
+	"sync"
+	"testing"
+	"time"
+)
+
+// 内存中的KV和Watcher, 由测试控制watch通道的关闭
+type fakeEtcd struct {
+	clientv3.KV
+	clientv3.Watcher
+
+	mu        sync.Mutex
+	kvs       []*mvccpb.KeyValue
+	rev       int64
+	watchRevs []int64
+
+	watches chan *fakeWatch
+}
+
+type fakeWatch struct {
+	ch   chan clientv3.WatchResponse
+	once sync.Once
+}
+
+func (fw *fakeWatch) close() {
+	fw.once.Do(func() {
+		close(fw.ch)
+	})
+}
+
+func newFakeEtcd() (*fakeEtcd, *clientv3.Client) {
+	fe := &fakeEtcd{watches: make(chan *fakeWatch, 4)}
+
+	cli := clientv3.NewCtxClient(context.Background())
+	cli.KV = fe
+	cli.Watcher = fe
+
+	return fe, cli
+}
+
+func (fe *fakeEtcd) put(t *testing.T, instances ...*regdis.Instance) {
+	fe.mu.Lock()
+	defer fe.mu.Unlock()
+
+	fe.rev++
+	fe.kvs = fe.kvs[:0]
+	for _, ins := range instances {
+		val, err := json.Fmt(ins)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		fe.kvs = append(fe.kvs, &mvccpb.KeyValue{Key: []byte(ins.InstanceId), Value: val, ModRevision: fe.rev})
+	}
+}
+
+func (fe *fakeEtcd) Get(_ context.Context, _ string, _ ...clientv3.OpOption) (*clientv3.GetResponse, error) {
+	fe.mu.Lock()
+	defer fe.mu.Unlock()
+
+	return &clientv3.GetResponse{
+		Header: &etcdserverpb.ResponseHeader{Revision: fe.rev},
+		Kvs:    append([]*mvccpb.KeyValue(nil), fe.kvs...),
+	}, nil
+}
+
+func (fe *fakeEtcd) Watch(ctx context.Context, key string, opts ...clientv3.OpOption) clientv3.WatchChan {
+	fe.mu.Lock()
+	fe.watchRevs = append(fe.watchRevs, clientv3.OpGet(key, opts...).Rev())
+	fe.mu.Unlock()
+
+	fw := &fakeWatch{ch: make(chan clientv3.WatchResponse, 1)}
+	go func() {
+		<-ctx.Done()
+		fw.close()
+	}()
+
+	fe.watches <- fw
+	return fw.ch
+}
+
+func (fe *fakeEtcd) notify(fw *fakeWatch) {
+	fe.mu.Lock()
+	rev := fe.rev
+	fe.mu.Unlock()
+
+	fw.ch <- clientv3.WatchResponse{Header: etcdserverpb.ResponseHeader{Revision: rev}}
+}
+
+func (fe *fakeEtcd) nextWatch(t *testing.T) *fakeWatch {
+	select {
+	case fw := <-fe.watches:
+		return fw
+	case <-time.After(3 * time.Second):
+		t.Fatal("watch not established")
+		return nil
+	}
+}
+
+func instanceOf(addr string) *regdis.Instance {
+	return &regdis.Instance{
+		InstanceId:  addr,
+		ServiceName: "one_service",
+		Ip:          "127.0.0.1",
+		Port:        8080,
+		Metadata:    map[string]string{regdis.MetadataRpcPort: "9090"},
+	}
+}
+
+func waitInstances(t *testing.T, changed <-chan []*regdis.Instance, want int) {
+	t.Helper()
+
+	waitInstancesWithin(t, changed, want, 3*time.Second)
+}
+
+func waitInstancesWithin(t *testing.T, changed <-chan []*regdis.Instance, want int, within time.Duration) {
+	t.Helper()
+
+	select {
+	case instances := <-changed:
+		if len(instances) != want {
+			t.Fatalf("expected %d instances, got:%s", want, regdis.PrettyOutput(instances))
+		}
+
+		for _, ins := range instances {
+			if ins.Port != 9090 {
+				t.Fatalf("rpc port not resolved:%s", regdis.PrettyOutput(instances))
+			}
+		}
+	case <-time.After(within):
+		t.Fatal("watch callback not triggered")
+	}
+}
+
+func TestEtcdDiscoverWatch(t *testing.T) {
+	fe, cli := newFakeEtcd()
+	dis := NewEtcdDiscovery(cli, EtcdDiscoveryConfig{})
+
+	dp := regdis.DiscoverParam{ServiceName: "one_service", DisType: regdis.ForRpc}
+
+	changed := make(chan []*regdis.Instance, 4)
+	if err := dis.Watch(dp, func(instances []*regdis.Instance, err error) {
+		if err == nil {
+			changed <- instances
+		}
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	fw := fe.nextWatch(t)
+
+	fe.put(t, instanceOf("127.0.0.1:8080"))
+	fe.notify(fw)
+	waitInstances(t, changed, 1)
+
+	// 通道关闭(如失去leader)后补一次全量实例, 并从读取时的版本继续监听
+	fe.put(t, instanceOf("127.0.0.1:8080"), instanceOf("127.0.0.1:8081"))
+	fw.close()
+	waitInstances(t, changed, 2)
+
+	fw = fe.nextWatch(t)
+
+	fe.mu.Lock()
+	revs := append([]int64(nil), fe.watchRevs...)
+	fe.mu.Unlock()
+
+	if len(revs) != 2 || revs[0] != 0 || revs[1] != 3 {
+		t.Fatalf("unexpected watch revisions:%v", revs)
+	}
+
+	fe.put(t)
+	fe.notify(fw)
+	waitInstances(t, changed, 0)
+
+	if err := dis.Unwatch(dp, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	// Unwatch后不再重新监听
+	select {
+	case <-fe.watches:
+		t.Fatal("watch re-established after unwatch")
+	case <-time.After(rewatchInterval + 200*time.Millisecond):
+	}
+}
+
+func TestEtcdRegisterDiscoverWatch(t *testing.T) {
+	cli := cetcdtest.StartEmbed(t).Cli
+
+	reg := regetcd.NewEtcdRegistry(cli, regetcd.EtcdRegistryConfig{LeaseTTLSeconds: 3})
+	dis := NewEtcdDiscovery(cli, EtcdDiscoveryConfig{})
+
+	dp := regdis.DiscoverParam{ServiceName: "one_service", DisType: regdis.ForRpc}
+
+	changed := make(chan []*regdis.Instance, 16)
+	if err := dis.Watch(dp, func(instances []*regdis.Instance, err error) {
+		if err == nil {
+			changed <- instances
+		}
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	rp := regdis.RegisterParam{
+		ServiceName: "one_service",
+		Addr:        "127.0.0.1:8080",
+		Weight:      10,
+		Metadata:    map[string]string{regdis.MetadataRpcPort: "9090"},
+	}
+
+	if err := reg.Register(rp); err != nil {
+		t.Fatalf("register failed: %v", err)
+	}
+	waitInstances(t, changed, 1)
+
+	instances, err := dis.Discover(dp)
+	if err != nil || len(instances) != 1 {
+		t.Fatalf("discover failed, instances:%v, err:%v", instances, err)
+	}
+
+	// 历史版本被压缩后监听不受影响
+	resp, err := cli.Get(context.Background(), "/")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = cli.Compact(context.Background(), resp.Header.Revision); err != nil {
+		t.Fatal(err)
+	}
+
+	// 租约丢失时实例下线, 重新注册后再次上线
+	kvs, err := cli.Get(context.Background(), "/", clientv3.WithPrefix())
+	if err != nil || len(kvs.Kvs) != 1 {
+		t.Fatalf("unexpected kvs:%v, %v", kvs, err)
+	}
+
+	if _, err = cli.Revoke(context.Background(), clientv3.LeaseID(kvs.Kvs[0].Lease)); err != nil {
+		t.Fatal(err)
+	}
+	waitInstances(t, changed, 0)
+	waitInstancesWithin(t, changed, 1, 5*time.Second)
+
+	if err = reg.Deregister(regdis.DeregisterParam{ServiceName: "one_service", Addr: "127.0.0.1:8080"}); err != nil {
+		t.Fatalf("deregister failed: %v", err)
+	}
+	waitInstances(t, changed, 0)
+
+	if err = dis.Unwatch(dp, nil); err != nil {
+		t.Fatal(err)
+	}
+}
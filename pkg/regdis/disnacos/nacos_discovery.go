@@ -7,7 +7,6 @@ import (
 	"github.com/sweemingdow/gmicro_pkg/pkg/regdis"
 	"github.com/sweemingdow/gmicro_pkg/pkg/regdis/extra/enacos"
 	"github.com/sweemingdow/gmicro_pkg/pkg/utils/usli"
-	"strconv"
 )
 
 type nacosDiscovery struct {
@@ -95,24 +94,11 @@ func convertIns(instances []model.Instance, disType regdis.DiscoverType) []*regd
 	return usli.Conv(instances, func(srcIns model.Instance) *regdis.Instance {
 		md := srcIns.Metadata
 
-		var port = int(srcIns.Port)
-		if disType == regdis.ForHttp {
-			p, err := strconv.Atoi(md["http_port"])
-			if err == nil {
-				port = p
-			}
-		} else if disType == regdis.ForRpc {
-			p, err := strconv.Atoi(md["rpc_port"])
-			if err == nil {
-				port = p
-			}
-		}
-
 		return &regdis.Instance{
 			InstanceId:  srcIns.InstanceId,
 			ServiceName: srcIns.ServiceName,
 			Ip:          srcIns.Ip,
-			Port:        port,
+			Port:        regdis.ResolvePort(int(srcIns.Port), md, disType),
 			Weight:      srcIns.Weight,
 			Metadata:    md,
 		}
@@ -0,0 +1,248 @@
+package disstatic
+
+import (
+	"context"
+	"fmt"
+	"github.com/sweemingdow/gmicro_pkg/pkg/mylog"
+	"github.com/sweemingdow/gmicro_pkg/pkg/parser/yaml"
+	"github.com/sweemingdow/gmicro_pkg/pkg/regdis"
+	"github.com/sweemingdow/gmicro_pkg/pkg/utils"
+	"github.com/sweemingdow/gmicro_pkg/pkg/utils/usli"
+	"os"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const defaultReloadInterval = 3 * time.Second
+
+// 静态文件格式:
+//
+//	services:
+//	  one_service:
+//	    - ip: 192.168.1.10
+//	      port: 8080
+//	      weight: 10
+//	      metadata:
+//	        rpc_port: "9090"
+type staticFile struct {
+	Services map[string][]staticInstance `yaml:"services"`
+}
+
+type staticInstance struct {
+	Ip       string            `yaml:"ip"`
+	Port     int               `yaml:"port"`
+	Weight   float64           `yaml:"weight"`
+	Metadata map[string]string `yaml:"metadata"`
+}
+
+type watcher struct {
+	disType regdis.DiscoverType
+	wf      regdis.WatchFunc
+}
+
+// StaticDiscovery 基于yaml文件的服务发现, 定时检查文件变化并通知watcher
+type StaticDiscovery struct {
+	path     string
+	interval time.Duration
+
+	rwMu     sync.RWMutex
+	services map[string][]staticInstance
+	modTime  time.Time
+	size     int64
+
+	wMu      sync.Mutex
+	watchers map[string][]watcher
+
+	stopChan chan struct{}
+	started  atomic.Bool
+	stopped  atomic.Bool
+}
+
+func NewStaticDiscovery(path string, reloadInterval time.Duration) (*StaticDiscovery, error) {
+	if reloadInterval <= 0 {
+		reloadInterval = defaultReloadInterval
+	}
+
+	sd := &StaticDiscovery{
+		path:     path,
+		interval: reloadInterval,
+		services: make(map[string][]staticInstance),
+		watchers: make(map[string][]watcher),
+		stopChan: make(chan struct{}),
+	}
+
+	if _, err := sd.Reload(); err != nil {
+		return nil, err
+	}
+
+	return sd, nil
+}
+
+func (sd *StaticDiscovery) Discover(dp regdis.DiscoverParam) ([]*regdis.Instance, error) {
+	sd.rwMu.RLock()
+	items, ok := sd.services[dp.ServiceName]
+	sd.rwMu.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("service:%s not found in static file:%s", dp.ServiceName, sd.path)
+	}
+
+	return convertIns(dp.ServiceName, items, dp.DisType), nil
+}
+
+func (sd *StaticDiscovery) Watch(dp regdis.DiscoverParam, wf regdis.WatchFunc) error {
+	sd.wMu.Lock()
+	sd.watchers[dp.ServiceName] = append(sd.watchers[dp.ServiceName], watcher{disType: dp.DisType, wf: wf})
+	sd.wMu.Unlock()
+
+	return nil
+}
+
+func (sd *StaticDiscovery) Unwatch(dp regdis.DiscoverParam, uwf regdis.UnwatchFunc) error {
+	sd.wMu.Lock()
+	sd.watchers[dp.ServiceName] = usli.Filter(sd.watchers[dp.ServiceName], func(w watcher) bool {
+		return w.disType != dp.DisType
+	})
+
+	if len(sd.watchers[dp.ServiceName]) == 0 {
+		delete(sd.watchers, dp.ServiceName)
+	}
+	sd.wMu.Unlock()
+
+	if uwf != nil {
+		uwf(nil)
+	}
+
+	return nil
+}
+
+// Reload 文件有变化时重新加载, 并通知实例有变化的服务的watcher
+func (sd *StaticDiscovery) Reload() (bool, error) {
+	fi, err := os.Stat(sd.path)
+	if err != nil {
+		return false, err
+	}
+
+	sd.rwMu.RLock()
+	unchanged := fi.ModTime().Equal(sd.modTime) && fi.Size() == sd.size
+	sd.rwMu.RUnlock()
+
+	if unchanged {
+		return false, nil
+	}
+
+	data, err := utils.ReadAll(sd.path)
+	if err != nil {
+		return false, err
+	}
+
+	var sf staticFile
+	if err = yaml.Parse(data, &sf); err != nil {
+		return false, fmt.Errorf("parse static discovery file:%s failed:%w", sd.path, err)
+	}
+
+	if sf.Services == nil {
+		sf.Services = make(map[string][]staticInstance)
+	}
+
+	sd.rwMu.Lock()
+	old := sd.services
+	sd.services = sf.Services
+	sd.modTime = fi.ModTime()
+	sd.size = fi.Size()
+	sd.rwMu.Unlock()
+
+	changed := make([]string, 0)
+	for name, items := range sf.Services {
+		if !reflect.DeepEqual(old[name], items) {
+			changed = append(changed, name)
+		}
+	}
+
+	for name := range old {
+		if _, ok := sf.Services[name]; !ok {
+			changed = append(changed, name)
+		}
+	}
+
+	for _, name := range changed {
+		sd.notify(name, sf.Services[name], nil)
+	}
+
+	return len(changed) > 0, nil
+}
+
+func (sd *StaticDiscovery) notify(serviceName string, items []staticInstance, err error) {
+	sd.wMu.Lock()
+	watchers := append([]watcher(nil), sd.watchers[serviceName]...)
+	sd.wMu.Unlock()
+
+	for _, w := range watchers {
+		if err != nil {
+			w.wf(nil, err)
+		} else {
+			w.wf(convertIns(serviceName, items, w.disType), nil)
+		}
+	}
+}
+
+func (sd *StaticDiscovery) notifyAllErr(err error) {
+	sd.wMu.Lock()
+	names := make([]string, 0, len(sd.watchers))
+	for name := range sd.watchers {
+		names = append(names, name)
+	}
+	sd.wMu.Unlock()
+
+	for _, name := range names {
+		sd.notify(name, nil, err)
+	}
+}
+
+func (sd *StaticDiscovery) OnCreated(_ chan<- error) {
+	if !sd.started.CompareAndSwap(false, true) {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(sd.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-sd.stopChan:
+				return
+			case <-ticker.C:
+				if _, err := sd.Reload(); err != nil {
+					lg := mylog.AppLoggerWithListen()
+					lg.Error().Stack().Err(err).Str("path", sd.path).Msg("reload static discovery file failed")
+
+					sd.notifyAllErr(err)
+				}
+			}
+		}
+	}()
+}
+
+func (sd *StaticDiscovery) OnDispose(_ context.Context) error {
+	if sd.stopped.CompareAndSwap(false, true) {
+		close(sd.stopChan)
+	}
+
+	return nil
+}
+
+func convertIns(serviceName string, items []staticInstance, disType regdis.DiscoverType) []*regdis.Instance {
+	return usli.Conv(items, func(item staticInstance) *regdis.Instance {
+		return &regdis.Instance{
+			InstanceId:  fmt.Sprintf("%s#%s#%d", serviceName, item.Ip, item.Port),
+			ServiceName: serviceName,
+			Ip:          item.Ip,
+			Port:        regdis.ResolvePort(item.Port, item.Metadata, disType),
+			Weight:      item.Weight,
+			Metadata:    item.Metadata,
+		}
+	})
+}
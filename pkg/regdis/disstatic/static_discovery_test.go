@@ -0,0 +1,68 @@
+package disstatic
+
+import (
+	"github.com/sweemingdow/gmicro_pkg/pkg/regdis"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+const oneInstance = `
+services:
+  one_service:
+    - ip: 127.0.0.1
+      port: 8080
+      metadata:
+        rpc_port: "9090"
+`
+
+const twoInstances = `
+services:
+  one_service:
+    - ip: 127.0.0.1
+      port: 8080
+      metadata:
+        rpc_port: "9090"
+    - ip: 127.0.0.2
+      port: 8080
+      metadata:
+        rpc_port: "9090"
+`
+
+func TestStaticDiscoveryReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "instances.yaml")
+	if err := os.WriteFile(path, []byte(oneInstance), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	sd, err := NewStaticDiscovery(path, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dp := regdis.DiscoverParam{ServiceName: "one_service", DisType: regdis.ForRpc}
+
+	instances, err := sd.Discover(dp)
+	if err != nil || len(instances) != 1 || instances[0].Port != 9090 {
+		t.Fatalf("unexpected discover result, instances:%v, err:%v", instances, err)
+	}
+
+	var watched []*regdis.Instance
+	_ = sd.Watch(dp, func(instances []*regdis.Instance, err error) {
+		watched = instances
+	})
+
+	if err = os.WriteFile(path, []byte(twoInstances), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	changed, err := sd.Reload()
+	if err != nil || !changed {
+		t.Fatalf("reload failed, changed:%t, err:%v", changed, err)
+	}
+
+	if len(watched) != 2 {
+		t.Fatalf("watcher should receive 2 instances, got: %s", regdis.PrettyOutput(watched))
+	}
+}
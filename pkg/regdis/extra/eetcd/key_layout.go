@@ -0,0 +1,18 @@
+package eetcd
+
+import "strings"
+
+// etcd中的key布局: {root}/{serviceName}/{ip:port}
+const DefaultRootPrefix = "/gmicro/services"
+
+func ServicePrefix(root, serviceName string) string {
+	if root == "" {
+		root = DefaultRootPrefix
+	}
+
+	return strings.TrimSuffix(root, "/") + "/" + serviceName + "/"
+}
+
+func InstanceKey(root, serviceName, addr string) string {
+	return ServicePrefix(root, serviceName) + addr
+}
@@ -0,0 +1,287 @@
+package regdismem
+
+import (
+	"fmt"
+	"github.com/sweemingdow/gmicro_pkg/pkg/regdis"
+	"github.com/sweemingdow/gmicro_pkg/pkg/utils"
+	"github.com/sweemingdow/gmicro_pkg/pkg/utils/usli"
+	"sync"
+	"time"
+)
+
+type watcher struct {
+	disType regdis.DiscoverType
+	wf      regdis.WatchFunc
+}
+
+// MemRegDis 进程内的Registry/Discovery实现, 用于单元测试和单进程开发
+// async为false时, Watch回调在Register/Deregister等调用中同步触发;
+// 为true时, 回调在独立的goroutine中按顺序触发, 可用WaitIdle等待投递完成
+type MemRegDis struct {
+	mu       sync.Mutex
+	services map[string][]*regdis.Instance
+	disErrs  map[string]error
+	watchers map[string][]watcher
+
+	async   bool
+	pending sync.WaitGroup
+
+	// 保护queue的投递和关闭, 不与mu共用以免回调中再调用Register等时死锁
+	// queue不限长度, 投递时不会阻塞, 回调中可以再次投递
+	qmu     sync.Mutex
+	queue   []func()
+	wake    chan struct{}
+	started bool
+	closed  bool
+}
+
+func NewMemRegDis(async bool) *MemRegDis {
+	return &MemRegDis{
+		services: make(map[string][]*regdis.Instance),
+		disErrs:  make(map[string]error),
+		watchers: make(map[string][]watcher),
+		async:    async,
+		wake:     make(chan struct{}, 1),
+	}
+}
+
+func (mrd *MemRegDis) Register(rp regdis.RegisterParam) error {
+	hp := utils.ExtractOneHp(rp.Addr)
+	if hp.Host == "" || hp.Port == 0 {
+		return fmt.Errorf("invalid register addr:%s", rp.Addr)
+	}
+
+	ins := &regdis.Instance{
+		InstanceId:  fmt.Sprintf("%s#%s", rp.ServiceName, rp.Addr),
+		ServiceName: rp.ServiceName,
+		Ip:          hp.Host,
+		Port:        hp.Port,
+		Weight:      float64(rp.Weight),
+		Metadata:    rp.Metadata,
+	}
+
+	mrd.mu.Lock()
+	instances := usli.Filter(mrd.services[rp.ServiceName], func(old *regdis.Instance) bool {
+		return old.InsIdentity() != ins.InsIdentity()
+	})
+	mrd.services[rp.ServiceName] = append(instances, ins)
+	mrd.mu.Unlock()
+
+	mrd.notify(rp.ServiceName)
+
+	return nil
+}
+
+func (mrd *MemRegDis) Deregister(dp regdis.DeregisterParam) error {
+	mrd.mu.Lock()
+	before := len(mrd.services[dp.ServiceName])
+	mrd.services[dp.ServiceName] = usli.Filter(mrd.services[dp.ServiceName], func(ins *regdis.Instance) bool {
+		return ins.InsIdentity() != dp.Addr
+	})
+	removed := before != len(mrd.services[dp.ServiceName])
+	mrd.mu.Unlock()
+
+	if !removed {
+		return fmt.Errorf("instance not registered, service:%s, addr:%s", dp.ServiceName, dp.Addr)
+	}
+
+	mrd.notify(dp.ServiceName)
+
+	return nil
+}
+
+func (mrd *MemRegDis) Discover(dp regdis.DiscoverParam) ([]*regdis.Instance, error) {
+	mrd.mu.Lock()
+	defer mrd.mu.Unlock()
+
+	if err := mrd.disErrs[dp.ServiceName]; err != nil {
+		return nil, err
+	}
+
+	return convertIns(mrd.services[dp.ServiceName], dp.DisType), nil
+}
+
+func (mrd *MemRegDis) Watch(dp regdis.DiscoverParam, wf regdis.WatchFunc) error {
+	mrd.mu.Lock()
+	mrd.watchers[dp.ServiceName] = append(mrd.watchers[dp.ServiceName], watcher{
+		disType: dp.DisType,
+		wf:      wf,
+	})
+	mrd.mu.Unlock()
+
+	return nil
+}
+
+func (mrd *MemRegDis) Unwatch(dp regdis.DiscoverParam, uwf regdis.UnwatchFunc) error {
+	mrd.mu.Lock()
+	mrd.watchers[dp.ServiceName] = usli.Filter(mrd.watchers[dp.ServiceName], func(w watcher) bool {
+		return w.disType != dp.DisType
+	})
+	mrd.mu.Unlock()
+
+	if uwf != nil {
+		uwf(nil)
+	}
+
+	return nil
+}
+
+// SetInstances 直接替换服务的实例列表并通知watcher
+func (mrd *MemRegDis) SetInstances(serviceName string, instances []*regdis.Instance) {
+	mrd.mu.Lock()
+	mrd.services[serviceName] = append([]*regdis.Instance(nil), instances...)
+	mrd.mu.Unlock()
+
+	mrd.notify(serviceName)
+}
+
+// EmitEmpty 向watcher推送空实例列表, 不修改已注册的实例
+func (mrd *MemRegDis) EmitEmpty(serviceName string) {
+	mrd.emit(serviceName, func(w watcher) {
+		w.wf(make([]*regdis.Instance, 0), nil)
+	})
+}
+
+// EmitError 向watcher推送错误
+func (mrd *MemRegDis) EmitError(serviceName string, err error) {
+	mrd.emit(serviceName, func(w watcher) {
+		w.wf(nil, err)
+	})
+}
+
+// SetDiscoverError 设置后Discover返回该错误, err为nil时恢复
+func (mrd *MemRegDis) SetDiscoverError(serviceName string, err error) {
+	mrd.mu.Lock()
+	if err == nil {
+		delete(mrd.disErrs, serviceName)
+	} else {
+		mrd.disErrs[serviceName] = err
+	}
+	mrd.mu.Unlock()
+}
+
+// Flap 模拟实例抖动: 交替推送空列表和当前实例列表times次, 最终停在当前实例列表
+func (mrd *MemRegDis) Flap(serviceName string, times int, interval time.Duration) {
+	for i := 0; i < times; i++ {
+		mrd.EmitEmpty(serviceName)
+		if interval > 0 {
+			time.Sleep(interval)
+		}
+
+		mrd.notify(serviceName)
+		if interval > 0 {
+			time.Sleep(interval)
+		}
+	}
+}
+
+// WaitIdle 等待所有异步回调投递完成, 同步模式下直接返回
+func (mrd *MemRegDis) WaitIdle() {
+	mrd.pending.Wait()
+}
+
+// Close 停止异步投递
+func (mrd *MemRegDis) Close() {
+	mrd.qmu.Lock()
+	if mrd.closed {
+		mrd.qmu.Unlock()
+		return
+	}
+	mrd.closed = true
+	mrd.qmu.Unlock()
+
+	mrd.signal()
+
+	// 已投递的回调投递完成后, 投递协程退出
+	mrd.pending.Wait()
+}
+
+func (mrd *MemRegDis) notify(serviceName string) {
+	mrd.mu.Lock()
+	instances := append([]*regdis.Instance(nil), mrd.services[serviceName]...)
+	mrd.mu.Unlock()
+
+	mrd.emit(serviceName, func(w watcher) {
+		w.wf(convertIns(instances, w.disType), nil)
+	})
+}
+
+func (mrd *MemRegDis) emit(serviceName string, call func(w watcher)) {
+	mrd.mu.Lock()
+	watchers := append([]watcher(nil), mrd.watchers[serviceName]...)
+	mrd.mu.Unlock()
+
+	for _, w := range watchers {
+		w := w
+		mrd.dispatch(func() {
+			call(w)
+		})
+	}
+}
+
+func (mrd *MemRegDis) dispatch(f func()) {
+	if !mrd.async {
+		f()
+		return
+	}
+
+	// 检查和投递在同一把锁内, 避免Close之后仍有投递
+	mrd.qmu.Lock()
+	if mrd.closed {
+		mrd.qmu.Unlock()
+		return
+	}
+
+	if !mrd.started {
+		mrd.started = true
+		go mrd.deliver()
+	}
+
+	mrd.pending.Add(1)
+	mrd.queue = append(mrd.queue, f)
+	mrd.qmu.Unlock()
+
+	mrd.signal()
+}
+
+// 按投递顺序执行回调, 关闭且已全部执行后退出
+func (mrd *MemRegDis) deliver() {
+	for {
+		mrd.qmu.Lock()
+		if len(mrd.queue) == 0 {
+			closed := mrd.closed
+			mrd.qmu.Unlock()
+
+			if closed {
+				return
+			}
+
+			<-mrd.wake
+			continue
+		}
+
+		fn := mrd.queue[0]
+		mrd.queue[0] = nil
+		mrd.queue = mrd.queue[1:]
+		mrd.qmu.Unlock()
+
+		fn()
+		mrd.pending.Done()
+	}
+}
+
+func (mrd *MemRegDis) signal() {
+	select {
+	case mrd.wake <- struct{}{}:
+	default:
+	}
+}
+
+// 每次返回新的实例副本, 避免调用方修改内部状态
+func convertIns(instances []*regdis.Instance, disType regdis.DiscoverType) []*regdis.Instance {
+	return usli.Conv(instances, func(src *regdis.Instance) *regdis.Instance {
+		ins := *src
+		ins.Port = regdis.ResolvePort(src.Port, src.Metadata, disType)
+		return &ins
+	})
+}
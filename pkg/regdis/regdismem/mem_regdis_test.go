@@ -0,0 +1,139 @@
+package regdismem
+
+import (
+	"errors"
+	"fmt"
+	"github.com/sweemingdow/gmicro_pkg/pkg/regdis"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestMemRegDisWatch(t *testing.T) {
+	for _, async := range []bool{false, true} {
+		mrd := NewMemRegDis(async)
+
+		var (
+			events [][]*regdis.Instance
+			errs   []error
+		)
+
+		dp := regdis.DiscoverParam{ServiceName: "one_service", DisType: regdis.ForRpc}
+		_ = mrd.Watch(dp, func(instances []*regdis.Instance, err error) {
+			if err != nil {
+				errs = append(errs, err)
+				return
+			}
+			events = append(events, instances)
+		})
+
+		md := map[string]string{regdis.MetadataRpcPort: "9090"}
+		if err := mrd.Register(regdis.RegisterParam{ServiceName: "one_service", Addr: "127.0.0.1:8080", Weight: 10, Metadata: md}); err != nil {
+			t.Fatal(err)
+		}
+		_ = mrd.Register(regdis.RegisterParam{ServiceName: "one_service", Addr: "127.0.0.2:8080", Weight: 10, Metadata: md})
+		_ = mrd.Deregister(regdis.DeregisterParam{ServiceName: "one_service", Addr: "127.0.0.1:8080"})
+		mrd.Flap("one_service", 2, 0)
+		mrd.EmitError("one_service", errors.New("boom"))
+		mrd.WaitIdle()
+
+		// register, register, deregister, (empty, full) * 2
+		if len(events) != 7 {
+			t.Fatalf("async:%v, expect 7 events, got %d", async, len(events))
+		}
+
+		last := events[len(events)-1]
+		if len(last) != 1 || last[0].Ip != "127.0.0.2" || last[0].Port != 9090 {
+			t.Fatalf("async:%v, unexpected last event:%s", async, regdis.PrettyOutput(last))
+		}
+
+		if len(events[3]) != 0 || len(errs) != 1 {
+			t.Fatalf("async:%v, flap or error not delivered", async)
+		}
+
+		mrd.SetDiscoverError("one_service", errors.New("unavailable"))
+		if _, err := mrd.Discover(dp); err == nil {
+			t.Fatalf("async:%v, expect discover error", async)
+		}
+
+		_ = mrd.Unwatch(dp, nil)
+		mrd.SetInstances("one_service", nil)
+		mrd.WaitIdle()
+		if len(events) != 7 {
+			t.Fatalf("async:%v, event delivered after unwatch", async)
+		}
+
+		mrd.Close()
+	}
+}
+
+// 异步模式下Close与Register并发时不能向已关闭的queue投递
+func TestMemRegDisCloseWhileDispatching(t *testing.T) {
+	for i := 0; i < 20; i++ {
+		mrd := NewMemRegDis(true)
+
+		dp := regdis.DiscoverParam{ServiceName: "one_service", DisType: regdis.ForRpc}
+		_ = mrd.Watch(dp, func(_ []*regdis.Instance, _ error) {})
+
+		var wg sync.WaitGroup
+		for j := 0; j < 4; j++ {
+			wg.Add(1)
+			go func(j int) {
+				defer wg.Done()
+
+				for k := 0; k < 20; k++ {
+					_ = mrd.Register(regdis.RegisterParam{
+						ServiceName: "one_service",
+						Addr:        fmt.Sprintf("127.0.0.1:%d", 8000+j*100+k),
+					})
+				}
+			}(j)
+		}
+
+		mrd.Close()
+		wg.Wait()
+	}
+}
+
+// 回调中大量投递不会阻塞投递协程自身
+func TestMemRegDisReentrantDispatch(t *testing.T) {
+	const total = 2000
+
+	// 失败时投递协程已阻塞, 不能再Close
+	mrd := NewMemRegDis(true)
+
+	var delivered atomic.Int32
+	_ = mrd.Watch(regdis.DiscoverParam{ServiceName: "two_service"}, func(_ []*regdis.Instance, _ error) {
+		delivered.Add(1)
+	})
+
+	var once sync.Once
+	_ = mrd.Watch(regdis.DiscoverParam{ServiceName: "one_service"}, func(_ []*regdis.Instance, _ error) {
+		once.Do(func() {
+			for i := 0; i < total; i++ {
+				_ = mrd.Register(regdis.RegisterParam{ServiceName: "two_service", Addr: "127.0.0.1:9090"})
+			}
+		})
+	})
+
+	_ = mrd.Register(regdis.RegisterParam{ServiceName: "one_service", Addr: "127.0.0.1:8080"})
+
+	idle := make(chan struct{})
+	go func() {
+		mrd.WaitIdle()
+		close(idle)
+	}()
+
+	select {
+	case <-idle:
+	case <-time.After(5 * time.Second):
+		t.Fatal("dispatch deadlocked when callback registers")
+	}
+
+	if n := delivered.Load(); n != total {
+		t.Fatalf("expect %d callbacks, got:%d", total, n)
+	}
+
+	mrd.Close()
+}
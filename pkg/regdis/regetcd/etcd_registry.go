@@ -0,0 +1,219 @@
+package regetcd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/sweemingdow/gmicro_pkg/pkg/mylog"
+	"github.com/sweemingdow/gmicro_pkg/pkg/parser/json"
+	"github.com/sweemingdow/gmicro_pkg/pkg/regdis"
+	"github.com/sweemingdow/gmicro_pkg/pkg/regdis/extra/eetcd"
+	"github.com/sweemingdow/gmicro_pkg/pkg/utils"
+	"go.etcd.io/etcd/api/v3/v3rpc/rpctypes"
+	clientv3 "go.etcd.io/etcd/client/v3"
+	"sync"
+	"time"
+)
+
+const (
+	defaultLeaseTTLSeconds = 10
+	defaultOpTimeout       = 3 * time.Second
+	reRegisterMaxBackoff   = 10 * time.Second
+)
+
+type EtcdRegistryConfig struct {
+	RootPrefix      string // key前缀, 默认eetcd.DefaultRootPrefix
+	LeaseTTLSeconds int64  // 租约时长, 进程异常退出后实例在ttl后自动过期
+	OpTimeout       time.Duration
+}
+
+type leaseEntry struct {
+	leaseId clientv3.LeaseID
+	cancel  context.CancelFunc
+	done    chan struct{} // 续约协程退出后关闭
+}
+
+// 基于租约的etcd注册: 注册时put带lease的key并持续续约, 续约中断时重新注册
+type etcdRegistry struct {
+	cli    *clientv3.Client
+	cfg    EtcdRegistryConfig
+	mu     sync.Mutex
+	leases map[string]*leaseEntry
+}
+
+func NewEtcdRegistry(cli *clientv3.Client, cfg EtcdRegistryConfig) regdis.Registry {
+	if cfg.LeaseTTLSeconds <= 0 {
+		cfg.LeaseTTLSeconds = defaultLeaseTTLSeconds
+	}
+
+	if cfg.OpTimeout <= 0 {
+		cfg.OpTimeout = defaultOpTimeout
+	}
+
+	return &etcdRegistry{
+		cli:    cli,
+		cfg:    cfg,
+		leases: make(map[string]*leaseEntry),
+	}
+}
+
+func (er *etcdRegistry) Register(rp regdis.RegisterParam) error {
+	key := eetcd.InstanceKey(er.cfg.RootPrefix, rp.ServiceName, rp.Addr)
+
+	hp := utils.ExtractOneHp(rp.Addr)
+	val, err := json.Fmt(&regdis.Instance{
+		InstanceId:  rp.Addr,
+		ServiceName: rp.ServiceName,
+		Ip:          hp.Host,
+		Port:        hp.Port,
+		Weight:      float64(rp.Weight),
+		Metadata:    rp.Metadata,
+	})
+	if err != nil {
+		return err
+	}
+
+	er.mu.Lock()
+	defer er.mu.Unlock()
+
+	if _, ok := er.leases[key]; ok {
+		return fmt.Errorf("instance already registered to etcd, key:%s", key)
+	}
+
+	leaseId, err := er.putWithLease(key, string(val))
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	entry := &leaseEntry{leaseId: leaseId, cancel: cancel, done: make(chan struct{})}
+	er.leases[key] = entry
+
+	go er.keepAlive(ctx, key, string(val), entry)
+
+	return nil
+}
+
+func (er *etcdRegistry) Deregister(dp regdis.DeregisterParam) error {
+	key := eetcd.InstanceKey(er.cfg.RootPrefix, dp.ServiceName, dp.Addr)
+
+	er.mu.Lock()
+	entry, ok := er.leases[key]
+	delete(er.leases, key)
+	er.mu.Unlock()
+
+	if !ok {
+		return fmt.Errorf("instance not registered to etcd, key:%s", key)
+	}
+
+	// 等待续约协程退出, 避免其重新注册的key在删除之后写回
+	entry.cancel()
+	<-entry.done
+
+	ctx, cancel := context.WithTimeout(context.Background(), er.cfg.OpTimeout)
+	defer cancel()
+
+	if _, err := er.cli.Delete(ctx, key); err != nil {
+		return err
+	}
+
+	// 租约丢失后尚未重新注册时, 原租约已不存在
+	_, err := er.cli.Revoke(ctx, er.currentLease(entry))
+	if errors.Is(err, rpctypes.ErrLeaseNotFound) {
+		return nil
+	}
+
+	return err
+}
+
+func (er *etcdRegistry) putWithLease(key, val string) (clientv3.LeaseID, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), er.cfg.OpTimeout)
+	defer cancel()
+
+	lease, err := er.cli.Grant(ctx, er.cfg.LeaseTTLSeconds)
+	if err != nil {
+		return 0, err
+	}
+
+	if _, err = er.cli.Put(ctx, key, val, clientv3.WithLease(lease.ID)); err != nil {
+		return 0, err
+	}
+
+	return lease.ID, nil
+}
+
+func (er *etcdRegistry) currentLease(entry *leaseEntry) clientv3.LeaseID {
+	er.mu.Lock()
+	defer er.mu.Unlock()
+
+	return entry.leaseId
+}
+
+// 续约, channel关闭说明租约丢失(网络中断或租约过期), 以退避方式重新注册
+func (er *etcdRegistry) keepAlive(ctx context.Context, key, val string, entry *leaseEntry) {
+	defer close(entry.done)
+
+	lg := mylog.AppLoggerWithListen()
+	backoff := time.Second
+
+	for {
+		kaCh, err := er.cli.KeepAlive(ctx, er.currentLease(entry))
+		if err == nil {
+			for range kaCh {
+				// drain keepalive responses
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		default:
+		}
+
+		lg.Warn().Str("key", key).Msgf("etcd lease lost, re-register after:%v", backoff)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+
+		leaseId, err := er.putWithLease(key, val)
+		if err != nil {
+			lg.Error().Stack().Err(err).Str("key", key).Msg("re-register to etcd failed")
+
+			backoff *= 2
+			if backoff > reRegisterMaxBackoff {
+				backoff = reRegisterMaxBackoff
+			}
+			continue
+		}
+
+		// 重新注册期间已注销, 撤销刚写入的key和租约
+		if ctx.Err() != nil {
+			er.revokeQuietly(key, leaseId)
+			return
+		}
+
+		er.mu.Lock()
+		entry.leaseId = leaseId
+		er.mu.Unlock()
+
+		backoff = time.Second
+	}
+}
+
+func (er *etcdRegistry) revokeQuietly(key string, leaseId clientv3.LeaseID) {
+	ctx, cancel := context.WithTimeout(context.Background(), er.cfg.OpTimeout)
+	defer cancel()
+
+	lg := mylog.AppLoggerWithStop()
+
+	if _, err := er.cli.Delete(ctx, key); err != nil {
+		lg.Error().Stack().Err(err).Str("key", key).Msg("delete re-registered key from etcd failed")
+	}
+
+	if _, err := er.cli.Revoke(ctx, leaseId); err != nil {
+		lg.Error().Stack().Err(err).Str("key", key).Msg("revoke re-registered lease failed")
+	}
+}
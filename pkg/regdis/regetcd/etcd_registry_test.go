@@ -0,0 +1,238 @@
+package regetcd
+
+import (
+	"context"
+	"errors"
+	"github.com/sweemingdow/gmicro_pkg/pkg/component/cetcd/cetcdtest"
+	"github.com/sweemingdow/gmicro_pkg/pkg/regdis"
+	"github.com/sweemingdow/gmicro_pkg/pkg/regdis/extra/eetcd"
+	clientv3 "go.etcd.io/etcd/client/v3"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+var (
+	testRp = regdis.RegisterParam{
+		ServiceName: "one_service",
+		Addr:        "127.0.0.1:8080",
+		Weight:      10,
+		Metadata:    map[string]string{regdis.MetadataRpcPort: "9090"},
+	}
+
+	testDp  = regdis.DeregisterParam{ServiceName: "one_service", Addr: "127.0.0.1:8080"}
+	testKey = eetcd.InstanceKey("", "one_service", "127.0.0.1:8080")
+)
+
+// 控制Put的KV, 用于模拟重新注册失败和写入进行中
+type hookKV struct {
+	clientv3.KV
+
+	fails   atomic.Int32  // 剩余失败次数
+	putting chan struct{} // 非nil时, Put开始后通知并等待release
+	release chan struct{}
+	deleted chan struct{}
+}
+
+func (hk *hookKV) Put(ctx context.Context, key, val string, opts ...clientv3.OpOption) (*clientv3.PutResponse, error) {
+	if hk.fails.Add(-1) >= 0 {
+		return nil, errors.New("put failed by test")
+	}
+
+	if hk.putting != nil {
+		hk.putting <- struct{}{}
+		<-hk.release
+	}
+
+	return hk.KV.Put(ctx, key, val, opts...)
+}
+
+func (hk *hookKV) Delete(ctx context.Context, key string, opts ...clientv3.OpOption) (*clientv3.DeleteResponse, error) {
+	if hk.deleted != nil {
+		select {
+		case hk.deleted <- struct{}{}:
+		default:
+		}
+	}
+
+	return hk.KV.Delete(ctx, key, opts...)
+}
+
+func hookCli(cli *clientv3.Client) *hookKV {
+	hk := &hookKV{KV: cli.KV}
+	cli.KV = hk
+	return hk
+}
+
+func leaseOf(t *testing.T, cli *clientv3.Client) clientv3.LeaseID {
+	t.Helper()
+
+	resp, err := cli.Get(context.Background(), testKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(resp.Kvs) == 0 {
+		return 0
+	}
+
+	return clientv3.LeaseID(resp.Kvs[0].Lease)
+}
+
+// 等待key以新的租约重新写入
+func waitReRegistered(t *testing.T, cli *clientv3.Client, old clientv3.LeaseID, within time.Duration) clientv3.LeaseID {
+	t.Helper()
+
+	deadline := time.Now().Add(within)
+	for time.Now().Before(deadline) {
+		if lease := leaseOf(t, cli); lease != 0 && lease != old {
+			return lease
+		}
+
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	t.Fatalf("instance not re-registered within %v", within)
+	return 0
+}
+
+func assertNoLeases(t *testing.T, cli *clientv3.Client) {
+	t.Helper()
+
+	resp, err := cli.Leases(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(resp.Leases) != 0 {
+		t.Fatalf("expected all leases revoked, got:%v", resp.Leases)
+	}
+}
+
+func TestEtcdRegistryLease(t *testing.T) {
+	cli := cetcdtest.StartEmbed(t).Cli
+	reg := NewEtcdRegistry(cli, EtcdRegistryConfig{LeaseTTLSeconds: 3})
+
+	if err := reg.Register(testRp); err != nil {
+		t.Fatal(err)
+	}
+
+	lease := leaseOf(t, cli)
+	if lease == 0 {
+		t.Fatal("instance not put with lease")
+	}
+
+	ttl, err := cli.TimeToLive(context.Background(), lease)
+	if err != nil || ttl.GrantedTTL != 3 {
+		t.Fatalf("unexpected lease ttl:%+v, %v", ttl, err)
+	}
+
+	if err = reg.Register(testRp); err == nil {
+		t.Fatal("expect duplicate register rejected")
+	}
+
+	// 续约使key在ttl之后仍然存在
+	time.Sleep(4 * time.Second)
+	if leaseOf(t, cli) != lease {
+		t.Fatal("lease not kept alive")
+	}
+
+	if err = reg.Deregister(testDp); err != nil {
+		t.Fatal(err)
+	}
+
+	if leaseOf(t, cli) != 0 {
+		t.Fatal("instance still registered after deregister")
+	}
+
+	assertNoLeases(t, cli)
+}
+
+func TestEtcdRegistryReRegisterAfterLeaseLost(t *testing.T) {
+	cli := cetcdtest.StartEmbed(t).Cli
+	reg := NewEtcdRegistry(cli, EtcdRegistryConfig{LeaseTTLSeconds: 3})
+
+	if err := reg.Register(testRp); err != nil {
+		t.Fatal(err)
+	}
+
+	// 租约丢失(如过期)后以新租约重新注册
+	lease := leaseOf(t, cli)
+	if _, err := cli.Revoke(context.Background(), lease); err != nil {
+		t.Fatal(err)
+	}
+
+	lease = waitReRegistered(t, cli, lease, 5*time.Second)
+
+	// 重新注册失败时退避重试
+	hk := hookCli(cli)
+	hk.fails.Store(2)
+
+	if _, err := cli.Revoke(context.Background(), lease); err != nil {
+		t.Fatal(err)
+	}
+
+	waitReRegistered(t, cli, lease, 10*time.Second)
+	if hk.fails.Load() >= 0 {
+		t.Fatal("expect failed re-register retried")
+	}
+
+	if err := reg.Deregister(testDp); err != nil {
+		t.Fatal(err)
+	}
+
+	assertNoLeases(t, cli)
+}
+
+func TestEtcdRegistryDeregisterDuringReRegister(t *testing.T) {
+	cli := cetcdtest.StartEmbed(t).Cli
+	reg := NewEtcdRegistry(cli, EtcdRegistryConfig{LeaseTTLSeconds: 3})
+
+	if err := reg.Register(testRp); err != nil {
+		t.Fatal(err)
+	}
+
+	hk := hookCli(cli)
+	hk.putting = make(chan struct{})
+	hk.release = make(chan struct{})
+	hk.deleted = make(chan struct{}, 1)
+
+	if _, err := cli.Revoke(context.Background(), leaseOf(t, cli)); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case <-hk.putting:
+	case <-time.After(5 * time.Second):
+		t.Fatal("re-register not started")
+	}
+
+	deregistered := make(chan error, 1)
+	go func() {
+		deregistered <- reg.Deregister(testDp)
+	}()
+
+	// 注销须等待进行中的重新注册结束, 此前不应删除key
+	select {
+	case <-hk.deleted:
+		t.Fatal("deregister deleted key while re-register in flight")
+	case <-time.After(200 * time.Millisecond):
+	}
+
+	close(hk.release)
+
+	select {
+	case err := <-deregistered:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("deregister not returned")
+	}
+
+	if leaseOf(t, cli) != 0 {
+		t.Fatal("instance written back after deregister")
+	}
+
+	assertNoLeases(t, cli)
+}
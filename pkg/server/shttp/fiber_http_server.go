@@ -38,9 +38,10 @@ func DefaultFiberServerConfig(port int) FiberServerConfig {
 }
 
 type FiberHttpServer struct {
-	fa     *fiber.App
-	port   int
-	closed atomic.Bool
+	fa        *fiber.App
+	port      int
+	closed    atomic.Bool
+	listening atomic.Bool
 }
 
 type HttpRouterBind func(fa *fiber.App)
@@ -76,16 +77,35 @@ func NewFiberHttpServer(cfg FiberServerConfig, errHandler fiber.ErrorHandler) *F
 		JSONDecoder:  json.Parse,
 	})
 
-	return &FiberHttpServer{
+	fhs := &FiberHttpServer{
 		port: cfg.Port,
 		fa:   fa,
 	}
+
+	fa.Hooks().OnListen(func(_ fiber.ListenData) error {
+		fhs.listening.Store(true)
+		return nil
+	})
+
+	return fhs
 }
 
 func (fhs *FiberHttpServer) GetFiber() *fiber.App {
 	return fhs.fa
 }
 
+func (fhs *FiberHttpServer) HealthCheck(_ context.Context) error {
+	if fhs.closed.Load() {
+		return errors.New("fiber http server closed")
+	}
+
+	if !fhs.listening.Load() {
+		return fmt.Errorf("fiber http server not listening yet, port:%d", fhs.port)
+	}
+
+	return nil
+}
+
 func (fhs *FiberHttpServer) OnCreated(ec chan<- error) {
 	lg := mylog.AppLoggerWithInit()
 	lg.Debug().Msgf("fiber http server start now, port:%d", fhs.port)
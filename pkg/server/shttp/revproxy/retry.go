@@ -0,0 +1,222 @@
+package revproxy
+
+import (
+	"errors"
+	"github.com/gofiber/fiber/v2"
+	"github.com/sweemingdow/gmicro_pkg/pkg/mylog"
+	"github.com/valyala/fasthttp"
+	"math/rand/v2"
+	"net"
+	"slices"
+	"sync"
+	"time"
+)
+
+// 幂等方法, 未配置Methods时只重试这些方法
+var defaultRetryMethods = []string{
+	fasthttp.MethodGet,
+	fasthttp.MethodHead,
+	fasthttp.MethodOptions,
+	fasthttp.MethodPut,
+	fasthttp.MethodDelete,
+	fasthttp.MethodTrace,
+}
+
+// RoutePolicy 路由级的转发策略, 零值时与未配置一致
+type RoutePolicy struct {
+	// 整个请求(含重试)的超时, 为0时使用HostClientConfig计算的超时
+	Timeout time.Duration
+
+	// 为nil时不重试
+	Retry *RetryPolicy
+}
+
+// RetryPolicy 连接失败或响应状态码在RetryOnStatus中时, 换一个未尝试过的实例重试
+type RetryPolicy struct {
+	// 包含首次请求, 小于2时不重试
+	MaxAttempts int
+
+	Methods []string
+
+	// 如502, 503, 504, 最后一次尝试的响应原样返回
+	RetryOnStatus []int
+
+	// 为nil时不限制
+	Budget *RetryBudget
+}
+
+func (rp *RetryPolicy) methodAllowed(method string) bool {
+	if len(rp.Methods) == 0 {
+		return slices.Contains(defaultRetryMethods, method)
+	}
+
+	return slices.Contains(rp.Methods, method)
+}
+
+func (rp *RetryPolicy) shouldRetry(method string, err error, status int) bool {
+	if err != nil {
+		if isConnectErr(err) {
+			return true
+		}
+
+		// 上游可能已处理过请求, 只有幂等方法才能重试
+		return errors.Is(err, fasthttp.ErrConnectionClosed) && slices.Contains(defaultRetryMethods, method)
+	}
+
+	return slices.Contains(rp.RetryOnStatus, status)
+}
+
+// 连接未建立, 请求未发送到上游的错误
+func isConnectErr(err error) bool {
+	if errors.Is(err, fasthttp.ErrDialTimeout) || errors.Is(err, fasthttp.ErrNoFreeConns) {
+		return true
+	}
+
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && opErr.Op == "dial"
+}
+
+// RetryBudget 限制重试量, 避免上游故障时重试放大流量
+// 每个请求存入ratio个令牌, 另外每秒补充minPerSec个, 每次重试消耗一个, 最多积累10秒的量
+type RetryBudget struct {
+	mu        sync.Mutex
+	ratio     float64
+	minPerSec float64
+	capacity  float64
+	balance   float64
+	last      time.Time
+}
+
+// NewRetryBudget ratio如0.2表示重试量不超过请求量的20%, minPerSec保证低流量时也能重试
+func NewRetryBudget(ratio, minPerSec float64) *RetryBudget {
+	capacity := max(minPerSec, ratio*100, 1) * 10
+
+	return &RetryBudget{
+		ratio:     ratio,
+		minPerSec: minPerSec,
+		capacity:  capacity,
+		balance:   minPerSec,
+		last:      time.Now(),
+	}
+}
+
+func (rb *RetryBudget) deposit() {
+	rb.mu.Lock()
+	defer rb.mu.Unlock()
+
+	rb.refill()
+	rb.balance = min(rb.capacity, rb.balance+rb.ratio)
+}
+
+func (rb *RetryBudget) withdraw() bool {
+	rb.mu.Lock()
+	defer rb.mu.Unlock()
+
+	rb.refill()
+	if rb.balance < 1 {
+		return false
+	}
+
+	rb.balance--
+	return true
+}
+
+func (rb *RetryBudget) refill() {
+	now := time.Now()
+	if elapsed := now.Sub(rb.last); elapsed > 0 {
+		rb.balance = min(rb.capacity, rb.balance+elapsed.Seconds()*rb.minPerSec)
+		rb.last = now
+	}
+}
+
+// SetRoutePolicy 设置超时和重试策略, 可随路由表热更新
+func (srp *HttpServerReverseProxy) SetRoutePolicy(rp RoutePolicy) {
+	srp.policy.Store(&rp)
+}
+
+func (srp *HttpServerReverseProxy) forward(c *fiber.Ctx, grp *upstreamGroup) error {
+	req := c.Request()
+	res := c.Response()
+
+	timeout := srp.lbCli.Timeout
+
+	var retry *RetryPolicy
+	if rp := srp.policy.Load(); rp != nil {
+		if rp.Timeout > 0 {
+			timeout = rp.Timeout
+		}
+		retry = rp.Retry
+	}
+
+	deadline := time.Now().Add(timeout)
+	method := string(req.Header.Method())
+
+	if retry == nil || retry.MaxAttempts < 2 || !retry.methodAllowed(method) {
+		return grp.lbCli.DoDeadline(req, res, deadline)
+	}
+
+	if retry.Budget != nil {
+		retry.Budget.deposit()
+	}
+
+	tried := make([]*fasthttp.HostClient, 0, retry.MaxAttempts)
+
+	hc := pickHost(grp.hosts, tried)
+	if hc == nil {
+		return grp.lbCli.DoDeadline(req, res, deadline)
+	}
+
+	for attempt := 1; ; attempt++ {
+		tried = append(tried, hc)
+
+		err := hc.DoDeadline(req, res, deadline)
+		if attempt >= retry.MaxAttempts || !retry.shouldRetry(method, err, res.StatusCode()) || !time.Now().Before(deadline) {
+			return err
+		}
+
+		next := pickHost(grp.hosts, tried)
+		if next == nil {
+			return err
+		}
+
+		if retry.Budget != nil && !retry.Budget.withdraw() {
+			return err
+		}
+
+		lg := mylog.AppLoggerWithListen()
+		if e := lg.Debug(); e.Enabled() {
+			e.Err(err).
+				Str("service_name", srp.serviceName).
+				Int("status", res.StatusCode()).
+				Msgf("retry on another upstream, attempt:%d, from:%s, to:%s", attempt+1, hc.Addr, next.Addr)
+		}
+
+		res.Reset()
+		hc = next
+	}
+}
+
+// 在未尝试过的实例中选择进行中请求最少的, 从随机位置开始以分散负载
+func pickHost(hosts []*fasthttp.HostClient, tried []*fasthttp.HostClient) *fasthttp.HostClient {
+	if len(hosts) == 0 {
+		return nil
+	}
+
+	var (
+		best  *fasthttp.HostClient
+		start = rand.IntN(len(hosts))
+	)
+
+	for i := range hosts {
+		hc := hosts[(start+i)%len(hosts)]
+		if slices.Contains(tried, hc) {
+			continue
+		}
+
+		if best == nil || hc.PendingRequests() < best.PendingRequests() {
+			best = hc
+		}
+	}
+
+	return best
+}
@@ -0,0 +1,136 @@
+package revproxy
+
+import (
+	"fmt"
+	"github.com/gofiber/fiber/v2"
+	"github.com/sweemingdow/gmicro_pkg/pkg/regdis"
+	"github.com/sweemingdow/gmicro_pkg/pkg/regdis/regdismem"
+	"github.com/valyala/fasthttp"
+	"net"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+)
+
+func startUpstream(t *testing.T, status int, hits *atomic.Int64) int {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	srv := &fasthttp.Server{Handler: func(c *fasthttp.RequestCtx) {
+		hits.Add(1)
+		c.SetStatusCode(status)
+	}}
+	go func() {
+		_ = srv.Serve(ln)
+	}()
+	t.Cleanup(func() {
+		_ = srv.Shutdown()
+	})
+
+	return ln.Addr().(*net.TCPAddr).Port
+}
+
+func TestReverseProxyRetryOnAnotherInstance(t *testing.T) {
+	const service = "retry_service"
+
+	var badHits, goodHits atomic.Int64
+	badPort := startUpstream(t, fasthttp.StatusServiceUnavailable, &badHits)
+	goodPort := startUpstream(t, fasthttp.StatusOK, &goodHits)
+
+	// 未监听的端口, 连接失败
+	ln, _ := net.Listen("tcp", "127.0.0.1:0")
+	deadPort := ln.Addr().(*net.TCPAddr).Port
+	_ = ln.Close()
+
+	mrd := regdismem.NewMemRegDis(false)
+	for i, port := range []int{badPort, goodPort, deadPort} {
+		_ = mrd.Register(regdis.RegisterParam{
+			ServiceName: service,
+			Addr:        fmt.Sprintf("127.0.0.1:%d", 9001+i),
+			Metadata:    map[string]string{regdis.MetadataHttpPort: strconv.Itoa(port)},
+		})
+	}
+
+	srp := NewHttpServerReverseProxy(service, mrd, nil, HostClientConfig{})
+	defer srp.Shutdown()
+
+	fa := fiber.New()
+	fa.All("/*", srp.ReverseProxy(nil, nil))
+
+	call := func(method string) int {
+		rsp, err := fa.Test(httptest.NewRequest(method, "/a", nil))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return rsp.StatusCode
+	}
+
+	srp.SetRoutePolicy(RoutePolicy{Retry: &RetryPolicy{
+		MaxAttempts:   3,
+		RetryOnStatus: []int{fasthttp.StatusServiceUnavailable},
+		Budget:        NewRetryBudget(1, 100),
+	}})
+
+	for i := 0; i < 20; i++ {
+		if status := call(fiber.MethodGet); status != fiber.StatusOK {
+			t.Fatalf("expect retried to the good instance, got:%d", status)
+		}
+	}
+
+	if goodHits.Load() != 20 || badHits.Load() == 0 {
+		t.Fatalf("unexpected hits, good:%d, bad:%d", goodHits.Load(), badHits.Load())
+	}
+
+	// 预算耗尽时不重试
+	srp.SetRoutePolicy(RoutePolicy{Retry: &RetryPolicy{
+		MaxAttempts:   3,
+		RetryOnStatus: []int{fasthttp.StatusServiceUnavailable},
+		Budget:        NewRetryBudget(0, 0),
+	}})
+
+	failed := 0
+	for i := 0; i < 20; i++ {
+		if call(fiber.MethodGet) != fiber.StatusOK {
+			failed++
+		}
+	}
+
+	if failed == 0 {
+		t.Fatal("expect failures without retry budget")
+	}
+}
+
+func TestRetryBudget(t *testing.T) {
+	rb := NewRetryBudget(0.5, 0)
+	if rb.withdraw() {
+		t.Fatal("expect empty budget")
+	}
+
+	rb.deposit()
+	rb.deposit()
+	if !rb.withdraw() || rb.withdraw() {
+		t.Fatal("expect one retry per two requests")
+	}
+}
+
+func TestRetryOnConnectionClosed(t *testing.T) {
+	rp := &RetryPolicy{Methods: []string{fasthttp.MethodGet, fasthttp.MethodPost}}
+
+	if !rp.shouldRetry(fasthttp.MethodPost, fasthttp.ErrDialTimeout, 0) {
+		t.Fatal("expect dial error retried for any method")
+	}
+
+	// 请求可能已被上游处理, 非幂等方法不重试
+	if rp.shouldRetry(fasthttp.MethodPost, fasthttp.ErrConnectionClosed, 0) {
+		t.Fatal("expect POST not retried on connection closed")
+	}
+
+	if !rp.shouldRetry(fasthttp.MethodGet, fasthttp.ErrConnectionClosed, 0) {
+		t.Fatal("expect GET retried on connection closed")
+	}
+}
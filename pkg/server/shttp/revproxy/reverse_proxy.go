@@ -1,6 +1,7 @@
 package revproxy
 
 import (
+	"errors"
 	"fmt"
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/utils"
@@ -20,6 +21,9 @@ const (
 	defaultTimeoutMills = 15_000
 )
 
+// ErrRequestHandled modifyReqs中返回该错误时不再转发, 以当前响应结束请求, 用于重定向, 静态响应等
+var ErrRequestHandled = errors.New("request handled without proxy")
+
 type HostClientConfig struct {
 	MaxConns            int
 	MaxIdleConnDuration time.Duration
@@ -41,6 +45,19 @@ type HttpServerReverseProxy struct {
 	discovered  []*regdis.Instance
 	unavailable atomic.Bool
 	hcCfg       HostClientConfig
+	ts          *TrafficSplit
+	split       atomic.Pointer[splitState]
+	all         atomic.Pointer[upstreamGroup] // 全部实例的快照
+	policy      atomic.Pointer[RoutePolicy]
+}
+
+type subsetCtxKey struct {
+}
+
+// RoutedSubset 请求被转发到的分组, 未分流或退回全部实例时为空
+func RoutedSubset(c *fiber.Ctx) string {
+	name, _ := c.Locals(subsetCtxKey{}).(string)
+	return name
 }
 
 func NewHttpServerReverseProxy(serviceName string, discovery regdis.Discovery, disExtraMap map[string]any, cfg HostClientConfig) *HttpServerReverseProxy {
@@ -85,6 +102,10 @@ func NewHttpServerReverseProxy(serviceName string, discovery regdis.Discovery, d
 		revProxy.modifyClients(instances)
 	}
 
+	if revProxy.all.Load() == nil {
+		revProxy.all.Store(&upstreamGroup{lbCli: revProxy.lbCli})
+	}
+
 	revProxy.watch()
 
 	return revProxy
@@ -93,14 +114,7 @@ func NewHttpServerReverseProxy(serviceName string, discovery regdis.Discovery, d
 func (srp *HttpServerReverseProxy) ReverseProxy(modifyReqs, modifyResps []fiber.Handler) fiber.Handler {
 	return func(c *fiber.Ctx) error {
 		if srp.unavailable.Load() {
-			isDev := app.GetTheApp().IsDevProfile()
-
-			c.Status(http.StatusServiceUnavailable)
-			if isDev {
-				return c.SendString(fmt.Sprintf("Upstream server:[%s] unavailable", srp.serviceName))
-			} else {
-				return c.SendString("Upstream server unavailable")
-			}
+			return srp.sendUnavailable(c, fmt.Sprintf("Upstream server:[%s] unavailable", srp.serviceName))
 		}
 
 		// Set request and response
@@ -116,14 +130,27 @@ func (srp *HttpServerReverseProxy) ReverseProxy(modifyReqs, modifyResps []fiber.
 			}
 
 			if err := handler(c); err != nil {
+				if errors.Is(err, ErrRequestHandled) {
+					return nil
+				}
+
 				return err
 			}
 		}
 
 		req.SetRequestURI(utils.UnsafeString(req.RequestURI()))
 
+		grp, subset := srp.pickUpstream(c)
+		if grp == nil {
+			return srp.sendUnavailable(c, fmt.Sprintf("Upstream server:[%s] has no instance in subset:[%s]", srp.serviceName, subset))
+		}
+
+		if subset != "" {
+			c.Locals(subsetCtxKey{}, subset)
+		}
+
 		// Forward request
-		if err := srp.lbCli.Do(req, res); err != nil {
+		if err := srp.forward(c, grp); err != nil {
 			return err
 		}
 
@@ -145,6 +172,16 @@ func (srp *HttpServerReverseProxy) ReverseProxy(modifyReqs, modifyResps []fiber.
 	}
 }
 
+// 开发环境返回详细原因
+func (srp *HttpServerReverseProxy) sendUnavailable(c *fiber.Ctx, devMsg string) error {
+	c.Status(http.StatusServiceUnavailable)
+	if app.GetTheApp().IsDevProfile() {
+		return c.SendString(devMsg)
+	}
+
+	return c.SendString("Upstream server unavailable")
+}
+
 func (srp *HttpServerReverseProxy) Shutdown() error {
 	if !srp.stopped.CompareAndSwap(false, true) {
 		return nil
@@ -228,6 +265,8 @@ func (srp *HttpServerReverseProxy) modifyClients(instances []*regdis.Instance) {
 	if len(instances) == 0 {
 		srp.discovered = make([]*regdis.Instance, 0)
 		srp.unavailable.Store(true)
+		srp.lbCli.Clients = nil
+		srp.rebuildGroups()
 		srp.mu.Unlock()
 
 		// remove all host clients
@@ -298,9 +337,12 @@ func (srp *HttpServerReverseProxy) modifyClients(instances []*regdis.Instance) {
 		return beRemoved[hc.Addr]
 	})
 
+	// RemoveClients不会修改Clients字段
 	clients := make([]fasthttp.BalancingClient, 0, len(shouldKeep))
 	for _, cli := range srp.lbCli.Clients {
-		clients = append(clients, cli)
+		if !beRemoved[cli.(*fasthttp.HostClient).Addr] {
+			clients = append(clients, cli)
+		}
 	}
 
 	// add new client
@@ -311,6 +353,8 @@ func (srp *HttpServerReverseProxy) modifyClients(instances []*regdis.Instance) {
 
 	srp.lbCli.Clients = clients
 
+	srp.rebuildGroups()
+
 	lg := mylog.AppLoggerWithListen()
 	if e := lg.Debug(); e.Enabled() {
 		remainAddr := usli.Conv(shouldKeep, func(ins *regdis.Instance) string {
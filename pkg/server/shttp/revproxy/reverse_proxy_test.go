@@ -0,0 +1,152 @@
+package revproxy
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/sweemingdow/gmicro_pkg/pkg/regdis"
+	"github.com/sweemingdow/gmicro_pkg/pkg/regdis/regdismem"
+	"github.com/valyala/fasthttp"
+	"testing"
+)
+
+func discoveredAddrs(srp *HttpServerReverseProxy) map[string]bool {
+	srp.mu.Lock()
+	defer srp.mu.Unlock()
+
+	addrs := make(map[string]bool, len(srp.discovered))
+	for _, ins := range srp.discovered {
+		addrs[ins.InsIdentity()] = true
+	}
+
+	return addrs
+}
+
+func TestReverseProxyFollowsDiscovery(t *testing.T) {
+	const service = "one_service"
+
+	mrd := regdismem.NewMemRegDis(true)
+	defer mrd.Close()
+
+	srp := NewHttpServerReverseProxy(service, mrd, nil, HostClientConfig{})
+	defer srp.Shutdown()
+
+	if !srp.unavailable.Load() {
+		// 服务未注册时Discover返回空列表
+		t.Fatal("proxy should be unavailable without instances")
+	}
+
+	md := map[string]string{regdis.MetadataHttpPort: "8081"}
+	_ = mrd.Register(regdis.RegisterParam{ServiceName: service, Addr: "127.0.0.1:9091", Metadata: md})
+	_ = mrd.Register(regdis.RegisterParam{ServiceName: service, Addr: "127.0.0.2:9091", Metadata: md})
+	mrd.WaitIdle()
+
+	addrs := discoveredAddrs(srp)
+	if len(addrs) != 2 || !addrs["127.0.0.1:8081"] || srp.unavailable.Load() {
+		t.Fatalf("unexpected discovered:%v", addrs)
+	}
+
+	if len(srp.lbCli.Clients) != 2 {
+		t.Fatalf("expect 2 host clients, got:%d", len(srp.lbCli.Clients))
+	}
+
+	mrd.EmitEmpty(service)
+	mrd.WaitIdle()
+	if !srp.unavailable.Load() {
+		t.Fatal("proxy should be unavailable after empty instances")
+	}
+
+	mrd.Flap(service, 2, 0)
+	_ = mrd.Deregister(regdis.DeregisterParam{ServiceName: service, Addr: "127.0.0.1:9091"})
+	mrd.WaitIdle()
+
+	addrs = discoveredAddrs(srp)
+	if len(addrs) != 1 || !addrs["127.0.0.2:8081"] || srp.unavailable.Load() {
+		t.Fatalf("unexpected discovered after deregister:%v", addrs)
+	}
+}
+
+func TestReverseProxyTrafficSplit(t *testing.T) {
+	const service = "split_service"
+
+	mrd := regdismem.NewMemRegDis(true)
+	defer mrd.Close()
+
+	register := func(addr, version string) {
+		_ = mrd.Register(regdis.RegisterParam{
+			ServiceName: service,
+			Addr:        addr,
+			Metadata:    map[string]string{regdis.MetadataHttpPort: "8081", "version": version},
+		})
+	}
+
+	register("127.0.0.1:9091", "v1")
+
+	srp := NewHttpServerReverseProxy(service, mrd, nil, HostClientConfig{})
+	defer srp.Shutdown()
+
+	srp.SetTrafficSplit(&TrafficSplit{
+		Subsets: []Subset{
+			{Name: "stable", Metadata: map[string]string{"version": "v1"}, Weight: 1},
+			{Name: "canary", Metadata: map[string]string{"version": "v2"}},
+		},
+		Pin: func(c *fiber.Ctx) string {
+			return c.Get("X-Subset")
+		},
+	})
+
+	fa := fiber.New()
+	pick := func(pin string) (string, string) {
+		c := fa.AcquireCtx(&fasthttp.RequestCtx{})
+		defer fa.ReleaseCtx(c)
+
+		c.Request().Header.Set("X-Subset", pin)
+
+		grp, subset := srp.pickUpstream(c)
+		if grp == nil || len(grp.hosts) != 1 {
+			return "", subset
+		}
+
+		return grp.hosts[0].Addr, subset
+	}
+
+	// 固定的灰度分组无实例时不退回全部实例
+	if addr, subset := pick("canary"); addr != "" || subset != "canary" {
+		t.Fatalf("expect no upstream for pinned empty subset, got:%s, %s", addr, subset)
+	}
+
+	// 按权重选中的分组无实例时退回全部实例
+	srp.SetTrafficSplit(&TrafficSplit{
+		Subsets: []Subset{{Name: "canary", Metadata: map[string]string{"version": "v2"}, Weight: 1}},
+	})
+	if addr, subset := pick(""); addr != "127.0.0.1:8081" || subset != "" {
+		t.Fatalf("expect fallback to all instances, got:%s, %s", addr, subset)
+	}
+
+	srp.SetTrafficSplit(&TrafficSplit{
+		Subsets: []Subset{
+			{Name: "stable", Metadata: map[string]string{"version": "v1"}, Weight: 1},
+			{Name: "canary", Metadata: map[string]string{"version": "v2"}},
+		},
+		Pin: func(c *fiber.Ctx) string {
+			return c.Get("X-Subset")
+		},
+	})
+
+	register("127.0.0.2:9091", "v2")
+	mrd.WaitIdle()
+
+	if addr, subset := pick("canary"); addr != "127.0.0.2:8081" || subset != "canary" {
+		t.Fatalf("expect pinned to canary, got:%s, %s", addr, subset)
+	}
+
+	// canary权重为0, 只能通过Pin访问
+	for i := 0; i < 10; i++ {
+		if addr, subset := pick(""); addr != "127.0.0.1:8081" || subset != "stable" {
+			t.Fatalf("expect stable by weight, got:%s, %s", addr, subset)
+		}
+	}
+
+	srp.SetTrafficSplit(nil)
+	if _, subset := pick("canary"); subset != "" {
+		t.Fatalf("expect split disabled, got:%s", subset)
+	}
+}
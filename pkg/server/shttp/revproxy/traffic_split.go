@@ -0,0 +1,166 @@
+package revproxy
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/sweemingdow/gmicro_pkg/pkg/regdis"
+	"github.com/valyala/fasthttp"
+	"math/rand/v2"
+)
+
+// Subset 按实例metadata选出的一组实例, 如version=v2
+type Subset struct {
+	Name string
+
+	// 实例metadata包含全部键值时属于该分组, 为空时包含全部实例
+	Metadata map[string]string
+
+	// 为0时只能通过Pin访问
+	Weight int
+}
+
+// TrafficSplit 按权重在分组间分流
+// Pin返回分组名时直接使用该分组, 如测试人员通过header固定访问灰度版本, 该分组没有实例时返回503
+// 按权重选中的分组没有实例时退回全部实例
+type TrafficSplit struct {
+	Subsets []Subset
+	Pin     func(c *fiber.Ctx) string
+}
+
+type subsetClient struct {
+	Subset
+	upstreamGroup
+}
+
+// 一组实例, 构建后不再修改
+type upstreamGroup struct {
+	lbCli *fasthttp.LBClient
+	hosts []*fasthttp.HostClient
+}
+
+type splitState struct {
+	ts          *TrafficSplit
+	subsets     []subsetClient
+	totalWeight int
+}
+
+// SetTrafficSplit 设置分流规则, nil时在全部实例间均衡
+func (srp *HttpServerReverseProxy) SetTrafficSplit(ts *TrafficSplit) {
+	srp.mu.Lock()
+	defer srp.mu.Unlock()
+
+	srp.ts = ts
+	srp.rebuildGroups()
+}
+
+// 实例变化或分流规则变化时重建, 需持有mu
+func (srp *HttpServerReverseProxy) rebuildGroups() {
+	addr2cli := make(map[string]*fasthttp.HostClient, len(srp.lbCli.Clients))
+	hosts := make([]*fasthttp.HostClient, 0, len(srp.lbCli.Clients))
+	for _, bc := range srp.lbCli.Clients {
+		hc := bc.(*fasthttp.HostClient)
+		addr2cli[hc.Addr] = hc
+		hosts = append(hosts, hc)
+	}
+
+	srp.all.Store(&upstreamGroup{lbCli: srp.lbCli, hosts: hosts})
+
+	if srp.ts == nil || len(srp.ts.Subsets) == 0 {
+		srp.split.Store(nil)
+		return
+	}
+
+	ss := &splitState{
+		ts:      srp.ts,
+		subsets: make([]subsetClient, 0, len(srp.ts.Subsets)),
+	}
+
+	for _, sub := range srp.ts.Subsets {
+		var (
+			clients  = make([]fasthttp.BalancingClient, 0)
+			subHosts = make([]*fasthttp.HostClient, 0)
+		)
+
+		for _, ins := range srp.discovered {
+			if !metadataMatched(ins, sub.Metadata) {
+				continue
+			}
+
+			if hc, ok := addr2cli[ins.InsIdentity()]; ok {
+				clients = append(clients, hc)
+				subHosts = append(subHosts, hc)
+			}
+		}
+
+		ss.subsets = append(ss.subsets, subsetClient{
+			Subset: sub,
+			upstreamGroup: upstreamGroup{
+				lbCli: &fasthttp.LBClient{
+					Timeout: srp.lbCli.Timeout,
+					Clients: clients,
+				},
+				hosts: subHosts,
+			},
+		})
+		ss.totalWeight += max(sub.Weight, 0)
+	}
+
+	srp.split.Store(ss)
+}
+
+func metadataMatched(ins *regdis.Instance, md map[string]string) bool {
+	for k, v := range md {
+		if ins.Metadata[k] != v {
+			return false
+		}
+	}
+
+	return true
+}
+
+// 返回选中的分组名, 未分流时为空; 固定的分组没有实例时返回nil
+func (srp *HttpServerReverseProxy) pickUpstream(c *fiber.Ctx) (*upstreamGroup, string) {
+	all := srp.all.Load()
+
+	ss := srp.split.Load()
+	if ss == nil {
+		return all, ""
+	}
+
+	var chosen *subsetClient
+
+	if ss.ts.Pin != nil {
+		if name := ss.ts.Pin(c); name != "" {
+			for i := range ss.subsets {
+				if ss.subsets[i].Name == name {
+					chosen = &ss.subsets[i]
+					break
+				}
+			}
+		}
+	}
+
+	// 固定访问的分组不退回全部实例, 避免请求被静默转发到其他版本
+	if chosen != nil {
+		if len(chosen.hosts) == 0 {
+			return nil, chosen.Name
+		}
+
+		return &chosen.upstreamGroup, chosen.Name
+	}
+
+	if ss.totalWeight > 0 {
+		n := rand.IntN(ss.totalWeight)
+		for i := range ss.subsets {
+			if n -= max(ss.subsets[i].Weight, 0); n < 0 {
+				chosen = &ss.subsets[i]
+				break
+			}
+		}
+	}
+
+	if chosen == nil || len(chosen.hosts) == 0 {
+		return all, ""
+	}
+
+	return &chosen.upstreamGroup, chosen.Name
+}
@@ -5,6 +5,14 @@ import "github.com/sweemingdow/gmicro_pkg/pkg/parser/json"
 type JsonIterCodec struct {
 }
 
+func (jic JsonIterCodec) Name() string {
+	return CodecJson
+}
+
+func (jic JsonIterCodec) Magic() byte {
+	return 0
+}
+
 func (jic JsonIterCodec) Marshal(v interface{}) ([]byte, error) {
 	return json.Fmt(v)
 }
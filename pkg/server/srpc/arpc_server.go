@@ -2,6 +2,7 @@ package srpc
 
 import (
 	"context"
+	"crypto/tls"
 	"errors"
 	"fmt"
 	"github.com/lesismal/arpc"
@@ -11,6 +12,7 @@ import (
 	"github.com/sweemingdow/gmicro_pkg/pkg/server/srpc/rpccall"
 	"net"
 	"strings"
+	"sync/atomic"
 	"time"
 )
 
@@ -21,34 +23,81 @@ func DiDa(c *arpc.Context) error {
 }
 
 type ArpcServer struct {
-	srv  *arpc.Server
-	port int
+	srv       *arpc.Server
+	port      int
+	codecs    []Codec
+	tls       *TlsReloader // 为nil时不启用tls
+	listening atomic.Bool
+	closed    atomic.Bool
 }
 
-func NewArpcServer(port int) *ArpcServer {
+type ArpcServerOption func(ars *ArpcServer)
+
+// WithCodecs 服务端接受的编解码器, 通过注册中心metadata告知客户端, 响应使用第一个编码
+// 旧版本客户端只支持json, 存在旧客户端时第一个应为json
+func WithCodecs(codecs ...Codec) ArpcServerOption {
+	return func(ars *ArpcServer) {
+		if len(codecs) > 0 {
+			ars.codecs = codecs
+		}
+	}
+}
+
+func NewArpcServer(port int, opts ...ArpcServerOption) *ArpcServer {
 	mylog.AddModuleLoggerWithFrame("arpcServer", 3)
 
+	ars := &ArpcServer{
+		port:   port,
+		codecs: []Codec{JsonIterCodec{}},
+	}
+
+	for _, opt := range opts {
+		opt(ars)
+	}
+
 	rpcSrv := arpc.NewServer()
 
-	rpcSrv.Codec = JsonIterCodec{}
+	rpcSrv.Codec = ArpcCodec(ars.codecs[0])
+	rpcSrv.Handler.UseCoder(MetaCoder{})
 	rpcSrv.Handler.SetLogTag(fmt.Sprintf("[%s]", app.GetTheApp().GetAppName()))
 
-	return &ArpcServer{
-		srv:  rpcSrv,
-		port: port,
-	}
+	ars.srv = rpcSrv
+
+	return ars
 }
 
 func (ars *ArpcServer) GetArpcSrv() *arpc.Server {
 	return ars.srv
 }
 
+// CodecNames 用于注册中心metadata, 见regdis.MetadataRpcCodecs
+func (ars *ArpcServer) CodecNames() string {
+	names := make([]string, 0, len(ars.codecs))
+	for _, c := range ars.codecs {
+		names = append(names, c.Name())
+	}
+
+	return strings.Join(names, ",")
+}
+
 func (ars *ArpcServer) OnCreated(ec chan<- error) {
 	lg := mylog.AppLogger()
 	lg.Debug().Msgf("arpc rpc server start now, port:%d", ars.port)
 
+	ln, err := net.Listen("tcp", fmt.Sprintf(":%d", ars.port))
+	if err != nil {
+		ec <- err
+		return
+	}
+
+	if ars.tls != nil {
+		ln = tls.NewListener(ln, ars.tls.ServerConfig())
+	}
+
+	ars.listening.Store(true)
+
 	go func() {
-		if err := ars.srv.Run(fmt.Sprintf(":%d", ars.port)); err != nil {
+		if err := ars.srv.Serve(ln); err != nil {
 			ilg := mylog.AppLogger()
 
 			if strings.Contains(err.Error(), "use of closed network connection") {
@@ -67,7 +116,21 @@ func (ars *ArpcServer) OnCreated(ec chan<- error) {
 	}()
 }
 
+func (ars *ArpcServer) HealthCheck(_ context.Context) error {
+	if ars.closed.Load() {
+		return errors.New("arpc rpc server closed")
+	}
+
+	if !ars.listening.Load() {
+		return fmt.Errorf("arpc rpc server not listening yet, port:%d", ars.port)
+	}
+
+	return nil
+}
+
 func (ars *ArpcServer) OnDispose(ctx context.Context) error {
+	ars.closed.Store(true)
+
 	lg := mylog.AppLogger()
 	lg.Debug().Msg("arpc rpc server stop now")
 
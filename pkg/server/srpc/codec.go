@@ -0,0 +1,406 @@
+package srpc
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	acodec "github.com/lesismal/arpc/codec"
+	"github.com/sweemingdow/gmicro_pkg/pkg/parser/json"
+	"github.com/vmihailenco/msgpack/v5"
+	"google.golang.org/protobuf/proto"
+	"io"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+const (
+	CodecJson     = "json"
+	CodecMsgpack  = "msgpack"
+	CodecProtobuf = "protobuf"
+	CodecGzipJson = "gzip_json"
+)
+
+// 报文首字节, 接收方据此选择解码器, json不加标识以兼容旧版本
+const (
+	magicMsgpack  byte = 0x01
+	magicProtobuf byte = 0x02
+	magicGzipJson byte = 0x03
+)
+
+const DefaultGzipThreshold = 4 << 10
+
+// Codec 可插拔的编解码器
+// 编码结果自带首字节标识(json除外), 因此接收方无需事先知道对端使用的编码, 见Decode
+type Codec interface {
+	Name() string
+
+	// Magic 编码结果的首字节, 0表示不加标识
+	Magic() byte
+
+	Marshal(v any) ([]byte, error)
+
+	Unmarshal(data []byte, v any) error
+}
+
+var (
+	codecMu       sync.RWMutex
+	codecsByName  = map[string]Codec{}
+	codecsByMagic = map[byte]Codec{}
+)
+
+func init() {
+	RegisterCodec(JsonIterCodec{})
+	RegisterCodec(MsgpackCodec{})
+	RegisterCodec(ProtobufCodec{})
+	RegisterCodec(GzipJsonCodec{Threshold: DefaultGzipThreshold})
+}
+
+// RegisterCodec 注册编解码器, 同名覆盖, Magic不能与其他编解码器冲突
+func RegisterCodec(c Codec) {
+	codecMu.Lock()
+	defer codecMu.Unlock()
+
+	if m := c.Magic(); m != 0 {
+		if exists, ok := codecsByMagic[m]; ok && exists.Name() != c.Name() {
+			panic(fmt.Sprintf("codec magic 0x%02x of %s conflicts with %s", m, c.Name(), exists.Name()))
+		}
+		codecsByMagic[m] = c
+	}
+
+	codecsByName[c.Name()] = c
+}
+
+func CodecByName(name string) (Codec, bool) {
+	codecMu.RLock()
+	defer codecMu.RUnlock()
+
+	c, ok := codecsByName[name]
+	return c, ok
+}
+
+// Decode 按首字节选择解码器, 无标识时按json解码
+func Decode(data []byte, v any) error {
+	if len(data) > 0 {
+		codecMu.RLock()
+		c, ok := codecsByMagic[data[0]]
+		codecMu.RUnlock()
+
+		if ok {
+			return c.Unmarshal(data, v)
+		}
+	}
+
+	return json.Parse(data, v)
+}
+
+// SelectCodec 为实例选择编解码器
+// 实例在metadata中声明了可接受的编解码器时, 取preferred中第一个被声明的, 都不满足时取实例声明的第一个
+// 未声明的实例(旧版本)只接受json
+func SelectCodec(preferred []string, advertised string) Codec {
+	var accepted []string
+	for _, name := range strings.Split(advertised, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			if _, ok := CodecByName(name); ok {
+				accepted = append(accepted, name)
+			}
+		}
+	}
+
+	if len(accepted) == 0 {
+		return JsonIterCodec{}
+	}
+
+	for _, name := range preferred {
+		for _, acc := range accepted {
+			if name == acc {
+				c, _ := CodecByName(name)
+				return c
+			}
+		}
+	}
+
+	c, _ := CodecByName(accepted[0])
+	return c
+}
+
+// ArpcCodec 以c编码, 解码时兼容所有已注册的编解码器, 用于arpc.Server和arpc.Client
+func ArpcCodec(c Codec) acodec.Codec {
+	return arpcCodec{enc: c}
+}
+
+type arpcCodec struct {
+	enc Codec
+}
+
+func (ac arpcCodec) Marshal(v interface{}) ([]byte, error) {
+	return ac.enc.Marshal(v)
+}
+
+func (ac arpcCodec) Unmarshal(data []byte, v interface{}) error {
+	return Decode(data, v)
+}
+
+func frame(magic byte, payload []byte) []byte {
+	data := make([]byte, len(payload)+1)
+	data[0] = magic
+	copy(data[1:], payload)
+	return data
+}
+
+func unframe(magic byte, data []byte) ([]byte, bool) {
+	if len(data) == 0 || data[0] != magic {
+		return data, false
+	}
+
+	return data[1:], true
+}
+
+// MsgpackCodec 字段名沿用json tag, 与json编码的结构保持一致
+type MsgpackCodec struct {
+}
+
+func (MsgpackCodec) Name() string {
+	return CodecMsgpack
+}
+
+func (MsgpackCodec) Magic() byte {
+	return magicMsgpack
+}
+
+func (MsgpackCodec) Marshal(v any) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte(magicMsgpack)
+
+	enc := msgpack.NewEncoder(&buf)
+	enc.SetCustomStructTag("json")
+	enc.SetOmitEmpty(true)
+
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+func (MsgpackCodec) Unmarshal(data []byte, v any) error {
+	payload, ok := unframe(magicMsgpack, data)
+	if !ok {
+		return json.Parse(data, v)
+	}
+
+	dec := msgpack.NewDecoder(bytes.NewReader(payload))
+	dec.SetCustomStructTag("json")
+
+	return dec.Decode(v)
+}
+
+// ProtobufCodec 用于protoc生成的类型, 非proto.Message的值(如通用错误响应)按json编码
+// 业务数据为proto.Message的PayloadWrapper(如rpccall.RpcReqWrapper[*pb.User])同样以protobuf编码业务数据
+type ProtobufCodec struct {
+}
+
+// PayloadWrapper 包装业务数据的类型, 如rpccall.RpcReqWrapper, rpccall.RpcRespWrapper
+type PayloadWrapper interface {
+	// PayloadPtr 业务数据字段的指针
+	PayloadPtr() any
+}
+
+var protoMessageType = reflect.TypeOf((*proto.Message)(nil)).Elem()
+
+func (ProtobufCodec) Name() string {
+	return CodecProtobuf
+}
+
+func (ProtobufCodec) Magic() byte {
+	return magicProtobuf
+}
+
+func (ProtobufCodec) Marshal(v any) ([]byte, error) {
+	if pm, ok := v.(proto.Message); ok {
+		payload, err := proto.Marshal(pm)
+		if err != nil {
+			return nil, err
+		}
+
+		return frame(magicProtobuf, payload), nil
+	}
+
+	if data, ok, err := marshalProtoWrapper(v); ok {
+		return data, err
+	}
+
+	return json.Fmt(v)
+}
+
+func (ProtobufCodec) Unmarshal(data []byte, v any) error {
+	payload, ok := unframe(magicProtobuf, data)
+	if !ok {
+		return json.Parse(data, v)
+	}
+
+	if pm, ok := v.(proto.Message); ok {
+		return proto.Unmarshal(payload, pm)
+	}
+
+	if pw, ok := v.(PayloadWrapper); ok {
+		return unmarshalProtoWrapper(payload, pw)
+	}
+
+	return fmt.Errorf("protobuf payload can not be decoded into %T", v)
+}
+
+// 包装类型的编码: magic, 业务数据是否为nil(1字节), 其余字段json的长度(uvarint), 其余字段json, 业务数据protobuf
+// 业务数据不是proto.Message时返回false, 由调用方按json编码
+func marshalProtoWrapper(v any) ([]byte, bool, error) {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() == reflect.Ptr {
+		if rv.IsNil() {
+			return nil, false, nil
+		}
+		rv = rv.Elem()
+	}
+
+	// 复制一份, 清空业务数据后编码其余字段, 不修改调用方的值
+	cp := reflect.New(rv.Type())
+	cp.Elem().Set(rv)
+
+	pw, ok := cp.Interface().(PayloadWrapper)
+	if !ok {
+		return nil, false, nil
+	}
+
+	field := reflect.ValueOf(pw.PayloadPtr()).Elem()
+	if field.Kind() != reflect.Ptr || !field.Type().Implements(protoMessageType) {
+		return nil, false, nil
+	}
+
+	var (
+		present = !field.IsNil()
+		payload []byte
+		err     error
+	)
+
+	if present {
+		if payload, err = proto.Marshal(field.Interface().(proto.Message)); err != nil {
+			return nil, true, err
+		}
+	}
+
+	field.Set(reflect.Zero(field.Type()))
+
+	header, err := json.Fmt(cp.Interface())
+	if err != nil {
+		return nil, true, err
+	}
+
+	data := make([]byte, 0, 2+binary.MaxVarintLen64+len(header)+len(payload))
+	data = append(data, magicProtobuf)
+	if present {
+		data = append(data, 1)
+	} else {
+		data = append(data, 0)
+	}
+	data = binary.AppendUvarint(data, uint64(len(header)))
+	data = append(data, header...)
+	data = append(data, payload...)
+
+	return data, true, nil
+}
+
+func unmarshalProtoWrapper(data []byte, pw PayloadWrapper) error {
+	if len(data) == 0 {
+		return errors.New("protobuf wrapper payload is empty")
+	}
+
+	present := data[0] == 1
+
+	size, n := binary.Uvarint(data[1:])
+	if n <= 0 || uint64(len(data)-1-n) < size {
+		return errors.New("invalid protobuf wrapper payload")
+	}
+
+	header := data[1+n : 1+n+int(size)]
+	payload := data[1+n+int(size):]
+
+	if err := json.Parse(header, pw); err != nil {
+		return err
+	}
+
+	field := reflect.ValueOf(pw.PayloadPtr()).Elem()
+	if field.Kind() != reflect.Ptr || !field.Type().Implements(protoMessageType) {
+		return fmt.Errorf("protobuf payload can not be decoded into %s", field.Type())
+	}
+
+	if !present {
+		field.Set(reflect.Zero(field.Type()))
+		return nil
+	}
+
+	msg := reflect.New(field.Type().Elem())
+	if err := proto.Unmarshal(payload, msg.Interface().(proto.Message)); err != nil {
+		return err
+	}
+
+	field.Set(msg)
+	return nil
+}
+
+// GzipJsonCodec json编码后超过Threshold字节时gzip压缩, 否则与json相同
+type GzipJsonCodec struct {
+	Threshold int
+}
+
+func (GzipJsonCodec) Name() string {
+	return CodecGzipJson
+}
+
+func (GzipJsonCodec) Magic() byte {
+	return magicGzipJson
+}
+
+func (gjc GzipJsonCodec) Marshal(v any) ([]byte, error) {
+	data, err := json.Fmt(v)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(data) <= gjc.Threshold {
+		return data, nil
+	}
+
+	var buf bytes.Buffer
+	buf.WriteByte(magicGzipJson)
+
+	zw := gzip.NewWriter(&buf)
+	if _, err = zw.Write(data); err != nil {
+		return nil, err
+	}
+	if err = zw.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+func (GzipJsonCodec) Unmarshal(data []byte, v any) error {
+	payload, ok := unframe(magicGzipJson, data)
+	if !ok {
+		return json.Parse(data, v)
+	}
+
+	zr, err := gzip.NewReader(bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	defer zr.Close()
+
+	raw, err := io.ReadAll(zr)
+	if err != nil {
+		return err
+	}
+
+	return json.Parse(raw, v)
+}
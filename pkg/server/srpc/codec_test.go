@@ -0,0 +1,91 @@
+package srpc
+
+import (
+	acodec "github.com/lesismal/arpc/codec"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+	"strings"
+	"testing"
+)
+
+type codecPayload struct {
+	Name  string            `json:"name"`
+	Count int               `json:"count"`
+	Tags  map[string]string `json:"tags,omitempty"`
+}
+
+func TestCodecRoundTrip(t *testing.T) {
+	small := codecPayload{Name: "tom", Count: 3, Tags: map[string]string{"k": "v"}}
+	large := codecPayload{Name: strings.Repeat("x", DefaultGzipThreshold), Count: 1}
+
+	cases := []struct {
+		codec  Codec
+		val    codecPayload
+		framed bool
+	}{
+		{JsonIterCodec{}, small, false},
+		{MsgpackCodec{}, small, true},
+		{GzipJsonCodec{Threshold: DefaultGzipThreshold}, small, false},
+		{GzipJsonCodec{Threshold: DefaultGzipThreshold}, large, true},
+		// 非proto.Message按json编码
+		{ProtobufCodec{}, small, false},
+	}
+
+	for _, cs := range cases {
+		data, err := cs.codec.Marshal(&cs.val)
+		if err != nil {
+			t.Fatalf("%s marshal: %v", cs.codec.Name(), err)
+		}
+
+		if framed := data[0] == cs.codec.Magic(); framed != cs.framed {
+			t.Fatalf("%s expect framed:%v, got first byte:0x%02x", cs.codec.Name(), cs.framed, data[0])
+		}
+
+		// 接收方不知道对端编码
+		var got codecPayload
+		if err = Decode(data, &got); err != nil {
+			t.Fatalf("%s decode: %v", cs.codec.Name(), err)
+		}
+
+		if got.Name != cs.val.Name || got.Count != cs.val.Count || len(got.Tags) != len(cs.val.Tags) {
+			t.Fatalf("%s expect %+v, got %+v", cs.codec.Name(), cs.val, got)
+		}
+	}
+
+	data, err := ProtobufCodec{}.Marshal(wrapperspb.String("hi"))
+	if err != nil || data[0] != magicProtobuf {
+		t.Fatalf("expect protobuf frame, data:%v, err:%v", data, err)
+	}
+
+	var sv wrapperspb.StringValue
+	if err = Decode(data, &sv); err != nil || sv.GetValue() != "hi" {
+		t.Fatalf("expect hi, got %q, err:%v", sv.GetValue(), err)
+	}
+}
+
+func TestSelectCodec(t *testing.T) {
+	cases := []struct {
+		preferred  []string
+		advertised string
+		expect     string
+	}{
+		{nil, "", CodecJson},
+		{[]string{CodecMsgpack}, "", CodecJson},
+		{[]string{CodecMsgpack}, "json,msgpack", CodecMsgpack},
+		{[]string{"unknown", CodecGzipJson}, "msgpack, gzip_json", CodecGzipJson},
+		{[]string{CodecProtobuf}, "msgpack,json", CodecMsgpack},
+		{nil, "unknown,json", CodecJson},
+	}
+
+	for _, cs := range cases {
+		if got := SelectCodec(cs.preferred, cs.advertised).Name(); got != cs.expect {
+			t.Fatalf("preferred:%v advertised:%q expect %s, got %s", cs.preferred, cs.advertised, cs.expect, got)
+		}
+	}
+}
+
+// 不修改arpc进程级的默认编解码器, 由Server和Client各自配置
+func TestArpcDefaultCodecUntouched(t *testing.T) {
+	if _, ok := acodec.DefaultCodec.(*acodec.JSONCodec); !ok {
+		t.Fatalf("arpc default codec replaced by %T", acodec.DefaultCodec)
+	}
+}
@@ -0,0 +1,139 @@
+package srpc
+
+import (
+	"fmt"
+	"github.com/lesismal/arpc"
+	"github.com/sweemingdow/gmicro_pkg/pkg/mylog"
+	"github.com/sweemingdow/gmicro_pkg/pkg/server/srpc/rpccall"
+	"runtime/debug"
+	"time"
+)
+
+// Interceptor 服务端拦截器, 调用next执行后续拦截器和handler, 不调用则中断
+type Interceptor func(c *arpc.Context, next arpc.HandlerFunc)
+
+// Chain 将拦截器按顺序包装到handler外层, 可直接用于srv.Handler.Handle注册单个路由
+func Chain(h arpc.HandlerFunc, interceptors ...Interceptor) arpc.HandlerFunc {
+	for i := len(interceptors) - 1; i >= 0; i-- {
+		icp, next := interceptors[i], h
+		if icp == nil {
+			continue
+		}
+
+		h = func(c *arpc.Context) {
+			icp(c, next)
+		}
+	}
+
+	return h
+}
+
+// Use 注册全局拦截器, 作用于所有路由, 必须在注册路由之前调用
+func (ars *ArpcServer) Use(interceptors ...Interceptor) {
+	for _, icp := range interceptors {
+		if icp == nil {
+			continue
+		}
+
+		icp := icp
+
+		// arpc的中间件在执行后自动调用Next, 此处在拦截器内部调用Next, 使其可以包裹后续的处理
+		// 拦截器未调用next时中断后续处理
+		ars.srv.Handler.Use(func(c *arpc.Context) {
+			called := false
+			icp(c, func(c *arpc.Context) {
+				called = true
+				c.Next()
+			})
+
+			if !called {
+				c.Abort()
+			}
+		})
+	}
+}
+
+// Handle 注册路由, interceptors只作用于该路由, 在全局拦截器之后执行
+func (ars *ArpcServer) Handle(method string, h arpc.HandlerFunc, interceptors ...Interceptor) {
+	ars.srv.Handler.Handle(method, Chain(h, interceptors...))
+}
+
+func metaValue(c *arpc.Context, key string) string {
+	val, _ := c.Get(key)
+	str, _ := val.(string)
+	return str
+}
+
+// Recovery 捕获handler的panic, 记录日志, 请求类消息返回ServerUnpredictableErr
+func Recovery() Interceptor {
+	return func(c *arpc.Context, next arpc.HandlerFunc) {
+		defer func() {
+			if r := recover(); r != nil {
+				c.Abort()
+
+				lg := mylog.AppLoggerWithRpc()
+				lg.Error().
+					Str("method", c.Message.Method()).
+					Str("request_id", metaValue(c, MetaKeyRequestId)).
+					Msgf("arpc handler panic:%v\n%s", r, debug.Stack())
+
+				if c.Message.Cmd() == arpc.CmdRequest {
+					WriteLoggedIfError(c, rpccall.SimpleUnpredictableErr(fmt.Errorf("panic:%v", r)))
+				}
+			}
+		}()
+
+		next(c)
+	}
+}
+
+// AccessLog 记录每次调用的方法, 调用方, 请求id, 耗时, skipMethods中的方法不记录(如健康检查)
+func AccessLog(skipMethods ...string) Interceptor {
+	skip := make(map[string]struct{}, len(skipMethods))
+	for _, m := range skipMethods {
+		skip[m] = struct{}{}
+	}
+
+	return func(c *arpc.Context, next arpc.HandlerFunc) {
+		method := c.Message.Method()
+		if _, ok := skip[method]; ok {
+			next(c)
+			return
+		}
+
+		start := time.Now()
+		next(c)
+
+		lg := mylog.AppLoggerWithAccess()
+		e := lg.Info()
+		if respErr := c.ResponseError(); respErr != nil {
+			e = lg.Warn().Any("resp_err", respErr)
+		}
+
+		e.Str("method", method).
+			Str("remote", c.Client.Conn.RemoteAddr().String()).
+			Str("request_id", metaValue(c, MetaKeyRequestId)).
+			Str("caller", metaValue(c, MetaKeyCallerAppId)).
+			Int("req_bytes", len(c.Body())).
+			Dur("took", time.Since(start)).
+			Send()
+	}
+}
+
+// Timing 统计handler耗时, 超过slowThreshold时记录慢调用日志
+func Timing(slowThreshold time.Duration) Interceptor {
+	return func(c *arpc.Context, next arpc.HandlerFunc) {
+		start := time.Now()
+		next(c)
+
+		took := time.Since(start)
+		if slowThreshold > 0 && took >= slowThreshold {
+			lg := mylog.AppLoggerWithRpc()
+			lg.Warn().
+				Str("method", c.Message.Method()).
+				Str("request_id", metaValue(c, MetaKeyRequestId)).
+				Dur("took", took).
+				Msgf("slow arpc call, threshold:%v", slowThreshold)
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package srpc
+
+import (
+	"github.com/lesismal/arpc"
+	"github.com/sweemingdow/gmicro_pkg/pkg/server/srpc/rpccall"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestArpcServerInterceptors(t *testing.T) {
+	ars := &ArpcServer{srv: arpc.NewServer()}
+	ars.srv.Codec = JsonIterCodec{}
+
+	var (
+		mu    sync.Mutex
+		trace []string
+	)
+
+	record := func(name string) Interceptor {
+		return func(c *arpc.Context, next arpc.HandlerFunc) {
+			mu.Lock()
+			trace = append(trace, name+">")
+			mu.Unlock()
+
+			next(c)
+
+			mu.Lock()
+			trace = append(trace, "<"+name)
+			mu.Unlock()
+		}
+	}
+
+	ars.Use(Recovery(), record("g"))
+
+	ars.Handle("/echo", func(c *arpc.Context) {
+		_ = c.Write("ok")
+	}, record("r"))
+
+	ars.Handle("/deny", func(c *arpc.Context) {
+		_ = c.Write("should not reach")
+	}, func(c *arpc.Context, next arpc.HandlerFunc) {
+		_ = c.Write(rpccall.SimpleErrDesc("denied"))
+	})
+
+	ars.Handle("/panic", func(c *arpc.Context) {
+		panic("boom")
+	})
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	go func() {
+		_ = ars.srv.Serve(ln)
+	}()
+	defer ars.srv.Stop()
+
+	cli, err := arpc.NewClient(func() (net.Conn, error) {
+		return net.DialTimeout("tcp", ln.Addr().String(), time.Second)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cli.Stop()
+	cli.Codec = JsonIterCodec{}
+
+	var resp string
+	if err = cli.Call("/echo", "", &resp, time.Second); err != nil || resp != "ok" {
+		t.Fatalf("echo failed, resp:%s, err:%v", resp, err)
+	}
+
+	mu.Lock()
+	got := strings.Join(trace, ",")
+	mu.Unlock()
+	if got != "g>,r>,<r,<g" {
+		t.Fatalf("unexpected interceptor order:%s", got)
+	}
+
+	var rs rpccall.RpcRespSimple
+	if err = cli.Call("/deny", "", &rs, time.Second); err != nil || rs.ErrDesc != "denied" {
+		t.Fatalf("expect short circuited, resp:%+v, err:%v", rs, err)
+	}
+
+	rs = rpccall.RpcRespSimple{}
+	if err = cli.Call("/panic", "", &rs, time.Second); err != nil || rs.Code != rpccall.ServerUnpredictableErr {
+		t.Fatalf("expect panic recovered, resp:%+v, err:%v", rs, err)
+	}
+}
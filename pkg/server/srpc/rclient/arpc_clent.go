@@ -1,6 +1,8 @@
 package rclient
 
 import (
+	"context"
+	"crypto/tls"
 	"fmt"
 	"github.com/lesismal/arpc"
 	"github.com/sweemingdow/gmicro_pkg/pkg/app"
@@ -12,33 +14,152 @@ import (
 )
 
 type arpcClientWrap struct {
-	ins    *regdis.Instance
-	cli    *arpc.Client
-	weight float64
+	// 实例标识不变, 权重和metadata随注册中心更新
+	ins    atomic.Pointer[regdis.Instance]
+	pool   *connPool
 	closed atomic.Bool
+
+	// 按实例metadata选择的编解码器
+	codec srpc.Codec
+
+	// 进行中的调用数
+	inflight atomic.Int64
+
+	// 未启用熔断时为nil
+	breaker *circuitBreaker
+}
+
+// Dialer 建立到实例的连接
+type Dialer func(ins *regdis.Instance, timeout time.Duration) (net.Conn, error)
+
+func tcpDialer(ins *regdis.Instance, timeout time.Duration) (net.Conn, error) {
+	return net.DialTimeout("tcp", fmt.Sprintf("%s:%d", ins.Ip, ins.Port), timeout)
+}
+
+// 在dialer建立的连接上完成tls握手, 握手耗时计入timeout
+func tlsDialer(dialer Dialer, tr *srpc.TlsReloader) Dialer {
+	return func(ins *regdis.Instance, timeout time.Duration) (net.Conn, error) {
+		start := time.Now()
+
+		conn, err := dialer(ins, timeout)
+		if err != nil {
+			return nil, err
+		}
+
+		tc := tls.Client(conn, tr.ClientConfig(ins.Ip))
+
+		ctx, cancel := context.WithTimeout(context.Background(), timeout-time.Since(start))
+		defer cancel()
+
+		if err = tc.HandshakeContext(ctx); err != nil {
+			_ = conn.Close()
+			return nil, fmt.Errorf("tls handshake with %s failed:%w", ins.InsIdentity(), err)
+		}
+
+		return tc, nil
+	}
 }
 
-func newArpcClientWrap(ins *regdis.Instance, timeout time.Duration) (*arpcClientWrap, error) {
-	cli, err := arpc.NewClient(func() (net.Conn, error) {
-		return net.DialTimeout("tcp", fmt.Sprintf("%s:%d", ins.Ip, ins.Port), timeout)
-	})
+func newArpcClientWrap(ins *regdis.Instance, timeout time.Duration, dialer Dialer, codec srpc.Codec, poolCfg ConnPoolConfig) (*arpcClientWrap, error) {
+	handler := arpc.DefaultHandler.Clone()
+	handler.UseCoder(srpc.MetaCoder{})
+	handler.SetLogTag(fmt.Sprintf("[%s]", app.GetTheApp().GetAppName()))
+
+	pool, err := newConnPool(
+		ins,
+		poolCfg,
+		func() (net.Conn, error) {
+			return dialer(ins, timeout)
+		},
+		handler,
+		srpc.ArpcCodec(codec),
+	)
 
 	if err != nil {
 		return nil, err
 	}
 
-	cli.Codec = srpc.JsonIterCodec{}
-	cli.Handler.SetLogTag(fmt.Sprintf("[%s]", app.GetTheApp().GetAppName()))
+	acw := &arpcClientWrap{
+		pool:  pool,
+		codec: codec,
+	}
+	acw.ins.Store(ins)
+
+	return acw, nil
+}
+
+// 从连接池取一个连接执行fn
+func (acw *arpcClientWrap) withConn(fn func(cli *arpc.Client) error) error {
+	pc, cli, err := acw.acquire()
+	if err != nil {
+		return err
+	}
+	defer pc.release()
+
+	return fn(cli)
+}
 
-	return &arpcClientWrap{
-		ins:    ins,
-		cli:    cli,
-		weight: ins.Weight,
-	}, nil
+// 返回的连接使用完毕后需调用release, 用于异步调用
+func (acw *arpcClientWrap) acquire() (*pooledConn, *arpc.Client, error) {
+	pc, err := acw.pool.acquire()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// 已取用的连接不会被回收, 仅在连接池关闭时为nil
+	cli := pc.client()
+	if cli == nil {
+		pc.release()
+		return nil, nil, arpc.ErrClientStopped
+	}
+
+	return pc, cli, nil
+}
+
+// ConnStates 各连接的状态
+func (acw *arpcClientWrap) ConnStates() []ConnState {
+	return acw.pool.states()
+}
+
+func (acw *arpcClientWrap) Identity() string {
+	return acw.Instance().InsIdentity()
+}
+
+func (acw *arpcClientWrap) Instance() *regdis.Instance {
+	return acw.ins.Load()
+}
+
+// 保留连接时更新为注册中心的最新实例
+func (acw *arpcClientWrap) setInstance(ins *regdis.Instance) {
+	acw.ins.Store(ins)
+}
+
+func (acw *arpcClientWrap) Metadata() map[string]string {
+	return acw.Instance().Metadata
+}
+
+// 未配置权重时按1处理
+func (acw *arpcClientWrap) Weight() float64 {
+	if w := acw.Instance().Weight; w > 0 {
+		return w
+	}
+
+	return 1
+}
+
+func (acw *arpcClientWrap) Inflight() int64 {
+	return acw.inflight.Load()
+}
+
+// 调用结束, 上报熔断器
+func (acw *arpcClientWrap) done(err error) {
+	if acw.breaker != nil {
+		acw.breaker.onResult(err)
+	}
 }
 
 func (acw *arpcClientWrap) close() {
 	if acw.closed.CompareAndSwap(false, true) {
-		acw.cli.Stop()
+		acw.pool.close()
 	}
 }
@@ -5,8 +5,10 @@ import (
 	"errors"
 	"fmt"
 	"github.com/lesismal/arpc"
+	"github.com/sweemingdow/gmicro_pkg/pkg/app"
 	"github.com/sweemingdow/gmicro_pkg/pkg/mylog"
 	"github.com/sweemingdow/gmicro_pkg/pkg/regdis"
+	"github.com/sweemingdow/gmicro_pkg/pkg/server/srpc"
 	"github.com/sweemingdow/gmicro_pkg/pkg/utils/usli"
 	"sync"
 	"sync/atomic"
@@ -18,10 +20,14 @@ type ArpcClientProxy interface {
 
 	Call(method string, req any, rsp any, timeout time.Duration, args ...any) error
 
+	// CallContext timeout>0时与ctx的deadline取较早者
 	CallContext(ctx context.Context, method string, req any, rsp any, timeout time.Duration, args ...any) error
 
 	CallAsync(method string, req any, handler arpc.AsyncHandlerFunc, timeout time.Duration, args ...any) error
 
+	// CallAsyncContext 超时时间取timeout与ctx的deadline中较早者, ctx中的元数据随请求发送
+	CallAsyncContext(ctx context.Context, method string, req any, handler arpc.AsyncHandlerFunc, timeout time.Duration, args ...any) error
+
 	PushMsg(msg *arpc.Message, timeout time.Duration) error
 
 	Notify(method string, data any, timeout time.Duration, args ...any) error
@@ -29,6 +35,9 @@ type ArpcClientProxy interface {
 	NotifyContext(ctx context.Context, method string, data any, args ...any) error
 
 	Stop(ctx context.Context) error
+
+	// ConnStates 各实例的连接状态, key为实例标识
+	ConnStates() map[string][]ConnState
 }
 
 type arpcClientProxy struct {
@@ -43,6 +52,9 @@ type arpcClientProxy struct {
 	// clients pool
 	clients []*arpcClientWrap
 
+	// clients的只读视图, 供负载均衡使用, 与clients同步更新
+	endpoints []Endpoint
+
 	lb LoadBalancer
 
 	rwMu sync.RWMutex
@@ -52,6 +64,78 @@ type arpcClientProxy struct {
 	initErr atomic.Pointer[error]
 
 	dialTimeout time.Duration
+
+	dialer Dialer
+
+	// 为nil时不启用tls
+	tls *srpc.TlsReloader
+
+	// 为nil时不启用熔断
+	breakerCfg *BreakerConfig
+
+	breakerListeners []BreakerListener
+
+	// 客户端拦截器, 按注册顺序执行
+	interceptors []ClientInterceptor
+
+	// 拦截器链包装后的调用入口
+	invoker Invoker
+
+	// 期望的编解码器, 按顺序与实例声明的取交集, 见srpc.SelectCodec
+	codecs []string
+
+	// 每个实例的连接池配置
+	poolCfg ConnPoolConfig
+}
+
+type ProxyOption func(acp *arpcClientProxy)
+
+// WithDialer 自定义连接方式, 默认tcp
+func WithDialer(dialer Dialer) ProxyOption {
+	return func(acp *arpcClientProxy) {
+		if dialer != nil {
+			acp.dialer = dialer
+		}
+	}
+}
+
+// WithTls 以tls连接实例, 作用于任意Dialer建立的连接之上
+func WithTls(tr *srpc.TlsReloader) ProxyOption {
+	return func(acp *arpcClientProxy) {
+		acp.tls = tr
+	}
+}
+
+// WithCodecs 期望使用的编解码器, 如srpc.CodecMsgpack, 实例未声明支持时退回实例的首选编码
+func WithCodecs(names ...string) ProxyOption {
+	return func(acp *arpcClientProxy) {
+		acp.codecs = names
+	}
+}
+
+// WithServiceCodecs 仅对serviceName生效的WithCodecs, 需放在WithCodecs之后
+func WithServiceCodecs(serviceName string, names ...string) ProxyOption {
+	return func(acp *arpcClientProxy) {
+		if acp.serviceName == serviceName {
+			acp.codecs = names
+		}
+	}
+}
+
+// WithConnPool 每个实例建立多个连接, 支持延迟建立, 空闲回收和断线退避重连
+func WithConnPool(cfg ConnPoolConfig) ProxyOption {
+	return func(acp *arpcClientProxy) {
+		acp.poolCfg = cfg
+	}
+}
+
+// WithBreaker 启用实例级熔断, 熔断中的实例不参与选择, listeners为空时只记录日志
+func WithBreaker(cfg BreakerConfig, listeners ...BreakerListener) ProxyOption {
+	return func(acp *arpcClientProxy) {
+		cfg = cfg.withDefaults()
+		acp.breakerCfg = &cfg
+		acp.breakerListeners = append([]BreakerListener{logBreakerEvent}, listeners...)
+	}
 }
 
 func NewArpcClientProxy(
@@ -60,16 +144,29 @@ func NewArpcClientProxy(
 	extraMap map[string]any,
 	lb LoadBalancer,
 	dialTimeout time.Duration,
+	opts ...ProxyOption,
 ) (ArpcClientProxy, error) {
 	cp := &arpcClientProxy{
 		serviceName: serviceName,
 		extraMap:    extraMap,
 		clients:     make([]*arpcClientWrap, 0),
+		endpoints:   make([]Endpoint, 0),
 		lb:          lb,
 		discovery:   discovery,
 		dialTimeout: dialTimeout,
+		dialer:      tcpDialer,
 	}
 
+	for _, opt := range opts {
+		opt(cp)
+	}
+
+	if cp.tls != nil {
+		cp.dialer = tlsDialer(cp.dialer, cp.tls)
+	}
+
+	cp.invoker = chainInvoker(cp.doInvoke, cp.interceptors)
+
 	// attempts to obtain available instances in init
 	instances, err := discovery.Discover(
 		regdis.DiscoverParam{
@@ -116,87 +213,236 @@ func (acp *arpcClientProxy) IsReady() bool {
 }
 
 func (acp *arpcClientProxy) Call(method string, req any, resp any, timeout time.Duration, args ...any) error {
-	err := acp.readyOrErr()
-	if err != nil {
-		return err
+	return acp.invoke(&Invocation{
+		Ctx:     context.Background(),
+		Method:  method,
+		Kind:    CallKindCall,
+		Req:     req,
+		Resp:    resp,
+		Timeout: timeout,
+		Args:    args,
+	})
+}
+
+func (acp *arpcClientProxy) CallContext(ctx context.Context, method string, req any, resp any, timeout time.Duration, args ...any) error {
+	// arpc以ctx的deadline为准
+	if ctx != nil && timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	return acp.invoke(&Invocation{
+		Ctx:     ctx,
+		Method:  method,
+		Kind:    CallKindCall,
+		Req:     req,
+		Resp:    resp,
+		Args:    args,
+		withCtx: true,
+	})
+}
+
+func (acp *arpcClientProxy) CallAsync(method string, req any, handler arpc.AsyncHandlerFunc, timeout time.Duration, args ...any) error {
+	return acp.invoke(&Invocation{
+		Ctx:     context.Background(),
+		Method:  method,
+		Kind:    CallKindAsync,
+		Req:     req,
+		Timeout: timeout,
+		Handler: handler,
+		Args:    args,
+	})
+}
+
+func (acp *arpcClientProxy) CallAsyncContext(ctx context.Context, method string, req any, handler arpc.AsyncHandlerFunc, timeout time.Duration, args ...any) error {
+	if ctx == nil {
+		ctx = context.Background()
 	}
 
-	cp, err := acp.chooseClient()
-	if err != nil {
+	if err := ctx.Err(); err != nil {
 		return err
 	}
 
-	return cp.cli.Call(method, req, resp, timeout, args...)
+	if dl, ok := ctx.Deadline(); ok {
+		if left := time.Until(dl); timeout <= 0 || left < timeout {
+			timeout = left
+		}
+	}
+
+	return acp.invoke(&Invocation{
+		Ctx:     ctx,
+		Method:  method,
+		Kind:    CallKindAsync,
+		Req:     req,
+		Timeout: timeout,
+		Handler: handler,
+		Args:    args,
+	})
 }
 
-func (acp *arpcClientProxy) CallContext(ctx context.Context, method string, req any, resp any, timeout time.Duration, args ...any) error {
+func (acp *arpcClientProxy) PushMsg(msg *arpc.Message, timeout time.Duration) error {
 	err := acp.readyOrErr()
 	if err != nil {
 		return err
 	}
 
-	cp, err := acp.chooseClient()
+	cp, err := acp.chooseClient(newSelectContext(nil, msg.Method()), nil)
 	if err != nil {
 		return err
 	}
 
-	return cp.cli.CallContext(ctx, method, req, resp, args...)
+	err = cp.withConn(func(cli *arpc.Client) error {
+		return cli.PushMsg(msg, timeout)
+	})
+	cp.done(err)
+
+	return err
 }
 
-func (acp *arpcClientProxy) CallAsync(method string, req any, handler arpc.AsyncHandlerFunc, timeout time.Duration, args ...any) error {
+func (acp *arpcClientProxy) Notify(method string, data any, timeout time.Duration, args ...any) error {
+	return acp.invoke(&Invocation{
+		Ctx:     context.Background(),
+		Method:  method,
+		Kind:    CallKindNotify,
+		Req:     data,
+		Timeout: timeout,
+		Args:    args,
+	})
+}
+
+func (acp *arpcClientProxy) NotifyContext(ctx context.Context, method string, data any, args ...any) error {
+	return acp.invoke(&Invocation{
+		Ctx:     ctx,
+		Method:  method,
+		Kind:    CallKindNotify,
+		Req:     data,
+		Args:    args,
+		withCtx: true,
+	})
+}
+
+// 经过拦截器链后执行实际调用
+func (acp *arpcClientProxy) invoke(inv *Invocation) error {
 	err := acp.readyOrErr()
 	if err != nil {
 		return err
 	}
 
-	cp, err := acp.chooseClient()
+	if inv.Ctx == nil {
+		inv.Ctx = context.Background()
+	}
+	inv.ServiceName = acp.serviceName
+
+	return acp.invoker(inv)
+}
+
+func (acp *arpcClientProxy) doInvoke(inv *Invocation) error {
+	switch inv.Kind {
+	case CallKindCall:
+		return acp.doCall(inv)
+	case CallKindAsync:
+		return acp.doCallAsync(inv)
+	case CallKindNotify:
+		return acp.doNotify(inv)
+	default:
+		return fmt.Errorf("unknown call kind:%d", inv.Kind)
+	}
+}
+
+func (acp *arpcClientProxy) doCall(inv *Invocation) error {
+	if inv.withCtx {
+		args, err := withMeta(inv.Ctx, 0, inv.Args)
+		if err != nil {
+			return err
+		}
+
+		return acp.callWithRetry(inv.Ctx, inv.Method, func(cp *arpcClientWrap) error {
+			return cp.withConn(func(cli *arpc.Client) error {
+				return cli.CallContext(inv.Ctx, inv.Method, inv.Req, inv.Resp, args...)
+			})
+		})
+	}
+
+	args, err := withMeta(inv.Ctx, inv.Timeout, inv.Args)
 	if err != nil {
 		return err
 	}
 
-	return cp.cli.CallAsync(method, req, handler, timeout, args...)
+	return acp.callWithRetry(inv.Ctx, inv.Method, func(cp *arpcClientWrap) error {
+		return cp.withConn(func(cli *arpc.Client) error {
+			return cli.Call(inv.Method, inv.Req, inv.Resp, inv.Timeout, args...)
+		})
+	})
 }
 
-func (acp *arpcClientProxy) PushMsg(msg *arpc.Message, timeout time.Duration) error {
-	err := acp.readyOrErr()
+func (acp *arpcClientProxy) doCallAsync(inv *Invocation) error {
+	args, err := withMeta(inv.Ctx, inv.Timeout, inv.Args)
 	if err != nil {
 		return err
 	}
 
-	cp, err := acp.chooseClient()
+	cp, err := acp.chooseClient(newSelectContext(inv.Ctx, inv.Method), nil)
 	if err != nil {
 		return err
 	}
 
-	return cp.cli.PushMsg(msg, timeout)
-}
+	handler := inv.Handler
 
-func (acp *arpcClientProxy) Notify(method string, data any, timeout time.Duration, args ...any) error {
-	err := acp.readyOrErr()
-	if err != nil {
+	// handler为nil时交由arpc校验报错
+	if handler == nil {
+		err = cp.withConn(func(cli *arpc.Client) error {
+			return cli.CallAsync(inv.Method, inv.Req, handler, inv.Timeout, args...)
+		})
+		cp.done(err)
 		return err
 	}
 
-	cp, err := acp.chooseClient()
+	pc, cli, err := cp.acquire()
 	if err != nil {
+		cp.done(err)
 		return err
 	}
 
-	return cp.cli.Notify(method, data, timeout, args...)
+	// 响应或超时回调时结束计数并归还连接
+	cp.inflight.Add(1)
+	err = cli.CallAsync(inv.Method, inv.Req, func(c *arpc.Context, err error) {
+		pc.release()
+		cp.inflight.Add(-1)
+		cp.done(err)
+		handler(c, err)
+	}, inv.Timeout, args...)
+
+	if err != nil {
+		pc.release()
+		cp.inflight.Add(-1)
+		cp.done(err)
+	}
+
+	return err
 }
 
-func (acp *arpcClientProxy) NotifyContext(ctx context.Context, method string, data any, args ...any) error {
-	err := acp.readyOrErr()
+func (acp *arpcClientProxy) doNotify(inv *Invocation) error {
+	args, err := withMeta(inv.Ctx, 0, inv.Args)
 	if err != nil {
 		return err
 	}
 
-	cp, err := acp.chooseClient()
+	cp, err := acp.chooseClient(newSelectContext(inv.Ctx, inv.Method), nil)
 	if err != nil {
 		return err
 	}
 
-	return cp.cli.NotifyContext(ctx, method, data, args...)
+	err = cp.withConn(func(cli *arpc.Client) error {
+		if inv.withCtx {
+			return cli.NotifyContext(inv.Ctx, inv.Method, inv.Req, args...)
+		}
+
+		return cli.Notify(inv.Method, inv.Req, inv.Timeout, args...)
+	})
+	cp.done(err)
+
+	return err
 }
 
 func (acp *arpcClientProxy) Stop(ctx context.Context) error {
@@ -219,6 +465,7 @@ func (acp *arpcClientProxy) Stop(ctx context.Context) error {
 	if err := acp.discovery.Unwatch(
 		regdis.DiscoverParam{
 			ServiceName: acp.serviceName,
+			DisType:     regdis.ForRpc,
 			Extra:       acp.extraMap,
 		}, func(err error) {
 
@@ -227,12 +474,24 @@ func (acp *arpcClientProxy) Stop(ctx context.Context) error {
 		errs = append(errs, err)
 	}
 
-	acp.clients = nil
+	acp.setClients(nil)
 	acp.hadWatched.Store(false)
 
 	return errors.Join(errs...)
 }
 
+func (acp *arpcClientProxy) ConnStates() map[string][]ConnState {
+	acp.rwMu.RLock()
+	defer acp.rwMu.RUnlock()
+
+	states := make(map[string][]ConnState, len(acp.clients))
+	for _, cli := range acp.clients {
+		states[cli.Identity()] = cli.ConnStates()
+	}
+
+	return states
+}
+
 func (acp *arpcClientProxy) readyOrErr() error {
 	if errVal := acp.initErr.Load(); errVal != nil {
 		return fmt.Errorf("arpc proxy client was not ready:%w for service:%s", *errVal, acp.serviceName)
@@ -241,16 +500,172 @@ func (acp *arpcClientProxy) readyOrErr() error {
 	return nil
 }
 
-func (acp *arpcClientProxy) chooseClient() (*arpcClientWrap, error) {
-	acp.rwMu.RLock()
-	cp := acp.lb.Select(acp.clients)
-	acp.rwMu.RUnlock()
+// 将超时时间, 请求id, 调用方等元数据合并到arpc的message values中
+// arpc只接受map[interface{}]interface{}作为首个参数, 其余参数原样保留
+func withMeta(ctx context.Context, timeout time.Duration, args []any) ([]any, error) {
+	md := srpc.OutgoingMeta(ctx, timeout, app.GetTheApp().GetAppId())
+
+	values := make(map[interface{}]interface{}, len(md))
+	if len(args) > 0 && args[0] != nil {
+		userValues, ok := args[0].(map[interface{}]interface{})
+		if !ok {
+			return nil, fmt.Errorf("unsupported call arg type:%T, message values must be map[interface{}]interface{}", args[0])
+		}
+
+		for k, v := range userValues {
+			values[k] = v
+		}
+	}
+
+	for k, v := range md {
+		values[k] = v
+	}
 
-	if cp == nil {
-		return nil, fmt.Errorf("can not found server instance with serviceName:%s", acp.serviceName)
+	if len(args) > 1 {
+		return append([]any{values}, args[1:]...), nil
 	}
 
-	return cp, nil
+	return []any{values}, nil
+}
+
+// 按方法的重试策略调用, 重试时优先选择未失败过的实例
+func (acp *arpcClientProxy) callWithRetry(ctx context.Context, method string, call func(cp *arpcClientWrap) error) error {
+	policy := lookupRetryPolicy(acp.serviceName, method)
+	sc := newSelectContext(ctx, method)
+
+	var failed map[string]struct{}
+	for attempt := 1; ; attempt++ {
+		cp, err := acp.chooseClient(sc, failed)
+		if err != nil {
+			return err
+		}
+
+		cp.inflight.Add(1)
+		err = call(cp)
+		cp.inflight.Add(-1)
+		cp.done(err)
+
+		if err == nil || !policy.enabled() || attempt >= policy.MaxAttempts || !IsRetryableErr(err) {
+			return err
+		}
+
+		if failed == nil {
+			failed = make(map[string]struct{}, policy.MaxAttempts)
+		}
+		failed[cp.Identity()] = struct{}{}
+
+		backoff := policy.backoff(attempt)
+
+		lg := mylog.AppLogger()
+		lg.Warn().
+			Err(err).
+			Str("service_name", acp.serviceName).
+			Str("method", method).
+			Str("instance", cp.Identity()).
+			Msgf("rpc call failed, retry after:%v, attempt:%d/%d", backoff, attempt, policy.MaxAttempts)
+
+		timer := time.NewTimer(backoff)
+		select {
+		case <-sc.Ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+	}
+}
+
+// excluded中的实例不参与选择, 全部被排除时退回到全部实例
+// 熔断中的实例不参与选择, 全部熔断时放行所有实例(fail open)
+func (acp *arpcClientProxy) chooseClient(sc *SelectContext, excluded map[string]struct{}) (*arpcClientWrap, error) {
+	var rejected map[string]struct{}
+
+	for {
+		now := time.Now()
+
+		acp.rwMu.RLock()
+		endpoints, guarded := acp.candidates(excluded, rejected, now)
+		ep := acp.lb.Select(sc, endpoints)
+		acp.rwMu.RUnlock()
+
+		if ep == nil {
+			return nil, fmt.Errorf("can not found server instance with serviceName:%s", acp.serviceName)
+		}
+
+		// 自定义负载均衡只能返回endpoints中的元素
+		cp, ok := ep.(*arpcClientWrap)
+		if !ok {
+			return nil, fmt.Errorf("load balancer returned an unknown endpoint:%s for serviceName:%s", ep.Identity(), acp.serviceName)
+		}
+
+		// 并发下半开的探测名额可能已被占用, 换一个实例
+		if !guarded || cp.breaker == nil || cp.breaker.tryAcquire(now) {
+			return cp, nil
+		}
+
+		if rejected == nil {
+			rejected = make(map[string]struct{})
+		}
+		rejected[cp.Identity()] = struct{}{}
+	}
+}
+
+// warning: Under the read lock
+func (acp *arpcClientProxy) candidates(excluded, rejected map[string]struct{}, now time.Time) ([]Endpoint, bool) {
+	endpoints := acp.endpoints
+	if len(excluded) > 0 {
+		remain := usli.Filter(endpoints, func(ep Endpoint) bool {
+			_, ok := excluded[ep.Identity()]
+			return !ok
+		})
+
+		if len(remain) > 0 {
+			endpoints = remain
+		}
+	}
+
+	if acp.breakerCfg == nil {
+		return endpoints, false
+	}
+
+	available := usli.Filter(endpoints, func(ep Endpoint) bool {
+		if _, ok := rejected[ep.Identity()]; ok {
+			return false
+		}
+
+		cb := ep.(*arpcClientWrap).breaker
+		return cb == nil || cb.selectable(now)
+	})
+
+	if len(available) == 0 {
+		return endpoints, false
+	}
+
+	return available, true
+}
+
+// 为新建的client挂载熔断器
+func (acp *arpcClientProxy) attachBreaker(cli *arpcClientWrap) {
+	if acp.breakerCfg == nil {
+		return
+	}
+
+	identity := cli.Identity()
+	cli.breaker = newCircuitBreaker(*acp.breakerCfg, func(ev BreakerEvent) {
+		ev.ServiceName = acp.serviceName
+		ev.Instance = identity
+
+		for _, listener := range acp.breakerListeners {
+			listener(ev)
+		}
+	})
+}
+
+// warning: Under the write lock
+func (acp *arpcClientProxy) setClients(clients []*arpcClientWrap) {
+	acp.clients = clients
+	acp.endpoints = usli.Conv(clients, func(cli *arpcClientWrap) Endpoint {
+		return cli
+	})
 }
 
 // 监听服务变化, 动态更新连接池
@@ -301,11 +716,13 @@ func (acp *arpcClientProxy) replaceAllClients(instances []*regdis.Instance) {
 	// create firstly, outside the lock
 	newInsSli := make([]*arpcClientWrap, 0, len(instances))
 	for _, ins := range instances {
-		cli, err := newArpcClientWrap(ins, acp.dialTimeout)
+		cli, err := newArpcClientWrap(ins, acp.dialTimeout, acp.dialer, acp.selectCodec(ins), acp.poolCfg)
 		if err != nil {
 			lg.Error().Stack().Err(err).Str("service_name", acp.serviceName).Msg("created clientWrap failed in replace clients")
 			continue
 		}
+
+		acp.attachBreaker(cli)
 		newInsSli = append(newInsSli, cli)
 	}
 
@@ -324,13 +741,13 @@ func (acp *arpcClientProxy) replaceAllClients(instances []*regdis.Instance) {
 	}
 
 	// replace new
-	acp.clients = newInsSli
+	acp.setClients(newInsSli)
 
 	acp.resetInitErr()
 
 	if e := lg.Debug(); e.Enabled() {
 		remainAddr := usli.Conv(newInsSli, func(t *arpcClientWrap) string {
-			return t.ins.InsIdentity()
+			return t.Identity()
 		})
 
 		e.Str("service_name", acp.serviceName).Msgf("replace clients completed, final clients:%+v", remainAddr)
@@ -341,6 +758,10 @@ func (acp *arpcClientProxy) resetInitErr() {
 	acp.initErr.Store(nil)
 }
 
+func (acp *arpcClientProxy) selectCodec(ins *regdis.Instance) srpc.Codec {
+	return srpc.SelectCodec(acp.codecs, ins.Metadata[regdis.MetadataRpcCodecs])
+}
+
 func (acp *arpcClientProxy) modifyClients(instances []*regdis.Instance) {
 	acp.rwMu.RLock()
 	if len(acp.clients) == 0 {
@@ -361,9 +782,9 @@ func (acp *arpcClientProxy) modifyClients(instances []*regdis.Instance) {
 	// clientWrap: keep old, insert new, delete not exists
 
 	// create new instances map for quick query
-	newInsMap := make(map[string]struct{}, len(instances))
+	newInsMap := make(map[string]*regdis.Instance, len(instances))
 	for _, ins := range instances {
-		newInsMap[ins.InsIdentity()] = struct{}{}
+		newInsMap[ins.InsIdentity()] = ins
 	}
 
 	// found existed client, but not in new ins list
@@ -373,8 +794,10 @@ func (acp *arpcClientProxy) modifyClients(instances []*regdis.Instance) {
 	clients := acp.clients
 
 	for _, cli := range clients {
-		if _, ok := newInsMap[cli.ins.InsIdentity()]; ok {
-			// keep it
+		// 实例重启后声明的编码可能变化, 需重建连接
+		if ins, ok := newInsMap[cli.Identity()]; ok && acp.selectCodec(ins).Name() == cli.codec.Name() {
+			// keep it, 权重和metadata可能已变化
+			cli.setInstance(ins)
 			keepClients = append(keepClients, cli)
 		} else {
 			// not exists, should be close
@@ -389,7 +812,7 @@ func (acp *arpcClientProxy) modifyClients(instances []*regdis.Instance) {
 		found := false
 
 		for _, cli := range keepClients {
-			oldKey := cli.ins.InsIdentity()
+			oldKey := cli.Identity()
 			if newKey == oldKey {
 				found = true
 				break
@@ -410,22 +833,24 @@ func (acp *arpcClientProxy) modifyClients(instances []*regdis.Instance) {
 
 	// create new client
 	for _, ins := range beCreatedIns {
-		cli, err := newArpcClientWrap(ins, acp.dialTimeout)
+		cli, err := newArpcClientWrap(ins, acp.dialTimeout, acp.dialer, acp.selectCodec(ins), acp.poolCfg)
 		if err != nil {
 			lg.Error().Stack().Err(err).Str("service_name", acp.serviceName).Msg("created clientWrap failed in modify clients")
 			continue
 		}
 
+		acp.attachBreaker(cli)
+
 		keepClients = append(keepClients, cli)
 	}
 
-	acp.clients = keepClients
+	acp.setClients(keepClients)
 
 	acp.resetInitErr()
 
 	if e := lg.Debug(); e.Enabled() {
 		remainAddr := usli.Conv(keepClients, func(t *arpcClientWrap) string {
-			return t.ins.InsIdentity()
+			return t.Identity()
 		})
 
 		e.Str("service_name", acp.serviceName).Msgf("modify clients completed, final clients:%+v", remainAddr)
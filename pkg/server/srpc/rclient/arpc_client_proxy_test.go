@@ -0,0 +1,561 @@
+package rclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/lesismal/arpc"
+	"github.com/sweemingdow/gmicro_pkg/pkg/regdis"
+	"github.com/sweemingdow/gmicro_pkg/pkg/regdis/regdismem"
+	"github.com/sweemingdow/gmicro_pkg/pkg/server/srpc"
+	"github.com/sweemingdow/gmicro_pkg/pkg/server/srpc/rpccall"
+	"net"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testService = "one_service"
+
+// 启动本地arpc服务, 返回监听地址
+func startArpcServer(t *testing.T, name string) string {
+	t.Helper()
+
+	srv := arpc.NewServer()
+	srv.Codec = srpc.JsonIterCodec{}
+	srv.Handler.UseCoder(srpc.MetaCoder{})
+	srv.Handler.Handle("/who", func(c *arpc.Context) {
+		_ = c.Write(name)
+	})
+	srv.Handler.Handle("/meta", func(c *arpc.Context) {
+		ctx, cancel := srpc.ContextFrom(c)
+		defer cancel()
+
+		var req string
+		if err := c.Bind(&req); err != nil {
+			_ = c.Error(err)
+			return
+		}
+
+		dl, ok := ctx.Deadline()
+		_ = c.Write(map[string]any{
+			"req":        req,
+			"request_id": srpc.RequestIdFrom(ctx),
+			"baggage":    srpc.BaggageFrom(ctx),
+			"has_dl":     ok,
+			"remain_ms":  time.Until(dl).Milliseconds(),
+		})
+	})
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	go func() {
+		_ = srv.Serve(ln)
+	}()
+
+	t.Cleanup(func() {
+		_ = srv.Stop()
+	})
+
+	return ln.Addr().String()
+}
+
+func clientAddrs(acp *arpcClientProxy) map[string]bool {
+	acp.rwMu.RLock()
+	defer acp.rwMu.RUnlock()
+
+	addrs := make(map[string]bool, len(acp.clients))
+	for _, cli := range acp.clients {
+		addrs[cli.Identity()] = true
+	}
+
+	return addrs
+}
+
+func TestArpcClientProxyFollowsDiscovery(t *testing.T) {
+	addrA := startArpcServer(t, "a")
+	addrB := startArpcServer(t, "b")
+
+	mrd := regdismem.NewMemRegDis(false)
+	mrd.SetDiscoverError(testService, errors.New("not found"))
+
+	proxy, err := NewArpcClientProxy(testService, mrd, nil, NewRoundRobinLoadBalancer(), time.Second)
+	if err == nil || proxy.IsReady() {
+		t.Fatal("proxy should not be ready before any instance discovered")
+	}
+	defer proxy.Stop(context.Background())
+
+	acp := proxy.(*arpcClientProxy)
+
+	_ = mrd.Register(regdis.RegisterParam{ServiceName: testService, Addr: addrA})
+	if !proxy.IsReady() || !clientAddrs(acp)[addrA] {
+		t.Fatal("proxy should be ready after instance registered")
+	}
+
+	_ = mrd.Register(regdis.RegisterParam{ServiceName: testService, Addr: addrB})
+	if addrs := clientAddrs(acp); len(addrs) != 2 {
+		t.Fatalf("expect 2 clients, got:%v", addrs)
+	}
+
+	// 抖动和空列表时保留已有连接
+	mrd.Flap(testService, 3, 0)
+	mrd.EmitEmpty(testService)
+	mrd.EmitError(testService, errors.New("watch failed"))
+	if addrs := clientAddrs(acp); len(addrs) != 2 {
+		t.Fatalf("expect clients kept after flapping, got:%v", addrs)
+	}
+
+	_ = mrd.Deregister(regdis.DeregisterParam{ServiceName: testService, Addr: addrA})
+	if addrs := clientAddrs(acp); len(addrs) != 1 || !addrs[addrB] {
+		t.Fatalf("expect only %s left, got:%v", addrB, addrs)
+	}
+
+	for i := 0; i < 3; i++ {
+		var who string
+		if err = proxy.Call("/who", "", &who, time.Second); err != nil {
+			t.Fatal(err)
+		}
+
+		if who != "b" {
+			t.Fatalf("expect call routed to b, got:%s", who)
+		}
+	}
+}
+
+// 保留的连接使用注册中心更新后的权重和metadata
+func TestArpcClientProxyFollowsInstanceUpdate(t *testing.T) {
+	addrA := startArpcServer(t, "a")
+	addrB := startArpcServer(t, "b")
+
+	mrd := regdismem.NewMemRegDis(false)
+	_ = mrd.Register(regdis.RegisterParam{ServiceName: testService, Addr: addrA, Weight: 1})
+	_ = mrd.Register(regdis.RegisterParam{ServiceName: testService, Addr: addrB, Weight: 1})
+
+	proxy, err := NewArpcClientProxy(testService, mrd, nil, NewWeightedRoundRobinLoadBalancer(), time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer proxy.Stop(context.Background())
+
+	acp := proxy.(*arpcClientProxy)
+
+	acp.rwMu.RLock()
+	before := append([]*arpcClientWrap(nil), acp.clients...)
+	acp.rwMu.RUnlock()
+
+	_ = mrd.Register(regdis.RegisterParam{ServiceName: testService, Addr: addrB, Weight: 3, Metadata: map[string]string{"version": "v2"}})
+
+	acp.rwMu.RLock()
+	after := append([]*arpcClientWrap(nil), acp.clients...)
+	acp.rwMu.RUnlock()
+
+	for _, cli := range after {
+		if cli.Identity() == addrB && (cli.Weight() != 3 || cli.Metadata()["version"] != "v2") {
+			t.Fatalf("instance not updated, weight:%v, metadata:%v", cli.Weight(), cli.Metadata())
+		}
+	}
+
+	if len(after) != 2 || (after[0] != before[0] && after[0] != before[1]) {
+		t.Fatal("expect clients kept on instance update")
+	}
+
+	counts := make(map[string]int)
+	for i := 0; i < 4; i++ {
+		var who string
+		if err = proxy.Call("/who", "", &who, time.Second); err != nil {
+			t.Fatal(err)
+		}
+		counts[who]++
+	}
+
+	if counts["a"] != 1 || counts["b"] != 3 {
+		t.Fatalf("expect calls follow the new weight, got:%v", counts)
+	}
+}
+
+func TestArpcClientProxyRetryOnAnotherInstance(t *testing.T) {
+	const service = "retry_service"
+
+	addrA := startArpcServer(t, "a")
+
+	// b先启动后关闭, 模拟即将下线的实例
+	srvB := arpc.NewServer()
+	srvB.Codec = srpc.JsonIterCodec{}
+	lnB, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		_ = srvB.Serve(lnB)
+	}()
+
+	mrd := regdismem.NewMemRegDis(false)
+	_ = mrd.Register(regdis.RegisterParam{ServiceName: service, Addr: addrA})
+	_ = mrd.Register(regdis.RegisterParam{ServiceName: service, Addr: lnB.Addr().String()})
+
+	proxy, err := NewArpcClientProxy(service, mrd, nil, NewRoundRobinLoadBalancer(), time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer proxy.Stop(context.Background())
+
+	_ = srvB.Stop()
+	time.Sleep(50 * time.Millisecond)
+
+	RegisterRetryPolicy(service, "/who", RetryPolicy{MaxAttempts: 2, InitialBackoff: time.Millisecond})
+
+	for i := 0; i < 4; i++ {
+		var who string
+		if err = proxy.Call("/who", "", &who, time.Second); err != nil {
+			t.Fatalf("call should succeed by retrying on another instance:%v", err)
+		}
+	}
+}
+
+func TestArpcClientProxyPropagatesMeta(t *testing.T) {
+	const service = "meta_service"
+
+	mrd := regdismem.NewMemRegDis(false)
+	_ = mrd.Register(regdis.RegisterParam{ServiceName: service, Addr: startArpcServer(t, "a")})
+
+	proxy, err := NewArpcClientProxy(service, mrd, nil, NewRoundRobinLoadBalancer(), time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer proxy.Stop(context.Background())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 800*time.Millisecond)
+	defer cancel()
+
+	ctx = srpc.WithRequestId(ctx, "req-1")
+	ctx = srpc.WithBaggage(ctx, "tenant", "t1")
+
+	var resp struct {
+		Req       string            `json:"req"`
+		RequestId string            `json:"request_id"`
+		Baggage   map[string]string `json:"baggage"`
+		HasDl     bool              `json:"has_dl"`
+		RemainMs  int64             `json:"remain_ms"`
+	}
+
+	if err = proxy.CallContext(ctx, "/meta", "hello", &resp, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	if resp.Req != "hello" || resp.RequestId != "req-1" || resp.Baggage["tenant"] != "t1" {
+		t.Fatalf("meta not propagated:%+v", resp)
+	}
+
+	if !resp.HasDl || resp.RemainMs <= 0 || resp.RemainMs > 800 {
+		t.Fatalf("deadline not propagated:%+v", resp)
+	}
+
+	// 未指定时自动生成请求id
+	if err = proxy.Call("/meta", "hi", &resp, time.Second); err != nil {
+		t.Fatal(err)
+	}
+
+	if resp.Req != "hi" || resp.RequestId == "" || resp.RequestId == "req-1" {
+		t.Fatalf("request id not generated:%+v", resp)
+	}
+
+	// 用户传入的values与元数据合并, 不支持的参数报错而不是丢弃
+	if err = proxy.Call("/meta", "hi", &resp, time.Second, map[interface{}]interface{}{"k": "v"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = proxy.Call("/meta", "hi", &resp, time.Second, "not values"); err == nil {
+		t.Fatal("expected error for unsupported arg")
+	}
+}
+
+func TestArpcClientProxyInterceptors(t *testing.T) {
+	const service = "interceptor_service"
+
+	mrd := regdismem.NewMemRegDis(false)
+	_ = mrd.Register(regdis.RegisterParam{ServiceName: service, Addr: startArpcServer(t, "a")})
+
+	var trace []string
+	record := func(name string) ClientInterceptor {
+		return func(inv *Invocation, next Invoker) error {
+			trace = append(trace, name+">")
+			err := next(inv)
+			trace = append(trace, "<"+name)
+			return err
+		}
+	}
+
+	auth := func(inv *Invocation, next Invoker) error {
+		inv.SetValue(srpc.MetaKeyBaggagePref+"token", "tk")
+		return next(inv)
+	}
+
+	proxy, err := NewArpcClientProxy(
+		service, mrd, nil, NewRoundRobinLoadBalancer(), time.Second,
+		WithInterceptors(record("a"), StampRequestId(), auth, record("b")),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer proxy.Stop(context.Background())
+
+	var resp struct {
+		RequestId string            `json:"request_id"`
+		Baggage   map[string]string `json:"baggage"`
+	}
+
+	ctx := srpc.WithRequestId(context.Background(), "req-2")
+	if err = proxy.CallContext(ctx, "/meta", "hi", &resp, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := strings.Join(trace, ","); got != "a>,b>,<b,<a" {
+		t.Fatalf("unexpected interceptor order:%s", got)
+	}
+
+	if resp.RequestId != "req-2" || resp.Baggage["token"] != "tk" {
+		t.Fatalf("interceptor values not sent:%+v", resp)
+	}
+
+	// 请求id写入RpcReqWrapper
+	req := rpccall.CreateIdReq("", "x")
+	req.ReqId = ""
+	err = StampRequestId()(&Invocation{Ctx: context.Background(), Req: &req}, func(inv *Invocation) error {
+		if id := srpc.RequestIdFrom(inv.Ctx); id == "" || id != req.ReqId {
+			t.Fatalf("request id not stamped, ctx:%s, req:%s", id, req.ReqId)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestArpcClientProxyCallAsyncContext(t *testing.T) {
+	const service = "async_service"
+
+	mrd := regdismem.NewMemRegDis(false)
+	_ = mrd.Register(regdis.RegisterParam{ServiceName: service, Addr: startArpcServer(t, "a")})
+
+	proxy, err := NewArpcClientProxy(service, mrd, nil, NewRoundRobinLoadBalancer(), time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer proxy.Stop(context.Background())
+
+	ctx := srpc.WithRequestId(context.Background(), "req-3")
+
+	type metaResp struct {
+		RequestId string `json:"request_id"`
+		RemainMs  int64  `json:"remain_ms"`
+	}
+
+	done := make(chan metaResp, 1)
+	err = proxy.CallAsyncContext(ctx, "/meta", "hi", func(c *arpc.Context, err error) {
+		var resp metaResp
+		if err == nil {
+			err = c.Bind(&resp)
+		}
+		if err != nil {
+			t.Error(err)
+		}
+		done <- resp
+	}, 500*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case resp := <-done:
+		if resp.RequestId != "req-3" || resp.RemainMs <= 0 || resp.RemainMs > 500 {
+			t.Fatalf("meta not propagated in async call:%+v", resp)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("async callback not invoked")
+	}
+
+	// ctx没有deadline时使用timeout
+	var resp metaResp
+	if err = proxy.CallContext(context.Background(), "/meta", "hi", &resp, 300*time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	if resp.RemainMs <= 0 || resp.RemainMs > 300 {
+		t.Fatalf("timeout not applied to CallContext:%+v", resp)
+	}
+
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err = proxy.CallAsyncContext(cancelled, "/meta", "hi", func(*arpc.Context, error) {}, time.Second); err == nil {
+		t.Fatal("expect error for cancelled ctx")
+	}
+}
+
+func TestArpcClientProxySelectsCodecByMetadata(t *testing.T) {
+	const service = "codec_service"
+
+	srv := arpc.NewServer()
+	srv.Codec = srpc.ArpcCodec(srpc.MsgpackCodec{})
+	srv.Handler.Handle("/echo", func(c *arpc.Context) {
+		var req map[string]string
+		if err := c.Bind(&req); err != nil {
+			_ = c.Error(err)
+			return
+		}
+
+		req["first_byte"] = fmt.Sprintf("0x%02x", c.Message.Data()[0])
+		_ = c.Write(req)
+	})
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		_ = srv.Serve(ln)
+	}()
+	defer srv.Stop()
+
+	mrd := regdismem.NewMemRegDis(false)
+	_ = mrd.Register(regdis.RegisterParam{
+		ServiceName: service,
+		Addr:        ln.Addr().String(),
+		Metadata:    map[string]string{regdis.MetadataRpcCodecs: "msgpack,json"},
+	})
+
+	proxy, err := NewArpcClientProxy(service, mrd, nil, NewRoundRobinLoadBalancer(), time.Second, WithCodecs(srpc.CodecGzipJson), WithServiceCodecs(service, srpc.CodecMsgpack))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer proxy.Stop(context.Background())
+
+	var resp map[string]string
+	if err = proxy.Call("/echo", map[string]string{"name": "tom"}, &resp, time.Second); err != nil {
+		t.Fatal(err)
+	}
+
+	if resp["name"] != "tom" || resp["first_byte"] != "0x01" {
+		t.Fatalf("expect msgpack request, got:%v", resp)
+	}
+}
+
+func TestArpcClientProxyConnPool(t *testing.T) {
+	const service = "pool_service"
+
+	// /slow在release关闭前不返回, 用于保持连接繁忙
+	var (
+		entered = make(chan struct{}, 2)
+		release = make(chan struct{})
+	)
+
+	serve := func(addr string) (*arpc.Server, string) {
+		srv := arpc.NewServer()
+		srv.Codec = srpc.JsonIterCodec{}
+		srv.Handler.Handle("/slow", func(c *arpc.Context) {
+			select {
+			case entered <- struct{}{}:
+			default:
+			}
+			<-release
+			_ = c.Write("ok")
+		})
+
+		ln, err := net.Listen("tcp", addr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		go func() {
+			_ = srv.Serve(ln)
+		}()
+
+		return srv, ln.Addr().String()
+	}
+
+	srv, addr := serve("127.0.0.1:0")
+
+	mrd := regdismem.NewMemRegDis(false)
+	_ = mrd.Register(regdis.RegisterParam{ServiceName: service, Addr: addr})
+
+	proxy, err := NewArpcClientProxy(service, mrd, nil, NewRoundRobinLoadBalancer(), time.Second, WithConnPool(ConnPoolConfig{
+		Size:                    2,
+		Lazy:                    true,
+		IdleTimeout:             200 * time.Millisecond,
+		ReconnectInitialBackoff: 10 * time.Millisecond,
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer proxy.Stop(context.Background())
+
+	statuses := func() []ConnStatus {
+		var sts []ConnStatus
+		for _, cs := range proxy.ConnStates()[addr] {
+			sts = append(sts, cs.Status)
+		}
+		return sts
+	}
+
+	// 轮询直到连接状态为want(不区分顺序)
+	waitStatuses := func(msg string, want ...ConnStatus) {
+		t.Helper()
+
+		slices.Sort(want)
+		deadline := time.Now().Add(3 * time.Second)
+		for sts := statuses(); !slices.Equal(slices.Sorted(slices.Values(sts)), want); sts = statuses() {
+			if time.Now().After(deadline) {
+				t.Fatalf("%s, got:%v", msg, statuses())
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+
+	if sts := statuses(); sts[0] != ConnIdle || sts[1] != ConnIdle {
+		t.Fatalf("expect lazy dial, got:%v", sts)
+	}
+
+	// 第一个连接繁忙时建立第二个连接
+	errCh := make(chan error, 2)
+	call := func() {
+		var rsp string
+		errCh <- proxy.Call("/slow", "", &rsp, 3*time.Second)
+	}
+
+	go call()
+	<-entered
+
+	go call()
+	waitStatuses("expect both connections ready", ConnReady, ConnReady)
+
+	close(release)
+	for i := 0; i < 2; i++ {
+		if err = <-errCh; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// 空闲回收后保留一个
+	waitStatuses("expect one connection reaped", ConnReady, ConnIdle)
+
+	// 服务重启后保留的连接重连
+	kept := slices.Index(statuses(), ConnReady)
+
+	_ = srv.Stop()
+	srv, _ = serve(addr)
+	defer srv.Stop()
+
+	deadline := time.Now().Add(3 * time.Second)
+	for proxy.ConnStates()[addr][kept].Reconnects == 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("expect reconnected, got:%+v", proxy.ConnStates()[addr])
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	var rsp string
+	if err = proxy.Call("/slow", "", &rsp, time.Second); err != nil || rsp != "ok" {
+		t.Fatalf("expect call succeed after reconnect, rsp:%q, err:%v", rsp, err)
+	}
+}
@@ -0,0 +1,318 @@
+package rclient
+
+import (
+	"fmt"
+	"github.com/sweemingdow/gmicro_pkg/pkg/mylog"
+	"sync"
+	"time"
+)
+
+const (
+	defaultConsecutiveFailures = 5
+	defaultBreakerMinRequests  = 20
+	defaultBreakerWindow       = 10 * time.Second
+	defaultEjectDuration       = 30 * time.Second
+	defaultMaxEjectDuration    = 5 * time.Minute
+	defaultHalfOpenProbes      = 1
+)
+
+type BreakerState int
+
+const (
+	BreakerClosed   BreakerState = iota // 正常
+	BreakerOpen                         // 熔断, 实例被剔除
+	BreakerHalfOpen                     // 半开, 放行少量探测请求
+)
+
+func (bs BreakerState) String() string {
+	switch bs {
+	case BreakerClosed:
+		return "closed"
+	case BreakerOpen:
+		return "open"
+	case BreakerHalfOpen:
+		return "half_open"
+	default:
+		return "unknown"
+	}
+}
+
+// BreakerConfig 实例级熔断配置, 只统计连接类和超时错误(见IsRetryableErr), 业务错误不计入
+type BreakerConfig struct {
+	ConsecutiveFailures int           // 连续失败次数达到后熔断, 默认5, <0不启用
+	ErrorRateThreshold  float64       // 窗口内错误率(0~1)达到后熔断, 0不启用
+	MinRequests         int           // 错误率生效所需的窗口内最少请求数, 默认20
+	Window              time.Duration // 错误率统计窗口, 默认10s
+	EjectDuration       time.Duration // 首次熔断时长, 默认30s, 再次熔断时翻倍
+	MaxEjectDuration    time.Duration // 熔断时长上限, 默认5m
+	HalfOpenProbes      int           // 半开时放行的探测请求数, 全部成功后恢复, 默认1
+}
+
+func (bc BreakerConfig) withDefaults() BreakerConfig {
+	if bc.ConsecutiveFailures == 0 {
+		bc.ConsecutiveFailures = defaultConsecutiveFailures
+	}
+
+	if bc.MinRequests <= 0 {
+		bc.MinRequests = defaultBreakerMinRequests
+	}
+
+	if bc.Window <= 0 {
+		bc.Window = defaultBreakerWindow
+	}
+
+	if bc.EjectDuration <= 0 {
+		bc.EjectDuration = defaultEjectDuration
+	}
+
+	if bc.MaxEjectDuration < bc.EjectDuration {
+		bc.MaxEjectDuration = defaultMaxEjectDuration
+		if bc.MaxEjectDuration < bc.EjectDuration {
+			bc.MaxEjectDuration = bc.EjectDuration
+		}
+	}
+
+	if bc.HalfOpenProbes <= 0 {
+		bc.HalfOpenProbes = defaultHalfOpenProbes
+	}
+
+	return bc
+}
+
+// BreakerEvent 实例熔断状态变化事件
+type BreakerEvent struct {
+	ServiceName string
+	Instance    string // ip:port
+	From        BreakerState
+	To          BreakerState
+	Reason      string
+	Ejections   int           // 该实例累计被剔除次数
+	EjectFor    time.Duration // To为BreakerOpen时的剔除时长
+	At          time.Time
+}
+
+// Ejected 实例被剔除
+func (be BreakerEvent) Ejected() bool {
+	return be.To == BreakerOpen
+}
+
+// Restored 实例恢复
+func (be BreakerEvent) Restored() bool {
+	return be.To == BreakerClosed
+}
+
+type BreakerListener func(ev BreakerEvent)
+
+// 默认监听, 记录剔除和恢复日志
+func logBreakerEvent(ev BreakerEvent) {
+	lg := mylog.AppLoggerWithListen()
+
+	switch {
+	case ev.Ejected():
+		lg.Warn().
+			Str("service_name", ev.ServiceName).
+			Str("instance", ev.Instance).
+			Msgf("instance ejected for:%v, reason:%s, ejections:%d", ev.EjectFor, ev.Reason, ev.Ejections)
+	case ev.Restored():
+		lg.Info().
+			Str("service_name", ev.ServiceName).
+			Str("instance", ev.Instance).
+			Msg("instance restored")
+	}
+}
+
+type circuitBreaker struct {
+	cfg    BreakerConfig
+	notify func(ev BreakerEvent)
+
+	mu    sync.Mutex
+	state BreakerState
+
+	consecutive int
+
+	// 滚动窗口, 按窗口起点整体重置
+	windowStart time.Time
+	requests    int
+	failures    int
+
+	openedAt  time.Time
+	ejectFor  time.Duration
+	ejections int
+
+	probing   int
+	probeSucc int
+}
+
+func newCircuitBreaker(cfg BreakerConfig, notify func(ev BreakerEvent)) *circuitBreaker {
+	return &circuitBreaker{
+		cfg:         cfg,
+		notify:      notify,
+		windowStart: time.Now(),
+	}
+}
+
+// 是否可参与选择, 不修改状态
+func (cb *circuitBreaker) selectable(now time.Time) bool {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	switch cb.state {
+	case BreakerOpen:
+		return !now.Before(cb.openedAt.Add(cb.ejectFor))
+	case BreakerHalfOpen:
+		return cb.probing < cb.cfg.HalfOpenProbes
+	default:
+		return true
+	}
+}
+
+// 选中后获取调用许可, 熔断到期时转为半开并占用探测名额
+func (cb *circuitBreaker) tryAcquire(now time.Time) bool {
+	cb.mu.Lock()
+
+	var ev *BreakerEvent
+	allowed := true
+
+	switch cb.state {
+	case BreakerOpen:
+		if now.Before(cb.openedAt.Add(cb.ejectFor)) {
+			allowed = false
+			break
+		}
+
+		ev = cb.transit(BreakerHalfOpen, "eject duration elapsed", now)
+		cb.probing = 1
+	case BreakerHalfOpen:
+		if cb.probing >= cb.cfg.HalfOpenProbes {
+			allowed = false
+			break
+		}
+
+		cb.probing++
+	}
+
+	cb.mu.Unlock()
+
+	cb.fire(ev)
+
+	return allowed
+}
+
+func (cb *circuitBreaker) onResult(err error) {
+	failed := IsRetryableErr(err)
+	now := time.Now()
+
+	cb.mu.Lock()
+
+	var ev *BreakerEvent
+
+	switch cb.state {
+	case BreakerHalfOpen:
+		if cb.probing > 0 {
+			cb.probing--
+		}
+
+		if failed {
+			ev = cb.eject("probe failed", now)
+			break
+		}
+
+		cb.probeSucc++
+		if cb.probeSucc >= cb.cfg.HalfOpenProbes {
+			ev = cb.transit(BreakerClosed, "probe succeeded", now)
+			cb.reset(now)
+		}
+	case BreakerClosed:
+		if now.Sub(cb.windowStart) >= cb.cfg.Window {
+			cb.windowStart = now
+			cb.requests = 0
+			cb.failures = 0
+		}
+
+		cb.requests++
+
+		if !failed {
+			cb.consecutive = 0
+			break
+		}
+
+		cb.failures++
+		cb.consecutive++
+
+		if cb.cfg.ConsecutiveFailures > 0 && cb.consecutive >= cb.cfg.ConsecutiveFailures {
+			ev = cb.eject(fmt.Sprintf("%d consecutive failures, last:%v", cb.consecutive, err), now)
+			break
+		}
+
+		if cb.cfg.ErrorRateThreshold > 0 && cb.requests >= cb.cfg.MinRequests {
+			if rate := float64(cb.failures) / float64(cb.requests); rate >= cb.cfg.ErrorRateThreshold {
+				ev = cb.eject(fmt.Sprintf("error rate %.2f in %d requests, last:%v", rate, cb.requests, err), now)
+			}
+		}
+	}
+
+	cb.mu.Unlock()
+
+	cb.fire(ev)
+}
+
+// warning: Under the lock
+func (cb *circuitBreaker) eject(reason string, now time.Time) *BreakerEvent {
+	// 连续被剔除时剔除时长翻倍
+	ejectFor := cb.cfg.EjectDuration
+	if cb.state == BreakerHalfOpen && cb.ejectFor > 0 {
+		ejectFor = min(cb.ejectFor*2, cb.cfg.MaxEjectDuration)
+	}
+
+	cb.ejections++
+	cb.ejectFor = ejectFor
+	cb.openedAt = now
+	cb.probing = 0
+	cb.probeSucc = 0
+
+	return cb.transit(BreakerOpen, reason, now)
+}
+
+// warning: Under the lock
+func (cb *circuitBreaker) reset(now time.Time) {
+	cb.consecutive = 0
+	cb.windowStart = now
+	cb.requests = 0
+	cb.failures = 0
+	cb.probing = 0
+	cb.probeSucc = 0
+	cb.ejectFor = 0
+}
+
+// warning: Under the lock
+func (cb *circuitBreaker) transit(to BreakerState, reason string, now time.Time) *BreakerEvent {
+	from := cb.state
+	cb.state = to
+
+	ev := &BreakerEvent{
+		From:      from,
+		To:        to,
+		Reason:    reason,
+		Ejections: cb.ejections,
+		At:        now,
+	}
+
+	if to == BreakerOpen {
+		ev.EjectFor = cb.ejectFor
+	}
+
+	return ev
+}
+
+func (cb *circuitBreaker) fire(ev *BreakerEvent) {
+	if ev != nil && cb.notify != nil {
+		cb.notify(*ev)
+	}
+}
+
+func (cb *circuitBreaker) currentState() BreakerState {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	return cb.state
+}
@@ -0,0 +1,79 @@
+package rclient
+
+import (
+	"errors"
+	"github.com/lesismal/arpc"
+	"github.com/sweemingdow/gmicro_pkg/pkg/myerr"
+	"testing"
+	"time"
+)
+
+func TestCircuitBreakerEjectAndRestore(t *testing.T) {
+	var events []BreakerEvent
+	cb := newCircuitBreaker(
+		BreakerConfig{ConsecutiveFailures: 3, EjectDuration: 20 * time.Millisecond, HalfOpenProbes: 1}.withDefaults(),
+		func(ev BreakerEvent) {
+			events = append(events, ev)
+		},
+	)
+
+	// 业务错误不计入
+	for i := 0; i < 5; i++ {
+		cb.onResult(myerr.NewRpcRespError("0", "biz", ""))
+	}
+	if cb.currentState() != BreakerClosed {
+		t.Fatal("business errors should not trip the breaker")
+	}
+
+	for i := 0; i < 3; i++ {
+		cb.onResult(arpc.ErrClientTimeout)
+	}
+	if cb.currentState() != BreakerOpen || len(events) != 1 || !events[0].Ejected() {
+		t.Fatalf("expect ejected after consecutive failures, events:%+v", events)
+	}
+
+	now := time.Now()
+	if cb.selectable(now) || cb.tryAcquire(now) {
+		t.Fatal("ejected instance should not be selectable")
+	}
+
+	// 到期后只放行一个探测请求, 探测失败时剔除时长翻倍
+	now = now.Add(25 * time.Millisecond)
+	if !cb.tryAcquire(now) || cb.tryAcquire(now) {
+		t.Fatal("expect exactly one probe in half open")
+	}
+
+	cb.onResult(arpc.ErrClientReconnecting)
+	if last := events[len(events)-1]; !last.Ejected() || last.EjectFor != 40*time.Millisecond {
+		t.Fatalf("expect re-ejected with doubled duration, got:%+v", last)
+	}
+
+	time.Sleep(45 * time.Millisecond)
+	if !cb.tryAcquire(time.Now()) {
+		t.Fatal("expect probe allowed")
+	}
+
+	cb.onResult(nil)
+	if last := events[len(events)-1]; !last.Restored() || cb.currentState() != BreakerClosed {
+		t.Fatalf("expect restored after probe succeeded, got:%+v", last)
+	}
+}
+
+func TestCircuitBreakerErrorRate(t *testing.T) {
+	cb := newCircuitBreaker(
+		BreakerConfig{ConsecutiveFailures: -1, ErrorRateThreshold: 0.5, MinRequests: 10}.withDefaults(),
+		nil,
+	)
+
+	for i := 0; i < 10; i++ {
+		if i%2 == 1 {
+			cb.onResult(errors.Join(errors.New("write failed"), arpc.ErrClientStopped))
+		} else {
+			cb.onResult(nil)
+		}
+	}
+
+	if cb.currentState() != BreakerOpen {
+		t.Fatal("expect ejected by error rate")
+	}
+}
@@ -0,0 +1,425 @@
+package rclient
+
+import (
+	"fmt"
+	"github.com/lesismal/arpc"
+	acodec "github.com/lesismal/arpc/codec"
+	"github.com/sweemingdow/gmicro_pkg/pkg/mylog"
+	"github.com/sweemingdow/gmicro_pkg/pkg/regdis"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	defaultReconnectInitialBackoff = 100 * time.Millisecond
+	defaultReconnectMaxBackoff     = 10 * time.Second
+)
+
+// ConnPoolConfig 每个实例的连接池配置, 零值时与单连接的行为一致
+type ConnPoolConfig struct {
+	Size                    int           // 每个实例的连接数, 默认1
+	Lazy                    bool          // 首次调用时才建立连接, 默认创建实例时建立第一个连接, 失败则忽略该实例
+	IdleTimeout             time.Duration // 空闲超过该时长的连接被关闭(至少保留一个), 0不回收
+	ReconnectInitialBackoff time.Duration // 断线重连的初始退避, 默认100ms, 每次失败翻倍, arpc自身在失败后另有1s间隔
+	ReconnectMaxBackoff     time.Duration // 重连退避上限, 默认10s
+}
+
+func (cpc ConnPoolConfig) withDefaults() ConnPoolConfig {
+	if cpc.Size <= 0 {
+		cpc.Size = 1
+	}
+
+	if cpc.ReconnectInitialBackoff <= 0 {
+		cpc.ReconnectInitialBackoff = defaultReconnectInitialBackoff
+	}
+
+	if cpc.ReconnectMaxBackoff < cpc.ReconnectInitialBackoff {
+		cpc.ReconnectMaxBackoff = max(defaultReconnectMaxBackoff, cpc.ReconnectInitialBackoff)
+	}
+
+	return cpc
+}
+
+func (cpc ConnPoolConfig) backoff(attempt int) time.Duration {
+	d := cpc.ReconnectInitialBackoff
+	for i := 1; i < attempt && d < cpc.ReconnectMaxBackoff; i++ {
+		d *= 2
+	}
+
+	return min(d, cpc.ReconnectMaxBackoff)
+}
+
+type ConnStatus int32
+
+const (
+	ConnIdle         ConnStatus = iota // 未建立或已被回收, 需要时再建立
+	ConnConnecting                     // 首次建立中
+	ConnReady                          // 可用
+	ConnReconnecting                   // 断线重连中
+	ConnClosing                        // 回收中
+	ConnClosed                         // 连接池已关闭
+)
+
+func (cs ConnStatus) String() string {
+	switch cs {
+	case ConnIdle:
+		return "idle"
+	case ConnConnecting:
+		return "connecting"
+	case ConnReady:
+		return "ready"
+	case ConnReconnecting:
+		return "reconnecting"
+	case ConnClosing:
+		return "closing"
+	case ConnClosed:
+		return "closed"
+	default:
+		return fmt.Sprintf("unknown(%d)", int32(cs))
+	}
+}
+
+// ConnState 单个连接的状态快照
+type ConnState struct {
+	Index      int        `json:"index"`
+	Status     ConnStatus `json:"-"`
+	StatusName string     `json:"status"`
+	Inflight   int64      `json:"inflight"`
+	Reconnects int64      `json:"reconnects"`
+	LastUsed   time.Time  `json:"lastUsed,omitempty"`
+	LastErr    string     `json:"lastErr,omitempty"`
+}
+
+// 一个实例的多条连接, 选择进行中调用最少的可用连接
+// 可用连接都繁忙且还有未建立的连接时, 在后台建立新连接
+type connPool struct {
+	ins *regdis.Instance
+	cfg ConnPoolConfig
+
+	dial    func() (net.Conn, error)
+	handler arpc.Handler
+	codec   acodec.Codec
+
+	conns []*pooledConn
+
+	growing atomic.Bool
+
+	stop   chan struct{}
+	closed atomic.Bool
+}
+
+func newConnPool(ins *regdis.Instance, cfg ConnPoolConfig, dial func() (net.Conn, error), handler arpc.Handler, codec acodec.Codec) (*connPool, error) {
+	cfg = cfg.withDefaults()
+
+	p := &connPool{
+		ins:     ins,
+		cfg:     cfg,
+		dial:    dial,
+		handler: handler,
+		codec:   codec,
+		conns:   make([]*pooledConn, cfg.Size),
+		stop:    make(chan struct{}),
+	}
+
+	for i := range p.conns {
+		p.conns[i] = &pooledConn{idx: i, pool: p}
+	}
+
+	if !cfg.Lazy {
+		if err := p.conns[0].connect(); err != nil {
+			return nil, err
+		}
+	}
+
+	if cfg.IdleTimeout > 0 {
+		go p.reapLoop()
+	}
+
+	return p, nil
+}
+
+// 返回的连接使用完毕后需调用release
+func (p *connPool) acquire() (*pooledConn, error) {
+	for {
+		if p.closed.Load() {
+			return nil, arpc.ErrClientStopped
+		}
+
+		var best, idle *pooledConn
+		for _, pc := range p.conns {
+			switch pc.loadStatus() {
+			case ConnReady:
+				if best == nil || pc.inflight.Load() < best.inflight.Load() {
+					best = pc
+				}
+			case ConnIdle:
+				if idle == nil {
+					idle = pc
+				}
+			}
+		}
+
+		if best == nil {
+			if idle == nil {
+				return nil, fmt.Errorf("no ready connection to %s: %w", p.ins.InsIdentity(), arpc.ErrClientReconnecting)
+			}
+
+			// 没有可用连接, 同步建立
+			if err := idle.connect(); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		if best.inflight.Load() > 0 && idle != nil {
+			p.growAsync(idle)
+		}
+
+		// 与回收互斥: 先计数再确认状态, 回收方先改状态再确认计数
+		best.inflight.Add(1)
+		if best.loadStatus() != ConnReady {
+			best.inflight.Add(-1)
+			continue
+		}
+
+		best.lastUsed.Store(time.Now().UnixNano())
+		return best, nil
+	}
+}
+
+func (p *connPool) growAsync(pc *pooledConn) {
+	if !p.growing.CompareAndSwap(false, true) {
+		return
+	}
+
+	go func() {
+		defer p.growing.Store(false)
+
+		if err := pc.connect(); err != nil {
+			lg := mylog.AppLoggerWithRpc()
+			lg.Warn().Err(err).Str("instance", p.ins.InsIdentity()).Msgf("grow connection pool failed, index:%d", pc.idx)
+		}
+	}()
+}
+
+func (p *connPool) reapLoop() {
+	ticker := time.NewTicker(max(p.cfg.IdleTimeout/2, 10*time.Millisecond))
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-p.stop:
+			return
+		case <-ticker.C:
+			p.reapIdle()
+		}
+	}
+}
+
+// 回收空闲连接, 至少保留一个可用连接
+func (p *connPool) reapIdle() {
+	ready := 0
+	for _, pc := range p.conns {
+		if pc.loadStatus() == ConnReady {
+			ready++
+		}
+	}
+
+	deadline := time.Now().Add(-p.cfg.IdleTimeout).UnixNano()
+	for _, pc := range p.conns {
+		if ready <= 1 {
+			return
+		}
+
+		if pc.lastUsed.Load() > deadline || pc.inflight.Load() > 0 {
+			continue
+		}
+
+		if !pc.status.CompareAndSwap(int32(ConnReady), int32(ConnClosing)) {
+			continue
+		}
+
+		// 已被取用, 放弃回收
+		if pc.inflight.Load() > 0 {
+			pc.status.Store(int32(ConnReady))
+			continue
+		}
+
+		pc.close(ConnIdle)
+		ready--
+	}
+}
+
+func (p *connPool) states() []ConnState {
+	states := make([]ConnState, 0, len(p.conns))
+	for _, pc := range p.conns {
+		states = append(states, pc.state())
+	}
+
+	return states
+}
+
+func (p *connPool) close() {
+	if !p.closed.CompareAndSwap(false, true) {
+		return
+	}
+
+	close(p.stop)
+
+	for _, pc := range p.conns {
+		pc.close(ConnClosed)
+	}
+}
+
+type pooledConn struct {
+	idx  int
+	pool *connPool
+
+	// 串行化建立和关闭
+	mu   sync.Mutex
+	cli  *arpc.Client
+	done chan struct{}
+
+	status     atomic.Int32
+	inflight   atomic.Int64
+	lastUsed   atomic.Int64
+	reconnects atomic.Int64
+	lastErr    atomic.Pointer[string]
+}
+
+func (pc *pooledConn) loadStatus() ConnStatus {
+	return ConnStatus(pc.status.Load())
+}
+
+func (pc *pooledConn) client() *arpc.Client {
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+
+	return pc.cli
+}
+
+func (pc *pooledConn) release() {
+	pc.lastUsed.Store(time.Now().UnixNano())
+	pc.inflight.Add(-1)
+}
+
+func (pc *pooledConn) connect() error {
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+
+	if pc.pool.closed.Load() {
+		return arpc.ErrClientStopped
+	}
+
+	if pc.cli != nil {
+		return nil
+	}
+
+	pc.status.Store(int32(ConnConnecting))
+
+	var (
+		done    = make(chan struct{})
+		first   = true
+		attempt = 0
+	)
+
+	// arpc断线后会反复调用dialer重连, 在此加入退避
+	// handler需在NewClient之前配置好, 创建后收发协程已启动
+	cli, err := arpc.NewClient(func() (net.Conn, error) {
+		if first {
+			first = false
+			return pc.pool.dial()
+		}
+
+		attempt++
+		return pc.redial(done, attempt, func() {
+			attempt = 0
+		})
+	}, pc.pool.handler)
+
+	if err != nil {
+		pc.setLastErr(err)
+		pc.status.Store(int32(ConnIdle))
+		return err
+	}
+
+	// arpc仅在发起调用和调用方读取响应时使用Codec, 在连接对外可用之前替换
+	cli.Codec = pc.pool.codec
+	pc.cli = cli
+	pc.done = done
+	pc.lastUsed.Store(time.Now().UnixNano())
+	pc.status.Store(int32(ConnReady))
+
+	return nil
+}
+
+func (pc *pooledConn) redial(done chan struct{}, attempt int, reset func()) (net.Conn, error) {
+	pc.status.CompareAndSwap(int32(ConnReady), int32(ConnReconnecting))
+
+	timer := time.NewTimer(pc.pool.cfg.backoff(attempt))
+	select {
+	case <-done:
+		timer.Stop()
+		return nil, net.ErrClosed
+	case <-timer.C:
+	}
+
+	conn, err := pc.pool.dial()
+	if err != nil {
+		pc.setLastErr(err)
+		return nil, err
+	}
+
+	reset()
+	pc.reconnects.Add(1)
+	pc.status.CompareAndSwap(int32(ConnReconnecting), int32(ConnReady))
+
+	lg := mylog.AppLoggerWithRpc()
+	lg.Info().Str("instance", pc.pool.ins.InsIdentity()).Msgf("connection reconnected, index:%d, attempts:%d", pc.idx, attempt)
+
+	return conn, nil
+}
+
+func (pc *pooledConn) close(to ConnStatus) {
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+
+	pc.status.Store(int32(to))
+
+	if pc.cli == nil {
+		return
+	}
+
+	close(pc.done)
+	pc.cli.Stop()
+	pc.cli = nil
+	pc.done = nil
+}
+
+func (pc *pooledConn) setLastErr(err error) {
+	msg := err.Error()
+	pc.lastErr.Store(&msg)
+}
+
+func (pc *pooledConn) state() ConnState {
+	st := pc.loadStatus()
+	cs := ConnState{
+		Index:      pc.idx,
+		Status:     st,
+		StatusName: st.String(),
+		Inflight:   pc.inflight.Load(),
+		Reconnects: pc.reconnects.Load(),
+	}
+
+	if lu := pc.lastUsed.Load(); lu > 0 {
+		cs.LastUsed = time.Unix(0, lu)
+	}
+
+	if le := pc.lastErr.Load(); le != nil {
+		cs.LastErr = *le
+	}
+
+	return cs
+}
@@ -0,0 +1,150 @@
+package rclient
+
+import (
+	"context"
+	"github.com/google/uuid"
+	"github.com/lesismal/arpc"
+	"github.com/rs/zerolog"
+	"github.com/sweemingdow/gmicro_pkg/pkg/mylog"
+	"github.com/sweemingdow/gmicro_pkg/pkg/server/srpc"
+	"github.com/sweemingdow/gmicro_pkg/pkg/server/srpc/rpccall"
+	"time"
+)
+
+type CallKind int
+
+const (
+	CallKindCall   CallKind = iota + 1 // Call, CallContext
+	CallKindAsync                      // CallAsync, CallAsyncContext
+	CallKindNotify                     // Notify, NotifyContext
+)
+
+func (ck CallKind) String() string {
+	switch ck {
+	case CallKindCall:
+		return "call"
+	case CallKindAsync:
+		return "async"
+	case CallKindNotify:
+		return "notify"
+	default:
+		return "unknown"
+	}
+}
+
+// Invocation 一次客户端调用, 拦截器可修改其中的字段
+type Invocation struct {
+	Ctx         context.Context // Call, CallAsync, Notify时为context.Background()
+	ServiceName string
+	Method      string
+	Kind        CallKind
+	Req         any
+	Resp        any                   // 只在CallKindCall时有效
+	Timeout     time.Duration         // CallContext, NotifyContext时为0, 以ctx的deadline为准
+	Handler     arpc.AsyncHandlerFunc // 只在CallKindAsync时有效, 可包装以处理响应
+	Args        []any
+
+	// 是否调用arpc的Context版本
+	withCtx bool
+}
+
+// SetValue 设置随请求发送的元数据, 如认证token
+func (inv *Invocation) SetValue(key, val string) {
+	var values map[interface{}]interface{}
+	if len(inv.Args) > 0 {
+		values, _ = inv.Args[0].(map[interface{}]interface{})
+	}
+
+	if values == nil {
+		values = make(map[interface{}]interface{}, 1)
+		inv.Args = append([]any{values}, inv.Args...)
+	}
+
+	values[key] = val
+}
+
+type Invoker func(inv *Invocation) error
+
+// ClientInterceptor 客户端拦截器, 调用next执行后续拦截器和实际调用, 不调用则中断
+// 拦截器包裹整个调用, 重试发生在next内部; CallKindAsync时next返回即表示请求已发出
+type ClientInterceptor func(inv *Invocation, next Invoker) error
+
+// WithInterceptors 按顺序注册客户端拦截器, 作用于除PushMsg外的所有调用
+func WithInterceptors(interceptors ...ClientInterceptor) ProxyOption {
+	return func(acp *arpcClientProxy) {
+		for _, icp := range interceptors {
+			if icp != nil {
+				acp.interceptors = append(acp.interceptors, icp)
+			}
+		}
+	}
+}
+
+func chainInvoker(invoker Invoker, interceptors []ClientInterceptor) Invoker {
+	for i := len(interceptors) - 1; i >= 0; i-- {
+		icp, next := interceptors[i], invoker
+		invoker = func(inv *Invocation) error {
+			return icp(inv, next)
+		}
+	}
+
+	return invoker
+}
+
+// StampRequestId 统一ctx中的请求id和rpccall.RpcReqWrapper.ReqId, 优先使用ctx中的请求id
+func StampRequestId() ClientInterceptor {
+	return func(inv *Invocation, next Invoker) error {
+		carrier, _ := inv.Req.(rpccall.ReqIdCarrier)
+
+		requestId := srpc.RequestIdFrom(inv.Ctx)
+		if requestId == "" && carrier != nil {
+			requestId = carrier.GetReqId()
+		}
+
+		if requestId == "" {
+			requestId = uuid.NewString()
+		}
+
+		inv.Ctx = srpc.WithRequestId(inv.Ctx, requestId)
+
+		if carrier != nil && carrier.GetReqId() == "" {
+			carrier.SetReqId(requestId)
+		}
+
+		return next(inv)
+	}
+}
+
+// CallLog 记录失败和超过slowThreshold的调用, slowThreshold<=0时不记录慢调用
+func CallLog(slowThreshold time.Duration) ClientInterceptor {
+	return func(inv *Invocation, next Invoker) error {
+		start := time.Now()
+		err := next(inv)
+		took := time.Since(start)
+
+		if err == nil && (slowThreshold <= 0 || took < slowThreshold) {
+			return nil
+		}
+
+		lg := mylog.AppLoggerWithRpc()
+
+		var (
+			e   *zerolog.Event
+			msg string
+		)
+		if err != nil {
+			e, msg = lg.Error().Err(err), "rpc call failed"
+		} else {
+			e, msg = lg.Warn(), "slow rpc call"
+		}
+
+		e.Str("service_name", inv.ServiceName).
+			Str("method", inv.Method).
+			Str("kind", inv.Kind.String()).
+			Str("request_id", srpc.RequestIdFrom(inv.Ctx)).
+			Dur("took", took).
+			Msg(msg)
+
+		return err
+	}
+}
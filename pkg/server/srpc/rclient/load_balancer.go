@@ -1,24 +1,251 @@
 package rclient
 
-import "sync/atomic"
+import (
+	"context"
+	"fmt"
+	"github.com/sweemingdow/gmicro_pkg/pkg/regdis"
+	"hash/fnv"
+	"math"
+	"math/rand/v2"
+	"sync"
+	"sync/atomic"
+)
+
+const (
+	LbRoundRobin         = "round_robin"
+	LbWeightedRoundRobin = "weighted_round_robin"
+	LbP2c                = "p2c"
+	LbConsistentHash     = "consistent_hash"
+)
+
+// Endpoint 负载均衡可见的只读实例视图
+type Endpoint interface {
+	// Identity 实例标识, ip:port
+	Identity() string
+
+	// Instance 发现的实例信息, 只读, 不可修改
+	Instance() *regdis.Instance
+
+	// Metadata 实例元数据, 只读, 不可修改
+	Metadata() map[string]string
+
+	// Weight 实例权重, 未配置时为1
+	Weight() float64
+
+	// Inflight 进行中的调用数
+	Inflight() int64
+}
+
+// SelectContext 单次调用的选择上下文
+type SelectContext struct {
+	Ctx context.Context
+
+	// 调用的方法, 如/user/get
+	Method string
+
+	// 路由key, 通过WithRouteKey指定
+	RouteKey string
+
+	// 调用方元数据, 通过WithCallerMetadata指定
+	CallerMetadata map[string]string
+}
 
 type LoadBalancer interface {
-	Select(clients []*arpcClientWrap) *arpcClientWrap
+	// Select 从endpoints中选择一个, 无可用实例时返回nil
+	// 并发调用, 实现需自行保证并发安全, 且不能修改endpoints
+	Select(sc *SelectContext, endpoints []Endpoint) Endpoint
+}
+
+type routeKeyCtxKey struct{}
+
+type callerMetadataCtxKey struct{}
+
+// WithRouteKey 为CallContext/NotifyContext指定路由key, 如一致性hash负载均衡按key选择实例
+func WithRouteKey(ctx context.Context, key string) context.Context {
+	return context.WithValue(ctx, routeKeyCtxKey{}, key)
+}
+
+// WithCallerMetadata 为CallContext/NotifyContext附带调用方元数据, 如zone, tag, 供自定义负载均衡使用
+func WithCallerMetadata(ctx context.Context, md map[string]string) context.Context {
+	return context.WithValue(ctx, callerMetadataCtxKey{}, md)
+}
+
+func newSelectContext(ctx context.Context, method string) *SelectContext {
+	sc := &SelectContext{
+		Ctx:    ctx,
+		Method: method,
+	}
+
+	if ctx == nil {
+		sc.Ctx = context.Background()
+		return sc
+	}
+
+	sc.RouteKey, _ = ctx.Value(routeKeyCtxKey{}).(string)
+	sc.CallerMetadata, _ = ctx.Value(callerMetadataCtxKey{}).(map[string]string)
+
+	return sc
 }
 
 type roundRobinLb struct {
 	counter atomic.Uint64
 }
 
-// warning: Under concurrency protection, operate Select
-func (rb *roundRobinLb) Select(clients []*arpcClientWrap) *arpcClientWrap {
-	if len(clients) == 0 {
+func (rb *roundRobinLb) Select(_ *SelectContext, endpoints []Endpoint) Endpoint {
+	if len(endpoints) == 0 {
 		return nil
 	}
 
-	return clients[rb.counter.Add(1)%uint64(len(clients))]
+	return endpoints[rb.counter.Add(1)%uint64(len(endpoints))]
 }
 
 func NewRoundRobinLoadBalancer() LoadBalancer {
 	return &roundRobinLb{}
 }
+
+// 平滑加权轮询(nginx smooth weighted round-robin)
+// 当前权重按实例标识保存, 实例下线后清理其状态
+type weightedRoundRobinLb struct {
+	mu      sync.Mutex
+	current map[string]float64
+}
+
+func (wrb *weightedRoundRobinLb) Select(_ *SelectContext, endpoints []Endpoint) Endpoint {
+	if len(endpoints) == 0 {
+		return nil
+	}
+
+	wrb.mu.Lock()
+	defer wrb.mu.Unlock()
+
+	var (
+		total   float64
+		best    Endpoint
+		bestCur float64
+	)
+
+	for _, ep := range endpoints {
+		w := ep.Weight()
+		cur := wrb.current[ep.Identity()] + w
+		wrb.current[ep.Identity()] = cur
+		total += w
+
+		if best == nil || cur > bestCur {
+			best, bestCur = ep, cur
+		}
+	}
+
+	wrb.current[best.Identity()] -= total
+
+	// 当前实例均已写入, 多出的即为已下线实例
+	if len(wrb.current) > len(endpoints) {
+		alive := make(map[string]struct{}, len(endpoints))
+		for _, ep := range endpoints {
+			alive[ep.Identity()] = struct{}{}
+		}
+
+		for id := range wrb.current {
+			if _, ok := alive[id]; !ok {
+				delete(wrb.current, id)
+			}
+		}
+	}
+
+	return best
+}
+
+func NewWeightedRoundRobinLoadBalancer() LoadBalancer {
+	return &weightedRoundRobinLb{current: make(map[string]float64)}
+}
+
+// power of two choices: 随机选两个实例, 取进行中调用数较少的
+type p2cLb struct {
+}
+
+func (p *p2cLb) Select(_ *SelectContext, endpoints []Endpoint) Endpoint {
+	n := len(endpoints)
+	if n == 0 {
+		return nil
+	}
+
+	if n == 1 {
+		return endpoints[0]
+	}
+
+	i := rand.IntN(n)
+	j := rand.IntN(n - 1)
+	if j >= i {
+		j++
+	}
+
+	a, b := endpoints[i], endpoints[j]
+
+	// 按权重折算负载, 权重高的实例可承担更多进行中调用
+	if float64(a.Inflight()+1)/a.Weight() <= float64(b.Inflight()+1)/b.Weight() {
+		return a
+	}
+
+	return b
+}
+
+func NewP2cLoadBalancer() LoadBalancer {
+	return &p2cLb{}
+}
+
+// 一致性hash, 采用加权rendezvous hash: 实例增减时只有落在该实例上的key会迁移
+// 未指定路由key时退化为轮询
+type consistentHashLb struct {
+	fallback roundRobinLb
+}
+
+func (ch *consistentHashLb) Select(sc *SelectContext, endpoints []Endpoint) Endpoint {
+	if len(endpoints) == 0 {
+		return nil
+	}
+
+	if sc == nil || sc.RouteKey == "" {
+		return ch.fallback.Select(sc, endpoints)
+	}
+
+	var (
+		best      Endpoint
+		bestScore = math.Inf(-1)
+	)
+
+	for _, ep := range endpoints {
+		h := fnv.New64a()
+		_, _ = h.Write([]byte(sc.RouteKey))
+		_, _ = h.Write([]byte{'#'})
+		_, _ = h.Write([]byte(ep.Identity()))
+
+		// 映射到(0,1)区间
+		u := (float64(h.Sum64()>>11) + 0.5) / (1 << 53)
+		score := -ep.Weight() / math.Log(u)
+
+		if score > bestScore {
+			best, bestScore = ep, score
+		}
+	}
+
+	return best
+}
+
+func NewConsistentHashLoadBalancer() LoadBalancer {
+	return &consistentHashLb{}
+}
+
+// NewLoadBalancer 按名称创建负载均衡器, 名称为空时使用轮询
+func NewLoadBalancer(name string) (LoadBalancer, error) {
+	switch name {
+	case "", LbRoundRobin:
+		return NewRoundRobinLoadBalancer(), nil
+	case LbWeightedRoundRobin:
+		return NewWeightedRoundRobinLoadBalancer(), nil
+	case LbP2c:
+		return NewP2cLoadBalancer(), nil
+	case LbConsistentHash:
+		return NewConsistentHashLoadBalancer(), nil
+	default:
+		return nil, fmt.Errorf("unsupported load balancer:%s", name)
+	}
+}
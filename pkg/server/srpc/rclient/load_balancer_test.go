@@ -0,0 +1,105 @@
+package rclient
+
+import (
+	"context"
+	"fmt"
+	"github.com/sweemingdow/gmicro_pkg/pkg/regdis"
+	"testing"
+)
+
+// 自定义的Endpoint实现, 与外部包实现负载均衡时一致
+type fakeEndpoint struct {
+	ins      *regdis.Instance
+	inflight int64
+}
+
+func (fe *fakeEndpoint) Identity() string            { return fe.ins.InsIdentity() }
+func (fe *fakeEndpoint) Instance() *regdis.Instance  { return fe.ins }
+func (fe *fakeEndpoint) Metadata() map[string]string { return fe.ins.Metadata }
+func (fe *fakeEndpoint) Weight() float64             { return fe.ins.Weight }
+func (fe *fakeEndpoint) Inflight() int64             { return fe.inflight }
+
+func fakeEndpoints(weights ...float64) []Endpoint {
+	endpoints := make([]Endpoint, 0, len(weights))
+	for i, w := range weights {
+		endpoints = append(endpoints, &fakeEndpoint{
+			ins: &regdis.Instance{Ip: fmt.Sprintf("127.0.0.%d", i+1), Port: 9090, Weight: w},
+		})
+	}
+
+	return endpoints
+}
+
+func keyed(key string) *SelectContext {
+	return newSelectContext(WithRouteKey(context.Background(), key), "/one/method")
+}
+
+func TestWeightedRoundRobin(t *testing.T) {
+	clients := fakeEndpoints(5, 1, 1)
+	lb := NewWeightedRoundRobinLoadBalancer()
+
+	counts := make(map[Endpoint]int)
+	seq := make([]int, 0, 7)
+	for i := 0; i < 7; i++ {
+		cli := lb.Select(keyed(""), clients)
+		counts[cli]++
+
+		for idx, c := range clients {
+			if c == cli {
+				seq = append(seq, idx)
+			}
+		}
+	}
+
+	if counts[clients[0]] != 5 || counts[clients[1]] != 1 || counts[clients[2]] != 1 {
+		t.Fatalf("unexpected distribution:%v", seq)
+	}
+
+	// 平滑: 低权重实例不会被挤到一轮的末尾
+	if fmt.Sprint(seq) != "[0 0 1 0 2 0 0]" {
+		t.Fatalf("unexpected sequence:%v", seq)
+	}
+
+	// 下线实例的权重状态被清理
+	lb.Select(keyed(""), clients[:1])
+	if cur := lb.(*weightedRoundRobinLb).current; len(cur) != 1 {
+		t.Fatalf("stale weights not pruned:%v", cur)
+	}
+}
+
+func TestP2cPrefersLessInflight(t *testing.T) {
+	clients := fakeEndpoints(1, 1)
+	clients[0].(*fakeEndpoint).inflight = 100
+
+	lb := NewP2cLoadBalancer()
+	for i := 0; i < 20; i++ {
+		if lb.Select(keyed(""), clients) != clients[1] {
+			t.Fatal("expect the idle instance to be selected")
+		}
+	}
+}
+
+func TestConsistentHashSticky(t *testing.T) {
+	clients := fakeEndpoints(1, 1, 1, 1)
+	lb := NewConsistentHashLoadBalancer()
+
+	before := make(map[string]Endpoint)
+	for i := 0; i < 200; i++ {
+		key := fmt.Sprintf("user-%d", i)
+		before[key] = lb.Select(keyed(key), clients)
+
+		if lb.Select(keyed(key), clients) != before[key] {
+			t.Fatalf("key:%s not sticky", key)
+		}
+	}
+
+	// 移除一个实例, 只有原本落在该实例上的key迁移
+	removed := clients[3]
+	remain := clients[:3]
+	for key, cli := range before {
+		after := lb.Select(keyed(key), remain)
+		if cli != removed && after != cli {
+			t.Fatalf("key:%s moved unexpectedly", key)
+		}
+	}
+}
@@ -2,6 +2,8 @@ package rcfactory
 
 import (
 	"context"
+	"fmt"
+	"github.com/sweemingdow/gmicro_pkg/pkg/health"
 	"github.com/sweemingdow/gmicro_pkg/pkg/lifetime"
 	"github.com/sweemingdow/gmicro_pkg/pkg/server/srpc/rclient"
 )
@@ -18,3 +20,22 @@ type ArpcClientFactoryLifecycle interface {
 
 	ArpcClientFactory
 }
+
+// RequireReady 指定的下游服务没有可用实例时视为未就绪
+// 默认不检查下游, 避免相互依赖的服务, 或某个下游故障时阻塞注册
+func RequireReady(f ArpcClientFactory, serviceNames ...string) health.Checker {
+	return health.CheckFunc(func(_ context.Context) error {
+		var notReady []string
+		for _, srvName := range serviceNames {
+			if !f.AcquireClient(srvName).IsReady() {
+				notReady = append(notReady, srvName)
+			}
+		}
+
+		if len(notReady) > 0 {
+			return fmt.Errorf("arpc client proxy not ready for services:%v", notReady)
+		}
+
+		return nil
+	})
+}
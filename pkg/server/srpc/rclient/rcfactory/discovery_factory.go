@@ -0,0 +1,131 @@
+package rcfactory
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/sweemingdow/gmicro_pkg/pkg/mylog"
+	"github.com/sweemingdow/gmicro_pkg/pkg/regdis"
+	"github.com/sweemingdow/gmicro_pkg/pkg/server/srpc/rclient"
+	"sync"
+	"time"
+)
+
+const defaultDialTimeout = 2 * time.Second
+
+// 与注册中心实现无关的arpc client工厂
+type arpcClientFactory struct {
+	discovery regdis.Discovery
+
+	lb rclient.LoadBalancer
+
+	name2clientProxy map[string]rclient.ArpcClientProxy
+
+	// extra data for discovery
+	extraMap map[string]any
+
+	dialTimeout time.Duration
+
+	// 创建client proxy时的选项, 如熔断
+	proxyOpts []rclient.ProxyOption
+
+	rwMu sync.RWMutex
+}
+
+func NewArpcClientFactory(discovery regdis.Discovery, lb rclient.LoadBalancer, extraMap map[string]any, dialTimeout time.Duration, opts ...rclient.ProxyOption) ArpcClientFactoryLifecycle {
+	if dialTimeout <= 0 {
+		dialTimeout = defaultDialTimeout
+	}
+
+	return &arpcClientFactory{
+		discovery:        discovery,
+		lb:               lb,
+		name2clientProxy: make(map[string]rclient.ArpcClientProxy, 2),
+		extraMap:         extraMap,
+		dialTimeout:      dialTimeout,
+		proxyOpts:        opts,
+	}
+}
+
+func (acf *arpcClientFactory) AcquireClient(serviceName string) rclient.ArpcClientProxy {
+	if serviceName == "" {
+		panic("the service name is required")
+	}
+
+	// lazy init
+	acf.rwMu.RLock()
+	cp, ok := acf.name2clientProxy[serviceName]
+	acf.rwMu.RUnlock()
+
+	if ok {
+		return cp
+	}
+
+	acf.rwMu.Lock()
+	defer acf.rwMu.Unlock()
+
+	// check again
+	if cp, ok := acf.name2clientProxy[serviceName]; ok {
+		return cp
+	}
+
+	// service's client proxy not exists, do lazy init now
+	cp, err := rclient.NewArpcClientProxy(
+		serviceName,
+		acf.discovery,
+		acf.extraMap,
+		acf.lb,
+		acf.dialTimeout,
+		acf.proxyOpts...,
+	)
+
+	if err != nil {
+		lg := mylog.AppLogger()
+		lg.Error().Stack().Err(err).Send()
+	}
+
+	// always save, because dynamic listen may trigger data update
+	acf.name2clientProxy[serviceName] = cp
+
+	return cp
+}
+
+func (acf *arpcClientFactory) Stop(ctx context.Context) error {
+	acf.rwMu.RLock()
+	defer acf.rwMu.RUnlock()
+
+	var allErrs []error
+	for srvName, proxy := range acf.name2clientProxy {
+		if err := proxy.Stop(ctx); err != nil {
+			allErrs = append(allErrs, fmt.Errorf("%s's arpc client proxy stopped failed:%w", srvName, err))
+		}
+
+		select {
+		case <-ctx.Done():
+			allErrs = append(allErrs, ctx.Err())
+			return errors.Join(allErrs...)
+		default:
+
+		}
+	}
+
+	return errors.Join(allErrs...)
+}
+
+func (acf *arpcClientFactory) OnCreated(_ chan<- error) {
+}
+
+func (acf *arpcClientFactory) OnDispose(ctx context.Context) error {
+	lg := mylog.AppLoggerWithStop()
+	lg.Debug().Msg("arpc client factory stop now")
+
+	err := acf.Stop(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	lg.Info().Msg("arpc client factory stopped successfully")
+
+	return nil
+}
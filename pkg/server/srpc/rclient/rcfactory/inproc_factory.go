@@ -0,0 +1,180 @@
+package rcfactory
+
+import (
+	"context"
+	"fmt"
+	"github.com/lesismal/arpc"
+	"github.com/sweemingdow/gmicro_pkg/pkg/regdis"
+	"github.com/sweemingdow/gmicro_pkg/pkg/regdis/regdismem"
+	"github.com/sweemingdow/gmicro_pkg/pkg/server/srpc"
+	"github.com/sweemingdow/gmicro_pkg/pkg/server/srpc/rclient"
+	"net"
+	"sync"
+	"time"
+)
+
+const inProcHost = "inproc"
+
+// InProcArpcClientFactory 进程内的arpc client工厂, 客户端与服务端通过net.Pipe通信, 不经过网络, 用于测试
+//
+//	f := rcfactory.NewInProcArpcClientFactory()
+//	cauth.BindArpcAuthRpcServer(f.Server("two_service"), impl)
+//	provider := cauth.NewAuthRpcProvider(f)
+type InProcArpcClientFactory struct {
+	mrd *regdismem.MemRegDis
+
+	acf ArpcClientFactoryLifecycle
+
+	mu sync.Mutex
+
+	servers map[string]*inProcServer
+}
+
+type inProcServer struct {
+	srv *arpc.Server
+	ln  *pipeListener
+}
+
+// opts作用于每个服务的client proxy, 如rclient.WithInterceptors
+func NewInProcArpcClientFactory(opts ...rclient.ProxyOption) *InProcArpcClientFactory {
+	f := &InProcArpcClientFactory{
+		mrd:     regdismem.NewMemRegDis(false),
+		servers: make(map[string]*inProcServer),
+	}
+
+	f.acf = NewArpcClientFactory(
+		f.mrd,
+		rclient.NewRoundRobinLoadBalancer(),
+		nil,
+		time.Second,
+		append(opts, rclient.WithDialer(f.dial))...,
+	)
+
+	return f
+}
+
+// Server 获取服务的进程内arpc服务端, 不存在时创建并注册, 路由需在调用前绑定完成
+// codec和元数据编码与srpc.NewArpcServer一致, 但不依赖app和日志的初始化
+func (f *InProcArpcClientFactory) Server(serviceName string) *arpc.Server {
+	f.mu.Lock()
+
+	if ips, ok := f.servers[serviceName]; ok {
+		f.mu.Unlock()
+		return ips.srv
+	}
+
+	srv := arpc.NewServer()
+	srv.Codec = srpc.ArpcCodec(srpc.JsonIterCodec{})
+	srv.Handler.UseCoder(srpc.MetaCoder{})
+
+	ips := &inProcServer{
+		srv: srv,
+		ln:  newPipeListener(serviceName),
+	}
+
+	go func() {
+		_ = srv.Serve(ips.ln)
+	}()
+
+	f.servers[serviceName] = ips
+	addr := fmt.Sprintf("%s:%d", inProcHost, len(f.servers))
+
+	f.mu.Unlock()
+
+	// 注册会同步通知已创建的proxy建连, 进而调用dial, 因此需在释放mu之后
+	_ = f.mrd.Register(regdis.RegisterParam{
+		ServiceName: serviceName,
+		Addr:        addr,
+	})
+
+	return ips.srv
+}
+
+func (f *InProcArpcClientFactory) AcquireClient(serviceName string) rclient.ArpcClientProxy {
+	return f.acf.AcquireClient(serviceName)
+}
+
+func (f *InProcArpcClientFactory) Stop(ctx context.Context) error {
+	err := f.acf.Stop(ctx)
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	// 关闭listener后Serve退出并断开已有连接
+	// arpc.Server.Stop修改running时未加锁, 与Serve存在数据竞争, 因此不使用
+	for _, ips := range f.servers {
+		_ = ips.ln.Close()
+	}
+	f.servers = make(map[string]*inProcServer)
+
+	return err
+}
+
+func (f *InProcArpcClientFactory) dial(ins *regdis.Instance, _ time.Duration) (net.Conn, error) {
+	f.mu.Lock()
+	ips, ok := f.servers[ins.ServiceName]
+	f.mu.Unlock()
+
+	if !ok {
+		return nil, fmt.Errorf("in-process server not found for service:%s", ins.ServiceName)
+	}
+
+	return ips.ln.dial()
+}
+
+// 基于net.Pipe的listener
+type pipeListener struct {
+	addr   pipeAddr
+	conns  chan net.Conn
+	closed chan struct{}
+	once   sync.Once
+}
+
+func newPipeListener(serviceName string) *pipeListener {
+	return &pipeListener{
+		addr:   pipeAddr(serviceName),
+		conns:  make(chan net.Conn),
+		closed: make(chan struct{}),
+	}
+}
+
+func (pl *pipeListener) dial() (net.Conn, error) {
+	cliConn, srvConn := net.Pipe()
+
+	select {
+	case pl.conns <- srvConn:
+		return cliConn, nil
+	case <-pl.closed:
+		return nil, net.ErrClosed
+	}
+}
+
+func (pl *pipeListener) Accept() (net.Conn, error) {
+	select {
+	case conn := <-pl.conns:
+		return conn, nil
+	case <-pl.closed:
+		return nil, net.ErrClosed
+	}
+}
+
+func (pl *pipeListener) Close() error {
+	pl.once.Do(func() {
+		close(pl.closed)
+	})
+	return nil
+}
+
+func (pl *pipeListener) Addr() net.Addr {
+	return pl.addr
+}
+
+type pipeAddr string
+
+func (pa pipeAddr) Network() string {
+	return "pipe"
+}
+
+func (pa pipeAddr) String() string {
+	return string(pa)
+}
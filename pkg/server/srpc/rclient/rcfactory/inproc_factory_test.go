@@ -0,0 +1,99 @@
+package rcfactory
+
+import (
+	"context"
+	"github.com/sweemingdow/gmicro_pkg/pkg/server/srpc"
+	"github.com/sweemingdow/gmicro_pkg/pkg/server/srpc/rpccall"
+	"testing"
+	"time"
+)
+
+type echoReq struct {
+	Msg string `json:"msg"`
+}
+
+func TestInProcArpcClientFactory(t *testing.T) {
+	f := NewInProcArpcClientFactory()
+	defer f.Stop(context.Background())
+
+	srpc.Register(f.Server("echo_service"), "/echo", func(ctx context.Context, req rpccall.RpcReqWrapper[echoReq]) (rpccall.RpcRespWrapper[string], error) {
+		return rpccall.Ok(req.Req.Msg), nil
+	})
+
+	var resp rpccall.RpcRespWrapper[string]
+	req := rpccall.CreateReq(echoReq{Msg: "hi"})
+	if err := f.AcquireClient("echo_service").Call("/echo", &req, &resp, time.Second); err != nil {
+		t.Fatal(err)
+	}
+
+	if !resp.IsOk() || resp.Resp != "hi" {
+		t.Fatalf("unexpected resp:%+v", resp)
+	}
+
+	if err := f.AcquireClient("missing_service").Call("/echo", &req, &resp, time.Second); err == nil {
+		t.Fatal("expect error for unknown service")
+	}
+}
+
+// 先获取client再创建服务端时, 注册通知proxy建连不能死锁
+func TestInProcArpcClientFactoryAcquireBeforeServer(t *testing.T) {
+	f := NewInProcArpcClientFactory()
+	defer f.Stop(context.Background())
+
+	cli := f.AcquireClient("late_service")
+
+	created := make(chan struct{})
+	go func() {
+		srpc.Register(f.Server("late_service"), "/echo", func(ctx context.Context, req rpccall.RpcReqWrapper[echoReq]) (rpccall.RpcRespWrapper[string], error) {
+			return rpccall.Ok(req.Req.Msg), nil
+		})
+		close(created)
+	}()
+
+	select {
+	case <-created:
+	case <-time.After(3 * time.Second):
+		t.Fatal("Server blocked after AcquireClient")
+	}
+
+	var resp rpccall.RpcRespWrapper[string]
+	req := rpccall.CreateReq(echoReq{Msg: "late"})
+	if err := cli.Call("/echo", &req, &resp, time.Second); err != nil {
+		t.Fatal(err)
+	}
+
+	if !resp.IsOk() || resp.Resp != "late" {
+		t.Fatalf("unexpected resp:%+v", resp)
+	}
+}
+
+// 仅指定的下游参与就绪检查
+func TestRequireReady(t *testing.T) {
+	f := NewInProcArpcClientFactory()
+	defer f.Stop(context.Background())
+
+	f.AcquireClient("optional_service")
+
+	if err := RequireReady(f).HealthCheck(context.Background()); err != nil {
+		t.Fatalf("downstream readiness should be opt-in:%v", err)
+	}
+
+	checker := RequireReady(f, "required_service")
+	if err := checker.HealthCheck(context.Background()); err == nil {
+		t.Fatal("expected not ready without instances")
+	}
+
+	srpc.Register(f.Server("required_service"), "/echo", func(ctx context.Context, req rpccall.RpcReqWrapper[echoReq]) (rpccall.RpcRespWrapper[string], error) {
+		return rpccall.Ok(req.Req.Msg), nil
+	})
+
+	if err := checker.HealthCheck(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	var resp rpccall.RpcRespWrapper[string]
+	req := rpccall.CreateReq(echoReq{Msg: "ready"})
+	if err := f.AcquireClient("required_service").Call("/echo", &req, &resp, time.Second); err != nil {
+		t.Fatal(err)
+	}
+}
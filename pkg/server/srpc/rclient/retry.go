@@ -0,0 +1,136 @@
+package rclient
+
+import (
+	"context"
+	"errors"
+	"github.com/lesismal/arpc"
+	"github.com/sweemingdow/gmicro_pkg/pkg/myerr"
+	"io"
+	"math"
+	"math/rand/v2"
+	"net"
+	"sync"
+	"syscall"
+	"time"
+)
+
+const (
+	// RetryAnyMethod 作为method注册时, 对服务下未单独声明的方法生效
+	RetryAnyMethod = "*"
+
+	defaultInitialBackoff = 50 * time.Millisecond
+	defaultMaxBackoff     = time.Second
+	defaultMultiplier     = 2.0
+	defaultJitter         = 0.2
+)
+
+// RetryPolicy 方法级重试策略
+// 只有连接类和超时错误会重试, 业务错误(RpcRespError等)不会重试,
+// 因此只应为幂等方法声明重试
+type RetryPolicy struct {
+	MaxAttempts    int           // 总尝试次数(含首次), <=1时不重试
+	InitialBackoff time.Duration // 首次重试前的等待, 默认50ms
+	MaxBackoff     time.Duration // 等待上限, 默认1s
+	Multiplier     float64       // 退避倍数, 默认2
+	Jitter         float64       // 随机抖动比例(0~1), 默认0.2
+}
+
+func (rp RetryPolicy) enabled() bool {
+	return rp.MaxAttempts > 1
+}
+
+// 第retry次重试前的等待时间, retry从1开始
+func (rp RetryPolicy) backoff(retry int) time.Duration {
+	initial := rp.InitialBackoff
+	if initial <= 0 {
+		initial = defaultInitialBackoff
+	}
+
+	maxBackoff := rp.MaxBackoff
+	if maxBackoff <= 0 {
+		maxBackoff = defaultMaxBackoff
+	}
+
+	multiplier := rp.Multiplier
+	if multiplier < 1 {
+		multiplier = defaultMultiplier
+	}
+
+	jitter := rp.Jitter
+	if jitter <= 0 || jitter > 1 {
+		jitter = defaultJitter
+	}
+
+	d := math.Min(float64(initial)*math.Pow(multiplier, float64(retry-1)), float64(maxBackoff))
+	d = d * (1 - jitter + 2*jitter*rand.Float64())
+
+	return time.Duration(d)
+}
+
+var retryPolicies sync.Map
+
+func retryPolicyKey(serviceName, method string) string {
+	return serviceName + "#" + method
+}
+
+// RegisterRetryPolicy 声明服务方法的重试策略, method为RetryAnyMethod时作为该服务的默认策略
+// rpcgen根据@retry注解生成的代码也通过此函数注册
+func RegisterRetryPolicy(serviceName, method string, policy RetryPolicy) {
+	retryPolicies.Store(retryPolicyKey(serviceName, method), policy)
+}
+
+func lookupRetryPolicy(serviceName, method string) RetryPolicy {
+	if val, ok := retryPolicies.Load(retryPolicyKey(serviceName, method)); ok {
+		return val.(RetryPolicy)
+	}
+
+	if val, ok := retryPolicies.Load(retryPolicyKey(serviceName, RetryAnyMethod)); ok {
+		return val.(RetryPolicy)
+	}
+
+	return RetryPolicy{}
+}
+
+// IsRetryableErr 是否为可重试的错误: 连接异常, 客户端重连/停止, 调用超时
+func IsRetryableErr(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	if _, ok := myerr.DecodeRpcRespError(err); ok {
+		return false
+	}
+
+	if _, ok := myerr.DecodeRpcParamValidateError(err); ok {
+		return false
+	}
+
+	if myerr.IsRpcBindError(err) {
+		return false
+	}
+
+	// 调用方的ctx结束, 重试没有意义
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+
+	if errors.Is(err, arpc.ErrClientTimeout) ||
+		errors.Is(err, arpc.ErrTimeout) ||
+		errors.Is(err, arpc.ErrClientReconnecting) ||
+		errors.Is(err, arpc.ErrClientStopped) ||
+		errors.Is(err, arpc.ErrClientOverstock) {
+		return true
+	}
+
+	if errors.Is(err, io.EOF) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, net.ErrClosed) ||
+		errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, syscall.EPIPE) {
+		return true
+	}
+
+	var ne net.Error
+	return errors.As(err, &ne)
+}
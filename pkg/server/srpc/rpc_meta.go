@@ -0,0 +1,251 @@
+package srpc
+
+import (
+	"context"
+	"encoding/binary"
+	"github.com/google/uuid"
+	"github.com/lesismal/arpc"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 跨进程传递的元数据key, 以arpc message values的形式随请求发送
+const (
+	MetaKeyTimeoutMills = "gm-timeout-ms" // 调用方剩余的超时时间
+	MetaKeyRequestId    = "gm-request-id" // 请求id, 未指定时自动生成
+	MetaKeyCallerAppId  = "gm-caller-app" // 调用方的app id
+	MetaKeyBaggagePref  = "gm-baggage-"   // 自定义透传数据的key前缀
+)
+
+const (
+	metaFlagBit         = 0         // message reserved byte中标识携带元数据的bit
+	metaTrailerLenBytes = 4         // 元数据尾部的长度字段
+	metaMaxEntryLen     = 1<<16 - 1 // 单个key/value的最大长度
+)
+
+type requestIdCtxKey struct{}
+
+type callerAppIdCtxKey struct{}
+
+type baggageCtxKey struct{}
+
+// WithRequestId 指定请求id, 未指定时客户端自动生成
+func WithRequestId(ctx context.Context, requestId string) context.Context {
+	return context.WithValue(ctx, requestIdCtxKey{}, requestId)
+}
+
+func RequestIdFrom(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+
+	id, _ := ctx.Value(requestIdCtxKey{}).(string)
+	return id
+}
+
+// CallerAppIdFrom 服务端获取调用方的app id
+func CallerAppIdFrom(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+
+	id, _ := ctx.Value(callerAppIdCtxKey{}).(string)
+	return id
+}
+
+// WithBaggage 追加随调用链透传的数据, 服务端通过ContextFrom还原后继续向下游透传
+func WithBaggage(ctx context.Context, key, val string) context.Context {
+	old := BaggageFrom(ctx)
+
+	bg := make(map[string]string, len(old)+1)
+	for k, v := range old {
+		bg[k] = v
+	}
+	bg[key] = val
+
+	return context.WithValue(ctx, baggageCtxKey{}, bg)
+}
+
+// BaggageFrom 获取透传数据, 只读
+func BaggageFrom(ctx context.Context) map[string]string {
+	if ctx == nil {
+		return nil
+	}
+
+	bg, _ := ctx.Value(baggageCtxKey{}).(map[string]string)
+	return bg
+}
+
+// OutgoingMeta 客户端根据ctx和本次调用的超时时间构建需要发送的元数据
+// timeout与ctx的deadline同时存在时取较早者
+func OutgoingMeta(ctx context.Context, timeout time.Duration, callerAppId string) map[string]string {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	md := make(map[string]string, 3+len(BaggageFrom(ctx)))
+
+	remain := timeout
+	if dl, ok := ctx.Deadline(); ok {
+		if left := time.Until(dl); remain <= 0 || left < remain {
+			remain = left
+		}
+	}
+
+	if remain > 0 {
+		md[MetaKeyTimeoutMills] = strconv.FormatInt(remain.Milliseconds(), 10)
+	}
+
+	requestId := RequestIdFrom(ctx)
+	if requestId == "" {
+		requestId = uuid.NewString()
+	}
+	md[MetaKeyRequestId] = requestId
+
+	if callerAppId != "" {
+		md[MetaKeyCallerAppId] = callerAppId
+	}
+
+	for k, v := range BaggageFrom(ctx) {
+		md[MetaKeyBaggagePref+k] = v
+	}
+
+	return md
+}
+
+// ContextFrom 服务端根据调用方传递的元数据还原ctx, 包含请求id, 调用方app id, 透传数据,
+// 并以调用方剩余的超时时间作为deadline, 处理完成后需调用cancel
+func ContextFrom(c *arpc.Context) (context.Context, context.CancelFunc) {
+	ctx := context.Background()
+	if c == nil || c.Message == nil {
+		return context.WithCancel(ctx)
+	}
+
+	var (
+		timeout time.Duration
+		bg      map[string]string
+	)
+
+	for k, v := range c.Values() {
+		key, _ := k.(string)
+		val, _ := v.(string)
+
+		switch {
+		case key == MetaKeyTimeoutMills:
+			if mills, err := strconv.ParseInt(val, 10, 64); err == nil && mills > 0 {
+				timeout = time.Duration(mills) * time.Millisecond
+			}
+		case key == MetaKeyRequestId:
+			ctx = context.WithValue(ctx, requestIdCtxKey{}, val)
+		case key == MetaKeyCallerAppId:
+			ctx = context.WithValue(ctx, callerAppIdCtxKey{}, val)
+		case strings.HasPrefix(key, MetaKeyBaggagePref):
+			if bg == nil {
+				bg = make(map[string]string)
+			}
+			bg[strings.TrimPrefix(key, MetaKeyBaggagePref)] = val
+		}
+	}
+
+	if bg != nil {
+		ctx = context.WithValue(ctx, baggageCtxKey{}, bg)
+	}
+
+	if pi, ok := PeerOf(c); ok {
+		ctx = context.WithValue(ctx, peerCtxKey{}, pi)
+	}
+
+	if timeout > 0 {
+		return context.WithTimeout(ctx, timeout)
+	}
+
+	return context.WithCancel(ctx)
+}
+
+// MetaCoder 将message values编码到消息尾部随请求发送, 客户端和服务端需同时启用
+//
+//	body: method | data | (klen uint16 | key | vlen uint16 | val)... | trailerLen uint32
+type MetaCoder struct {
+}
+
+func (MetaCoder) Encode(cli *arpc.Client, msg *arpc.Message) *arpc.Message {
+	// 响应会带上请求的values, 只编码请求和通知
+	if cmd := msg.Cmd(); cmd != arpc.CmdRequest && cmd != arpc.CmdNotify {
+		return msg
+	}
+
+	values := msg.Values()
+	if len(values) == 0 || msg.IsFlagBitSet(metaFlagBit) {
+		return msg
+	}
+
+	trailer := make([]byte, 0, 64)
+	for k, v := range values {
+		key, ok1 := k.(string)
+		val, ok2 := v.(string)
+		if !ok1 || !ok2 || len(key) > metaMaxEntryLen || len(val) > metaMaxEntryLen {
+			continue
+		}
+
+		trailer = binary.LittleEndian.AppendUint16(trailer, uint16(len(key)))
+		trailer = append(trailer, key...)
+		trailer = binary.LittleEndian.AppendUint16(trailer, uint16(len(val)))
+		trailer = append(trailer, val...)
+	}
+
+	if len(trailer) == 0 {
+		return msg
+	}
+
+	trailer = binary.LittleEndian.AppendUint32(trailer, uint32(len(trailer)))
+
+	msg.Buffer = cli.Handler.Append(msg.Buffer, trailer...)
+	msg.SetBodyLen(msg.BodyLen() + len(trailer))
+	_ = msg.SetFlagBit(metaFlagBit, true)
+
+	return msg
+}
+
+func (MetaCoder) Decode(_ *arpc.Client, msg *arpc.Message) *arpc.Message {
+	if !msg.IsFlagBitSet(metaFlagBit) {
+		return msg
+	}
+
+	buf := msg.Buffer
+	if len(buf) < arpc.HeadLen+msg.MethodLen()+metaTrailerLenBytes {
+		return msg
+	}
+
+	trailerLen := int(binary.LittleEndian.Uint32(buf[len(buf)-metaTrailerLenBytes:]))
+	end := len(buf) - metaTrailerLenBytes
+	start := end - trailerLen
+	if start < arpc.HeadLen+msg.MethodLen() {
+		return msg
+	}
+
+	trailer := buf[start:end]
+	for len(trailer) >= 2 {
+		kl := int(binary.LittleEndian.Uint16(trailer))
+		if len(trailer) < 2+kl+2 {
+			break
+		}
+		key := string(trailer[2 : 2+kl])
+		trailer = trailer[2+kl:]
+
+		vl := int(binary.LittleEndian.Uint16(trailer))
+		if len(trailer) < 2+vl {
+			break
+		}
+		val := string(trailer[2 : 2+vl])
+		trailer = trailer[2+vl:]
+
+		msg.Set(key, val)
+	}
+
+	msg.Buffer = buf[:start]
+	msg.SetBodyLen(msg.BodyLen() - trailerLen - metaTrailerLenBytes)
+	_ = msg.SetFlagBit(metaFlagBit, false)
+
+	return msg
+}
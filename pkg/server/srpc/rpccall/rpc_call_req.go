@@ -53,3 +53,23 @@ func LoggerWrapWithReq[T any](req RpcReqWrapper[T], lg zerolog.Logger) zerolog.L
 func LoggerWrapWithReqId(reqId string, lg zerolog.Logger) zerolog.Logger {
 	return lg.With().Str("req_id", reqId).Logger()
 }
+
+// ReqIdCarrier 携带请求id的请求, 客户端拦截器据此写入请求id
+type ReqIdCarrier interface {
+	GetReqId() string
+
+	SetReqId(reqId string)
+}
+
+func (rw *RpcReqWrapper[T]) GetReqId() string {
+	return rw.ReqId
+}
+
+func (rw *RpcReqWrapper[T]) SetReqId(reqId string) {
+	rw.ReqId = reqId
+}
+
+// PayloadPtr 业务数据的指针, 用于单独编码业务数据, 见srpc.ProtobufCodec
+func (rw *RpcReqWrapper[T]) PayloadPtr() any {
+	return &rw.Req
+}
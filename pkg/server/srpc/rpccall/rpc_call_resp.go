@@ -98,6 +98,11 @@ func (resp RpcRespWrapper[T]) OkOrErr() (T, error) {
 	return zero, myerr.NewRpcRespError(resp.Code, resp.ErrDesc, resp.Msg)
 }
 
+// PayloadPtr 业务数据的指针, 用于单独编码业务数据, 见srpc.ProtobufCodec
+func (resp *RpcRespWrapper[T]) PayloadPtr() any {
+	return &resp.Resp
+}
+
 func Ok[T any](resp T) RpcRespWrapper[T] {
 	return RpcRespWrapper[T]{
 		Code: CallOk,
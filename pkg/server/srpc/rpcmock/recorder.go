@@ -0,0 +1,100 @@
+package rpcmock
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ErrNotStubbed 调用了未设置桩的mock方法
+var ErrNotStubbed = errors.New("rpcmock: method not stubbed")
+
+func NotStubbed(iface, method string) error {
+	return fmt.Errorf("%w: %s.%s", ErrNotStubbed, iface, method)
+}
+
+// Call 一次被记录的调用
+type Call struct {
+	Method string
+	Path   string
+	Req    any
+	At     time.Time
+}
+
+// TB testing.TB的子集, 避免非测试代码引入testing包
+type TB interface {
+	Helper()
+	Fatalf(format string, args ...any)
+}
+
+// Recorder 记录mock的调用, 由rpcgen生成的Mock嵌入, 并发安全
+type Recorder struct {
+	mu    sync.Mutex
+	calls []Call
+}
+
+func (r *Recorder) Record(method, path string, req any) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.calls = append(r.calls, Call{
+		Method: method,
+		Path:   path,
+		Req:    req,
+		At:     time.Now(),
+	})
+}
+
+// Calls 按调用顺序返回method的调用记录, method为空时返回全部
+func (r *Recorder) Calls(method string) []Call {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var calls []Call
+	for _, c := range r.calls {
+		if method == "" || c.Method == method {
+			calls = append(calls, c)
+		}
+	}
+
+	return calls
+}
+
+func (r *Recorder) CallCount(method string) int {
+	return len(r.Calls(method))
+}
+
+// LastCall method最近一次的调用
+func (r *Recorder) LastCall(method string) (Call, bool) {
+	calls := r.Calls(method)
+	if len(calls) == 0 {
+		return Call{}, false
+	}
+
+	return calls[len(calls)-1], true
+}
+
+// AssertCalled 断言method恰好被调用times次
+func (r *Recorder) AssertCalled(t TB, method string, times int) {
+	t.Helper()
+
+	if n := r.CallCount(method); n != times {
+		t.Fatalf("expect %s called %d times, got %d", method, times, n)
+	}
+}
+
+func (r *Recorder) AssertNotCalled(t TB, method string) {
+	t.Helper()
+
+	if n := r.CallCount(method); n != 0 {
+		t.Fatalf("expect %s not called, got %d calls", method, n)
+	}
+}
+
+func (r *Recorder) Reset() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.calls = nil
+}
@@ -0,0 +1,328 @@
+package srpc
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"github.com/lesismal/arpc"
+	"github.com/sweemingdow/gmicro_pkg/pkg/config"
+	"github.com/sweemingdow/gmicro_pkg/pkg/mylog"
+	"net"
+	"os"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const defaultTlsReloadInterval = 10 * time.Second
+
+// TlsReloader 加载证书和CA, 定时检查文件变化并重新加载, 新连接使用新证书, 已建立的连接不受影响
+// 服务端和客户端共用, 作为LifeCycle收集后才会定时检查
+type TlsReloader struct {
+	cfg config.RpcTlsConfig
+
+	// 未配置证书时为nil(仅作为客户端且不需要mTLS)
+	cert atomic.Pointer[tls.Certificate]
+
+	// 未配置CA时为nil, 使用系统CA
+	roots atomic.Pointer[x509.CertPool]
+
+	modTimes map[string]time.Time
+
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+func NewTlsReloader(cfg config.RpcTlsConfig) (*TlsReloader, error) {
+	if (cfg.CertFile == "") != (cfg.KeyFile == "") {
+		return nil, errors.New("tls cert-file and key-file must be set together")
+	}
+
+	tr := &TlsReloader{
+		cfg:      cfg,
+		modTimes: make(map[string]time.Time, 3),
+		stop:     make(chan struct{}),
+	}
+
+	if err := tr.Reload(); err != nil {
+		return nil, err
+	}
+
+	tr.modTimes = tr.statFiles()
+
+	return tr, nil
+}
+
+// Reload 重新加载证书和CA, 失败时保留原有的
+func (tr *TlsReloader) Reload() error {
+	var (
+		cert  *tls.Certificate
+		roots *x509.CertPool
+	)
+
+	if tr.cfg.CertFile != "" {
+		c, err := tls.LoadX509KeyPair(tr.cfg.CertFile, tr.cfg.KeyFile)
+		if err != nil {
+			return fmt.Errorf("load tls key pair failed:%w", err)
+		}
+		cert = &c
+	}
+
+	if tr.cfg.CaFile != "" {
+		pem, err := os.ReadFile(tr.cfg.CaFile)
+		if err != nil {
+			return fmt.Errorf("read tls ca file failed:%w", err)
+		}
+
+		roots = x509.NewCertPool()
+		if !roots.AppendCertsFromPEM(pem) {
+			return fmt.Errorf("no certificate found in tls ca file:%s", tr.cfg.CaFile)
+		}
+	}
+
+	tr.cert.Store(cert)
+	tr.roots.Store(roots)
+
+	return nil
+}
+
+func (tr *TlsReloader) OnCreated(_ chan<- error) {
+	interval := time.Duration(tr.cfg.ReloadIntervalMills) * time.Millisecond
+	if interval <= 0 {
+		interval = defaultTlsReloadInterval
+	}
+
+	go tr.watch(interval)
+}
+
+func (tr *TlsReloader) OnDispose(_ context.Context) error {
+	tr.stopOnce.Do(func() {
+		close(tr.stop)
+	})
+
+	return nil
+}
+
+func (tr *TlsReloader) watch(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-tr.stop:
+			return
+		case <-ticker.C:
+		}
+
+		modTimes := tr.statFiles()
+		if mapsEqual(modTimes, tr.modTimes) {
+			continue
+		}
+
+		lg := mylog.AppLoggerWithRpc()
+		if err := tr.Reload(); err != nil {
+			// 证书和私钥可能未同时写入完成, 下次检查时重试
+			lg.Error().Stack().Err(err).Msg("reload tls certificates failed, keep the previous ones")
+			continue
+		}
+
+		tr.modTimes = modTimes
+
+		lg.Info().Msg("tls certificates reloaded")
+	}
+}
+
+func (tr *TlsReloader) statFiles() map[string]time.Time {
+	modTimes := make(map[string]time.Time, 3)
+	for _, f := range []string{tr.cfg.CertFile, tr.cfg.KeyFile, tr.cfg.CaFile} {
+		if f == "" {
+			continue
+		}
+
+		if fi, err := os.Stat(f); err == nil {
+			modTimes[f] = fi.ModTime()
+		}
+	}
+
+	return modTimes
+}
+
+func mapsEqual(a, b map[string]time.Time) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for k, v := range a {
+		if !b[k].Equal(v) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// ServerConfig 每次握手时取当前的证书和CA, ClientAuth开启时要求并校验客户端证书
+func (tr *TlsReloader) ServerConfig() *tls.Config {
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		GetConfigForClient: func(_ *tls.ClientHelloInfo) (*tls.Config, error) {
+			cert := tr.cert.Load()
+			if cert == nil {
+				return nil, errors.New("tls server certificate not configured")
+			}
+
+			c := &tls.Config{
+				MinVersion:   tls.VersionTLS12,
+				Certificates: []tls.Certificate{*cert},
+			}
+
+			if tr.cfg.ClientAuth {
+				c.ClientAuth = tls.RequireAndVerifyClientCert
+				c.ClientCAs = tr.roots.Load()
+			}
+
+			return c, nil
+		},
+	}
+}
+
+// ClientConfig serverName为空时使用配置中的ServerName
+// 使用VerifyConnection按当前CA校验服务端证书, 以支持CA轮换
+func (tr *TlsReloader) ClientConfig(serverName string) *tls.Config {
+	if tr.cfg.ServerName != "" {
+		serverName = tr.cfg.ServerName
+	}
+
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		ServerName: serverName,
+		// 由VerifyConnection校验
+		InsecureSkipVerify: true,
+		VerifyConnection: func(cs tls.ConnectionState) error {
+			return tr.verifyServer(cs, serverName)
+		},
+		GetClientCertificate: func(_ *tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			if cert := tr.cert.Load(); cert != nil {
+				return cert, nil
+			}
+
+			// 不发送证书, 由服务端决定是否拒绝
+			return &tls.Certificate{}, nil
+		},
+	}
+}
+
+func (tr *TlsReloader) verifyServer(cs tls.ConnectionState, serverName string) error {
+	if len(cs.PeerCertificates) == 0 {
+		return errors.New("tls server presented no certificate")
+	}
+
+	opts := x509.VerifyOptions{
+		Roots:         tr.roots.Load(),
+		DNSName:       serverName,
+		Intermediates: x509.NewCertPool(),
+	}
+
+	for _, c := range cs.PeerCertificates[1:] {
+		opts.Intermediates.AddCert(c)
+	}
+
+	_, err := cs.PeerCertificates[0].Verify(opts)
+	return err
+}
+
+// WithTls 以tls监听, 证书由tr提供
+func WithTls(tr *TlsReloader) ArpcServerOption {
+	return func(ars *ArpcServer) {
+		ars.tls = tr
+	}
+}
+
+// PeerIdentity tls对端证书中的身份信息, 用于鉴权
+type PeerIdentity struct {
+	CommonName string
+	DNSNames   []string
+	URIs       []string
+	Cert       *x509.Certificate
+}
+
+// Names CN和全部SAN
+func (pi PeerIdentity) Names() []string {
+	names := make([]string, 0, 1+len(pi.DNSNames)+len(pi.URIs))
+	if pi.CommonName != "" {
+		names = append(names, pi.CommonName)
+	}
+	names = append(names, pi.DNSNames...)
+	names = append(names, pi.URIs...)
+
+	return names
+}
+
+type peerCtxKey struct{}
+
+// PeerFrom 从ContextFrom还原的ctx中获取对端身份, 非mTLS连接时返回false
+func PeerFrom(ctx context.Context) (PeerIdentity, bool) {
+	pi, ok := ctx.Value(peerCtxKey{}).(PeerIdentity)
+	return pi, ok
+}
+
+// PeerOf 获取arpc连接的对端身份
+func PeerOf(c *arpc.Context) (PeerIdentity, bool) {
+	if c == nil || c.Client == nil {
+		return PeerIdentity{}, false
+	}
+
+	return peerOfConn(c.Client.Conn)
+}
+
+func peerOfConn(conn net.Conn) (PeerIdentity, bool) {
+	tc, ok := conn.(*tls.Conn)
+	if !ok {
+		return PeerIdentity{}, false
+	}
+
+	certs := tc.ConnectionState().PeerCertificates
+	if len(certs) == 0 {
+		return PeerIdentity{}, false
+	}
+
+	cert := certs[0]
+	pi := PeerIdentity{
+		CommonName: cert.Subject.CommonName,
+		DNSNames:   cert.DNSNames,
+		Cert:       cert,
+	}
+
+	for _, u := range cert.URIs {
+		pi.URIs = append(pi.URIs, u.String())
+	}
+
+	return pi, true
+}
+
+// AuthorizePeer 只允许证书CN或SAN在allowed中的对端调用, 需开启mTLS
+func AuthorizePeer(allowed ...string) Interceptor {
+	return func(c *arpc.Context, next arpc.HandlerFunc) {
+		pi, ok := PeerOf(c)
+		if ok && slices.ContainsFunc(pi.Names(), func(name string) bool {
+			return slices.Contains(allowed, name)
+		}) {
+			next(c)
+			return
+		}
+
+		lg := mylog.AppLoggerWithRpc()
+		lg.Warn().
+			Str("method", c.Message.Method()).
+			Str("remote", c.Client.Conn.RemoteAddr().String()).
+			Strs("peer", pi.Names()).
+			Msg("arpc peer not authorized")
+
+		if c.Message.Cmd() == arpc.CmdRequest {
+			_ = c.Error(errors.New("peer not authorized"))
+		}
+	}
+}
@@ -0,0 +1,162 @@
+package srpc
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"github.com/lesismal/arpc"
+	"github.com/sweemingdow/gmicro_pkg/pkg/config"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+}
+
+func newTestCA(t *testing.T, dir string) *testCA {
+	t.Helper()
+
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	tpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test-ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tpl, tpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+
+	writePem(t, filepath.Join(dir, "ca.pem"), "CERTIFICATE", der)
+
+	return &testCA{cert: cert, key: key}
+}
+
+// 签发证书并写入dir/name.pem, dir/name.key
+func (ca *testCA) issue(t *testing.T, dir, name, cn string) config.RpcTlsConfig {
+	t.Helper()
+
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	tpl := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: cn},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tpl, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keyDer, _ := x509.MarshalECPrivateKey(key)
+
+	cfg := config.RpcTlsConfig{
+		Enabled:    true,
+		CertFile:   filepath.Join(dir, name+".pem"),
+		KeyFile:    filepath.Join(dir, name+".key"),
+		CaFile:     filepath.Join(dir, "ca.pem"),
+		ClientAuth: true,
+	}
+
+	writePem(t, cfg.CertFile, "CERTIFICATE", der)
+	writePem(t, cfg.KeyFile, "EC PRIVATE KEY", keyDer)
+
+	return cfg
+}
+
+func writePem(t *testing.T, path, typ string, der []byte) {
+	t.Helper()
+
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: typ, Bytes: der}), 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestTlsPeerIdentityAndReload(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCA(t, dir)
+
+	srvTr, err := NewTlsReloader(ca.issue(t, dir, "server", "one_service"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cliTr, err := NewTlsReloader(ca.issue(t, dir, "client", "gateway"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	srv := arpc.NewServer()
+	srv.Handler.Handle("/whoami", Chain(func(c *arpc.Context) {
+		pi, _ := PeerOf(c)
+		_ = c.Write(pi.CommonName)
+	}, AuthorizePeer("gateway")))
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		_ = srv.Serve(tls.NewListener(ln, srvTr.ServerConfig()))
+	}()
+	defer srv.Stop()
+
+	call := func(tr *TlsReloader) (string, error) {
+		cli, err := arpc.NewClient(func() (net.Conn, error) {
+			return tls.Dial("tcp", ln.Addr().String(), tr.ClientConfig("127.0.0.1"))
+		})
+		if err != nil {
+			return "", err
+		}
+		defer cli.Stop()
+
+		var who string
+		err = cli.Call("/whoami", "", &who, time.Second)
+		return who, err
+	}
+
+	if who, err := call(cliTr); err != nil || who != "gateway" {
+		t.Fatalf("expect peer gateway, got:%q, err:%v", who, err)
+	}
+
+	// 证书轮换后新连接使用新证书
+	ca.issue(t, dir, "client", "intruder")
+	if err = cliTr.Reload(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = call(cliTr); err == nil || !strings.Contains(err.Error(), "not authorized") {
+		t.Fatalf("expect not authorized after reload, err:%v", err)
+	}
+
+	// 无客户端证书时握手失败
+	noCert, err := NewTlsReloader(config.RpcTlsConfig{CaFile: filepath.Join(dir, "ca.pem")})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = call(noCert); err == nil {
+		t.Fatal("expect handshake failed without client certificate")
+	}
+}
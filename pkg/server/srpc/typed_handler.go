@@ -0,0 +1,190 @@
+package srpc
+
+import (
+	"context"
+	"fmt"
+	"github.com/lesismal/arpc"
+	"github.com/sweemingdow/gmicro_pkg/pkg/myerr"
+	"github.com/sweemingdow/gmicro_pkg/pkg/mylog"
+	"github.com/sweemingdow/gmicro_pkg/pkg/server/srpc/rpccall"
+	"runtime/debug"
+)
+
+// TypedHandler 类型化的handler, ctx由调用方传递的元数据还原(见ContextFrom)
+type TypedHandler[Req, Resp any] func(ctx context.Context, req rpccall.RpcReqWrapper[Req]) (rpccall.RpcRespWrapper[Resp], error)
+
+// Validator 请求体实现该接口时, 绑定后自动校验, 校验失败响应ParamValidateErr
+type Validator interface {
+	Validate() error
+}
+
+// Typed 将TypedHandler转换为arpc handler, 负责绑定, 校验, 错误转换, panic恢复和写回响应
+//
+//	绑定失败: ServerUnpredictableErr
+//	校验失败或返回myerr.RpcParamValidateError: ParamValidateErr
+//	返回myerr.RpcRespError: 使用其code, desc, msg
+//	返回其他错误或panic: ServerUnpredictableErr
+func Typed[Req, Resp any](h TypedHandler[Req, Resp]) arpc.HandlerFunc {
+	return serveUnary(
+		h,
+		true,
+		func(req *rpccall.RpcReqWrapper[Req]) error {
+			return validateReq(&req.Req)
+		},
+		func(code, desc, msg string, resp rpccall.RpcRespWrapper[Resp]) any {
+			errResp := rpccall.ErrAll(code, desc, msg, resp.Resp)
+			return &errResp
+		},
+	)
+}
+
+// Unary 与Typed相同, 但Req和Resp可为任意类型, 出错时响应rpccall.RpcRespSimple
+func Unary[Req, Resp any](h func(ctx context.Context, req Req) (Resp, error)) arpc.HandlerFunc {
+	return serveUnary(
+		h,
+		true,
+		func(req *Req) error {
+			return validateReq(req)
+		},
+		simpleErrResp[Resp],
+	)
+}
+
+// UnaryNoReq 无请求参数的Unary
+func UnaryNoReq[Resp any](h func(ctx context.Context) (Resp, error)) arpc.HandlerFunc {
+	return serveUnary(
+		func(ctx context.Context, _ struct{}) (Resp, error) {
+			return h(ctx)
+		},
+		false,
+		nil,
+		simpleErrResp[Resp],
+	)
+}
+
+// OneWay 通知类handler, 只处理不响应, 以请求方式调用时响应空对象
+func OneWay[Req any](h func(ctx context.Context, req Req) error) arpc.HandlerFunc {
+	return serveUnary(
+		func(ctx context.Context, req Req) (struct{}, error) {
+			return struct{}{}, h(ctx, req)
+		},
+		true,
+		func(req *Req) error {
+			return validateReq(req)
+		},
+		simpleErrResp[struct{}],
+	)
+}
+
+// Register 以TypedHandler注册路由
+func Register[Req, Resp any](srv *arpc.Server, method string, h TypedHandler[Req, Resp], interceptors ...Interceptor) {
+	srv.Handler.Handle(method, Chain(Typed(h), interceptors...))
+}
+
+func serveUnary[Req, Resp any](
+	h func(ctx context.Context, req Req) (Resp, error),
+	bind bool,
+	validate func(req *Req) error,
+	errResp func(code, desc, msg string, resp Resp) any,
+) arpc.HandlerFunc {
+	return func(c *arpc.Context) {
+		var (
+			req  Req
+			resp Resp
+		)
+
+		defer func() {
+			if r := recover(); r != nil {
+				lg := rpccall.LoggerWrapWithReqId(reqIdOf(&req), mylog.AppLoggerWithRpc())
+				lg.Error().
+					Str("method", c.Message.Method()).
+					Msgf("typed handler panic:%v\n%s", r, debug.Stack())
+
+				writeTyped(c, errResp(rpccall.ServerUnpredictableErr, fmt.Sprintf("panic:%v", r), "", resp))
+			}
+		}()
+
+		if bind {
+			if err := c.Bind(&req); err != nil {
+				err = myerr.NewRpcBindError(err)
+
+				lg := mylog.AppLoggerWithBind()
+				lg.Error().Stack().Err(err).Str("method", c.Message.Method()).Msg("bind data failed")
+
+				writeTyped(c, errResp(rpccall.ServerUnpredictableErr, err.Error(), "", resp))
+				return
+			}
+		}
+
+		if validate != nil {
+			if err := validate(&req); err != nil {
+				desc, msg := err.Error(), ""
+				if pve, ok := myerr.DecodeRpcParamValidateError(err); ok {
+					desc, msg = pve.ErrDesc, pve.Msg
+				}
+
+				writeTyped(c, errResp(rpccall.ParamValidateErr, desc, msg, resp))
+				return
+			}
+		}
+
+		ctx, cancel := ContextFrom(c)
+		defer cancel()
+
+		resp, err := h(ctx, req)
+		if err != nil {
+			code, desc, msg := errCodeOf(err)
+
+			if code == rpccall.ServerUnpredictableErr {
+				lg := rpccall.LoggerWrapWithReqId(reqIdOf(&req), mylog.AppLoggerWithRpc())
+				lg.Error().Stack().Err(err).Str("method", c.Message.Method()).Msg("typed handler failed")
+			}
+
+			writeTyped(c, errResp(code, desc, msg, resp))
+			return
+		}
+
+		writeTyped(c, &resp)
+	}
+}
+
+func validateReq(req any) error {
+	if v, ok := req.(Validator); ok {
+		return v.Validate()
+	}
+
+	return nil
+}
+
+func reqIdOf(req any) string {
+	if carrier, ok := req.(rpccall.ReqIdCarrier); ok {
+		return carrier.GetReqId()
+	}
+
+	return ""
+}
+
+func errCodeOf(err error) (code, desc, msg string) {
+	if pve, ok := myerr.DecodeRpcParamValidateError(err); ok {
+		return rpccall.ParamValidateErr, pve.ErrDesc, pve.Msg
+	}
+
+	if rre, ok := myerr.DecodeRpcRespError(err); ok {
+		return rre.Code(), rre.ErrDesc, rre.Msg
+	}
+
+	return rpccall.ServerUnpredictableErr, err.Error(), ""
+}
+
+func simpleErrResp[Resp any](code, desc, msg string, _ Resp) any {
+	return rpccall.SimpleErrAll(code, desc, msg)
+}
+
+// 通知类消息无需响应
+func writeTyped(c *arpc.Context, resp any) {
+	if c.Message.Cmd() != arpc.CmdRequest {
+		return
+	}
+
+	WriteLoggedIfError(c, resp)
+}
@@ -0,0 +1,162 @@
+package srpc
+
+import (
+	"context"
+	"errors"
+	"github.com/lesismal/arpc"
+	"github.com/sweemingdow/gmicro_pkg/pkg/myerr"
+	"github.com/sweemingdow/gmicro_pkg/pkg/server/srpc/rpccall"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+	"net"
+	"testing"
+	"time"
+)
+
+type typedReq struct {
+	Name string `json:"name"`
+}
+
+func (tr typedReq) Validate() error {
+	if tr.Name == "" {
+		return myerr.NewRpcParamValidateError("name required", "")
+	}
+
+	return nil
+}
+
+type typedResp struct {
+	Greeting string `json:"greeting"`
+}
+
+func TestTypedHandler(t *testing.T) {
+	srv := arpc.NewServer()
+	srv.Codec = JsonIterCodec{}
+
+	Register(srv, "/greet", func(ctx context.Context, req rpccall.RpcReqWrapper[typedReq]) (rpccall.RpcRespWrapper[typedResp], error) {
+		switch req.Req.Name {
+		case "biz":
+			return rpccall.RpcRespWrapper[typedResp]{}, myerr.NewRpcRespError("2001", "biz failed", "")
+		case "err":
+			return rpccall.RpcRespWrapper[typedResp]{}, errors.New("db down")
+		case "panic":
+			panic("boom")
+		}
+
+		return rpccall.Ok(typedResp{Greeting: "hi " + req.Req.Name}), nil
+	})
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	go func() {
+		_ = srv.Serve(ln)
+	}()
+	defer srv.Stop()
+
+	cli, err := arpc.NewClient(func() (net.Conn, error) {
+		return net.DialTimeout("tcp", ln.Addr().String(), time.Second)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cli.Stop()
+	cli.Codec = JsonIterCodec{}
+
+	cases := []struct {
+		name string
+		code string
+	}{
+		{"tom", rpccall.CallOk},
+		{"", rpccall.ParamValidateErr},
+		{"biz", "2001"},
+		{"err", rpccall.ServerUnpredictableErr},
+		{"panic", rpccall.ServerUnpredictableErr},
+	}
+
+	for _, cs := range cases {
+		var resp rpccall.RpcRespWrapper[typedResp]
+		req := rpccall.CreateReq(typedReq{Name: cs.name})
+		if err = cli.Call("/greet", &req, &resp, time.Second); err != nil {
+			t.Fatal(err)
+		}
+
+		if resp.Code != cs.code {
+			t.Fatalf("name:%q expect code:%s, got:%+v", cs.name, cs.code, resp)
+		}
+	}
+
+	var resp rpccall.RpcRespWrapper[typedResp]
+	if err = cli.Call("/greet", "not an object", &resp, time.Second); err != nil || resp.Code != rpccall.ServerUnpredictableErr {
+		t.Fatalf("expect bind failed, resp:%+v, err:%v", resp, err)
+	}
+}
+
+// 协商为protobuf时, 包装类型中的业务数据以protobuf编码, 而不是退回json
+func TestTypedHandlerProtobuf(t *testing.T) {
+	srv := arpc.NewServer()
+	srv.Codec = ArpcCodec(ProtobufCodec{})
+
+	Register(srv, "/echo", func(ctx context.Context, req rpccall.RpcReqWrapper[*wrapperspb.StringValue]) (rpccall.RpcRespWrapper[*wrapperspb.StringValue], error) {
+		if req.Req.GetValue() == "biz" {
+			return rpccall.RpcRespWrapper[*wrapperspb.StringValue]{}, myerr.NewRpcRespError("2001", "biz failed", "")
+		}
+
+		return rpccall.Ok(wrapperspb.String("echo " + req.Req.GetValue() + " " + req.ReqId)), nil
+	})
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	go func() {
+		_ = srv.Serve(ln)
+	}()
+	defer srv.Stop()
+
+	cli, err := arpc.NewClient(func() (net.Conn, error) {
+		return net.DialTimeout("tcp", ln.Addr().String(), time.Second)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cli.Stop()
+
+	call := func(val string) (rpccall.RpcRespWrapper[*wrapperspb.StringValue], []byte) {
+		t.Helper()
+
+		data, err := ProtobufCodec{}.Marshal(&rpccall.RpcReqWrapper[*wrapperspb.StringValue]{ReqId: "r1", Req: wrapperspb.String(val)})
+		if err != nil || data[0] != magicProtobuf {
+			t.Fatalf("expect protobuf request, data:%v, err:%v", data, err)
+		}
+
+		var raw []byte
+		if err = cli.Call("/echo", data, &raw, time.Second); err != nil {
+			t.Fatal(err)
+		}
+
+		var resp rpccall.RpcRespWrapper[*wrapperspb.StringValue]
+		if err = Decode(raw, &resp); err != nil {
+			t.Fatal(err)
+		}
+
+		return resp, raw
+	}
+
+	resp, raw := call("hi")
+	if raw[0] != magicProtobuf {
+		t.Fatalf("expect protobuf response, got first byte:0x%02x", raw[0])
+	}
+
+	if !resp.IsOk() || resp.Resp.GetValue() != "echo hi r1" {
+		t.Fatalf("unexpected resp:%+v", resp)
+	}
+
+	// 业务数据为nil时解码后仍为nil
+	resp, _ = call("biz")
+	if resp.Code != "2001" || resp.ErrDesc != "biz failed" || resp.Resp != nil {
+		t.Fatalf("unexpected error resp:%+v", resp)
+	}
+}
@@ -6,12 +6,16 @@ import (
 	"flag"
 	"fmt"
 	"go/ast"
+	"go/format"
 	"go/parser"
 	"go/printer"
 	"go/token"
+	"io"
 	"log"
 	"os"
 	"path/filepath"
+	"slices"
+	"sort"
 	"strings"
 	"text/template"
 	"time"
@@ -27,6 +31,36 @@ type Method struct {
 	Path        string
 	Timeout     time.Duration
 	HasParam    bool
+	HasCtx      bool // 首个参数为context.Context
+	Kind        string
+	Retry       *Retry
+	Pos         token.Pos
+}
+
+// 方法的调用方式, 默认同步调用, @async生成CallAsync, @notify生成Notify
+const (
+	KindCall   = "call"
+	KindAsync  = "async"
+	KindNotify = "notify"
+)
+
+func (m Method) IsCall() bool {
+	return m.Kind == KindCall
+}
+
+func (m Method) IsAsync() bool {
+	return m.Kind == KindAsync
+}
+
+func (m Method) IsNotify() bool {
+	return m.Kind == KindNotify
+}
+
+// @retry <max_attempts> [initial_backoff] [max_backoff], e.g. @retry 3 50ms 1s
+type Retry struct {
+	MaxAttempts    int
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
 }
 
 type Interface struct {
@@ -36,28 +70,82 @@ type Interface struct {
 	PkgName    string
 	FileName   string
 	PkgPrefix  string
+	Tagged     bool // 带有@rpc_server注释
+	Pos        token.Pos
+}
+
+// 解析时收集全部问题, 统一以file:line:col的形式报告
+type diagnostics struct {
+	fset   *token.FileSet
+	strict bool
+	errs   []string
+	warns  []string
+	w      io.Writer
+}
+
+func (d *diagnostics) errorf(pos token.Pos, format string, args ...any) {
+	d.errs = append(d.errs, fmt.Sprintf("%s: %s", d.fset.Position(pos), fmt.Sprintf(format, args...)))
+}
+
+// strict模式下警告视为错误
+func (d *diagnostics) warnf(pos token.Pos, format string, args ...any) {
+	if d.strict {
+		d.errorf(pos, format, args...)
+		return
+	}
+	d.warns = append(d.warns, fmt.Sprintf("%s: warning: %s", d.fset.Position(pos), fmt.Sprintf(format, args...)))
+}
+
+// 输出全部问题, 存在错误时返回false
+func (d *diagnostics) report() bool {
+	for _, w := range d.warns {
+		fmt.Fprintln(d.w, w)
+	}
+	for _, e := range d.errs {
+		fmt.Fprintln(d.w, e)
+	}
+	return len(d.errs) == 0
 }
 
 func main() {
+	os.Exit(run(".", os.Args[1:], os.Stdout, os.Stderr))
+}
+
+// 对dir下的包生成代码, 返回进程退出码
+func run(dir string, args []string, stdout, stderr io.Writer) int {
 	var (
-		typeName  string
+		typeNames string
 		pkgPrefix string
+		withSrv   bool
+		withMock  bool
+		check     bool
+		strict    bool
+		manifest  bool
 	)
-	flag.StringVar(&typeName, "type", "", "interface name to generate")
-	flag.StringVar(&pkgPrefix, "pkg_prefix", "github.com/sweemingdow/gmicro_pkg", "-pkg_prefix")
-	flag.Parse()
-
-	if typeName == "" {
-		log.Fatal("-type flag is required")
+	fs := flag.NewFlagSet("rpcgen", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.StringVar(&typeNames, "type", "", "comma-separated interface names to generate, empty to generate every interface tagged @rpc_server")
+	fs.StringVar(&pkgPrefix, "pkg_prefix", "github.com/sweemingdow/gmicro_pkg", "-pkg_prefix")
+	fs.BoolVar(&withSrv, "server", true, "also generate server interface and arpc route binder")
+	fs.BoolVar(&withMock, "mock", true, "also generate a programmable mock of the provider interface")
+	fs.BoolVar(&check, "check", false, "do not write files, exit non-zero if generated files are stale")
+	fs.BoolVar(&strict, "strict", false, "treat warnings (e.g. missing @path) as errors")
+	fs.BoolVar(&manifest, "manifest", false, "also emit <server>_rpc_manifest.json describing paths and payload schemas")
+	if err := fs.Parse(args); err != nil {
+		return 2
 	}
 
 	fset := token.NewFileSet()
-	pkgs, err := parser.ParseDir(fset, ".", nil, parser.ParseComments)
+	pkgs, err := parser.ParseDir(fset, dir, func(fi os.FileInfo) bool {
+		return !strings.HasSuffix(fi.Name(), "_test.go")
+	}, parser.ParseComments)
 	if err != nil {
-		log.Fatalf("parse dir: %v", err)
+		fmt.Fprintf(stderr, "parse dir: %v\n", err)
+		return 1
 	}
 	if len(pkgs) != 1 {
-		log.Fatal("expected exactly one package in directory")
+		fmt.Fprintln(stderr, "expected exactly one package in directory")
+		return 1
 	}
 	var pkg *ast.Package
 	for _, p := range pkgs {
@@ -65,56 +153,203 @@ func main() {
 		break
 	}
 
-	var iface *Interface
-	for fileName, file := range pkg.Files {
-		if i := parseInterface(file, typeName, fset); i != nil {
-			iface = i
-			iface.PkgName = pkg.Name
-			iface.FileName = filepath.Base(fileName)
-			iface.PkgPrefix = pkgPrefix
-			break
+	var wanted []string
+	for _, name := range strings.Split(typeNames, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			wanted = append(wanted, name)
+		}
+	}
+
+	diag := &diagnostics{fset: fset, strict: strict, w: stderr}
+
+	ifaces := scanInterfaces(pkg, fset, wanted, diag)
+	for _, iface := range ifaces {
+		iface.PkgName = pkg.Name
+		iface.PkgPrefix = pkgPrefix
+	}
+
+	checkDuplicatePaths(ifaces, diag)
+
+	var targets []*Interface
+	if len(wanted) == 0 {
+		for _, iface := range ifaces {
+			if iface.Tagged {
+				targets = append(targets, iface)
+			}
+		}
+		if len(targets) == 0 {
+			fmt.Fprintln(stderr, "no interface tagged @rpc_server found")
+			return 1
+		}
+	} else {
+		for _, name := range wanted {
+			found := false
+			for _, iface := range ifaces {
+				if iface.Name == name {
+					targets = append(targets, iface)
+					found = true
+					break
+				}
+			}
+			if !found {
+				diag.errs = append(diag.errs, fmt.Sprintf("interface %s not found", name))
+			}
 		}
 	}
-	if iface == nil {
-		log.Fatalf("interface %s not found", typeName)
+
+	if !diag.report() {
+		return 1
 	}
 
-	generate(iface)
+	var outputs []output
+	for _, iface := range targets {
+		outputs = append(outputs, render(iface))
+		if withSrv {
+			outputs = append(outputs, renderServer(iface))
+		}
+		if withMock {
+			outputs = append(outputs, renderMock(iface))
+		}
+	}
+
+	if manifest {
+		// 解析阶段的警告已输出
+		diag.warns = diag.warns[:0]
+
+		checked, typeErrs := typeCheck(fset, pkg)
+		for _, e := range typeErrs {
+			diag.warns = append(diag.warns, "warning: "+e)
+		}
+
+		var servers []string
+		for _, iface := range targets {
+			if !slices.Contains(servers, iface.ServerName) {
+				servers = append(servers, iface.ServerName)
+			}
+		}
+
+		outputs = append(outputs, renderManifests(checked, ifaces, servers, diag)...)
+
+		if !diag.report() {
+			return 1
+		}
+	}
+
+	if check {
+		if stale := staleOutputs(dir, outputs); len(stale) > 0 {
+			for _, name := range stale {
+				fmt.Fprintf(stderr, "%s is stale, run go generate\n", name)
+			}
+			return 1
+		}
+		return 0
+	}
+
+	for _, out := range outputs {
+		if err := os.WriteFile(filepath.Join(dir, out.FileName), out.Src, 0644); err != nil {
+			fmt.Fprintf(stderr, "write %s: %v\n", out.FileName, err)
+			return 1
+		}
+		fmt.Fprintf(stdout, "Generated %s\n", out.FileName)
+	}
+
+	return 0
 }
 
-func parseInterface(file *ast.File, typeName string, fset *token.FileSet) *Interface {
-	for _, decl := range file.Decls {
-		if genDecl, ok := decl.(*ast.GenDecl); ok && genDecl.Tok == token.TYPE {
+// 扫描包内带有@rpc_server的接口以及wanted中指定的接口, 按文件名和声明顺序返回
+func scanInterfaces(pkg *ast.Package, fset *token.FileSet, wanted []string, diag *diagnostics) []*Interface {
+	fileNames := make([]string, 0, len(pkg.Files))
+	for fileName := range pkg.Files {
+		fileNames = append(fileNames, fileName)
+	}
+	sort.Strings(fileNames)
+
+	isWanted := func(name string) bool {
+		for _, w := range wanted {
+			if w == name {
+				return true
+			}
+		}
+		return false
+	}
+
+	var ifaces []*Interface
+	for _, fileName := range fileNames {
+		file := pkg.Files[fileName]
+		for _, decl := range file.Decls {
+			genDecl, ok := decl.(*ast.GenDecl)
+			if !ok || genDecl.Tok != token.TYPE {
+				continue
+			}
+
 			for _, spec := range genDecl.Specs {
-				if typeSpec, ok := spec.(*ast.TypeSpec); ok {
-					if typeSpec.Name.Name == typeName {
-						if ifaceType, ok := typeSpec.Type.(*ast.InterfaceType); ok {
-							return parseInterfaceType(file, ifaceType, typeName, fset, genDecl.Doc)
-						}
-					}
+				typeSpec, ok := spec.(*ast.TypeSpec)
+				if !ok {
+					continue
 				}
+				ifaceType, ok := typeSpec.Type.(*ast.InterfaceType)
+				if !ok {
+					continue
+				}
+
+				doc := typeSpec.Doc
+				if doc == nil && len(genDecl.Specs) == 1 {
+					doc = genDecl.Doc
+				}
+
+				tagged := doc != nil && strings.Contains(doc.Text(), "@rpc_server")
+				if !tagged && !isWanted(typeSpec.Name.Name) {
+					continue
+				}
+
+				iface := parseInterfaceType(ifaceType, typeSpec, fset, doc, diag)
+				iface.FileName = filepath.Base(fileName)
+				iface.Tagged = tagged
+				ifaces = append(ifaces, iface)
 			}
 		}
 	}
-	return nil
+
+	return ifaces
 }
 
-func parseInterfaceType(file *ast.File, iface *ast.InterfaceType, typeName string, fset *token.FileSet, doc *ast.CommentGroup) *Interface {
+// 同一服务下的@path不能重复, 包括跨接口的情况
+func checkDuplicatePaths(ifaces []*Interface, diag *diagnostics) {
+	type declared struct {
+		iface  string
+		method string
+		pos    token.Pos
+	}
+
+	seen := make(map[string]declared)
+	for _, iface := range ifaces {
+		for _, m := range iface.Methods {
+			key := iface.ServerName + " " + m.Path
+			if prev, ok := seen[key]; ok {
+				diag.errorf(m.Pos, "duplicate @path %s for server %s, already declared by %s.%s at %s",
+					m.Path, iface.ServerName, prev.iface, prev.method, diag.fset.Position(prev.pos))
+				continue
+			}
+			seen[key] = declared{iface: iface.Name, method: m.Name, pos: m.Pos}
+		}
+	}
+}
+
+func parseInterfaceType(iface *ast.InterfaceType, typeSpec *ast.TypeSpec, fset *token.FileSet, doc *ast.CommentGroup, diag *diagnostics) *Interface {
+	typeName := typeSpec.Name.Name
 	i := &Interface{
 		Name: typeName,
+		Pos:  typeSpec.Pos(),
 	}
 
 	if doc != nil {
 		for _, c := range doc.List {
-			text := c.Text
-			if strings.Contains(text, "@rpc_server") {
-				parts := strings.Fields(text)
-				for j, part := range parts {
-					if part == "@rpc_server" && j+1 < len(parts) {
-						i.ServerName = strings.Trim(parts[j+1], "\"'")
-						break
-					}
+			if args, ok := annotationArgs(c.Text, "@rpc_server"); ok {
+				if len(args) == 0 {
+					diag.errorf(c.Pos(), "@rpc_server requires a service name")
+					continue
 				}
+				i.ServerName = strings.Trim(args[0], "\"'")
 			}
 		}
 	}
@@ -128,6 +363,7 @@ func parseInterfaceType(file *ast.File, iface *ast.InterfaceType, typeName strin
 
 	for _, method := range iface.Methods.List {
 		if len(method.Names) == 0 {
+			diag.errorf(method.Pos(), "%s: embedded interfaces are not supported", typeName)
 			continue
 		}
 		methodName := method.Names[0].Name
@@ -136,85 +372,226 @@ func parseInterfaceType(file *ast.File, iface *ast.InterfaceType, typeName strin
 			continue
 		}
 
-		paramCount := len(funcType.Params.List)
-		if paramCount > 1 {
-			log.Fatalf("method %s must have 0 or 1 parameter", methodName)
+		// 有错误时不会生成代码, 仍保留方法用于重复路径检查
+		m, _ := parseMethod(typeName, methodName, method, funcType, fset, diag)
+		i.Methods = append(i.Methods, m)
+	}
+
+	return i
+}
+
+// 解析单个方法, 出错时记录到diag并返回false, 此时只有Name, Path, Pos有效
+func parseMethod(typeName, methodName string, method *ast.Field, funcType *ast.FuncType, fset *token.FileSet, diag *diagnostics) (Method, bool) {
+	pos := method.Pos()
+	errCount := len(diag.errs)
+
+	var (
+		comments []*ast.Comment
+		path     string
+		pathPos  token.Pos
+	)
+	if method.Doc != nil {
+		comments = method.Doc.List
+	}
+
+	kind := KindCall
+	methodTimeout := 1 * time.Second
+	var retry *Retry
+
+	for _, c := range comments {
+		switch {
+		case hasAnnotation(c.Text, "@async"):
+			if kind != KindCall {
+				diag.errorf(c.Pos(), "%s.%s: @async and @notify are mutually exclusive", typeName, methodName)
+			}
+			kind = KindAsync
+		case hasAnnotation(c.Text, "@notify"):
+			if kind != KindCall {
+				diag.errorf(c.Pos(), "%s.%s: @async and @notify are mutually exclusive", typeName, methodName)
+			}
+			kind = KindNotify
+		}
+
+		if args, ok := annotationArgs(c.Text, "@path"); ok {
+			if len(args) == 0 || !strings.HasPrefix(args[0], "/") {
+				diag.errorf(c.Pos(), "%s.%s: @path must be followed by a path starting with /", typeName, methodName)
+				continue
+			}
+			path, pathPos = args[0], c.Pos()
 		}
-		if len(funcType.Results.List) != 2 {
-			log.Fatalf("method %s must return (T, error)", methodName)
+
+		if args, ok := annotationArgs(c.Text, "@timeout"); ok {
+			if len(args) == 0 {
+				diag.errorf(c.Pos(), "%s.%s: @timeout requires a duration", typeName, methodName)
+				continue
+			}
+			d, err := time.ParseDuration(args[0])
+			if err != nil || d <= 0 {
+				diag.errorf(c.Pos(), "%s.%s: invalid @timeout %q", typeName, methodName, args[0])
+				continue
+			}
+			methodTimeout = d
 		}
 
-		var originalReqStr, coreReq string
-		var isWrapper bool
-		hasParam := paramCount == 1
+		if args, ok := annotationArgs(c.Text, "@retry"); ok {
+			retry = parseRetry(c.Pos(), typeName+"."+methodName, args, diag)
+		}
+	}
+
+	if path == "" {
+		path = "/" + toSnakeCase(methodName)
+		diag.warnf(pos, "%s.%s: missing @path, defaulting to %s", typeName, methodName, path)
+	} else {
+		pos = pathPos
+	}
+
+	if retry != nil && kind != KindCall {
+		diag.errorf(pos, "%s.%s: @retry is not supported on @async or @notify methods", typeName, methodName)
+	}
 
-		if hasParam {
-			originalReqStr = astString(fset, funcType.Params.List[0].Type)
-			originalReqStr, coreReq, isWrapper = parseReqType(originalReqStr)
+	params := flattenFields(fset, funcType.Params)
+	results := flattenFields(fset, funcType.Results)
+
+	// 可选的首个context.Context参数
+	hasCtx := len(params) > 0 && params[0] == "context.Context"
+	if hasCtx {
+		params = params[1:]
+	}
+
+	var respTypeStr string
+	switch kind {
+	case KindCall:
+		if len(results) != 2 || results[1] != "error" {
+			diag.errorf(method.Pos(), "%s.%s must return (T, error)", typeName, methodName)
 		} else {
-			originalReqStr = ""
-			coreReq = ""
-			isWrapper = false
-		}
-
-		respTypeStr := astString(fset, funcType.Results.List[0].Type)
-
-		path := "/unknown"
-		if method.Doc != nil {
-			for _, c := range method.Doc.List {
-				text := c.Text
-				if strings.Contains(text, "@path") {
-					parts := strings.Fields(text)
-					for j, part := range parts {
-						if part == "@path" && j+1 < len(parts) {
-							path = parts[j+1]
-							break
-						}
-					}
-					break
-				}
-			}
+			respTypeStr = results[0]
 		}
-		if path == "/unknown" {
-			path = "/" + toSnakeCase(methodName)
-		}
-
-		methodTimeout := 1 * time.Second
-		if method.Doc != nil {
-			for _, c := range method.Doc.List {
-				text := c.Text
-				if strings.Contains(text, "@timeout") {
-					parts := strings.Fields(text)
-					for j, part := range parts {
-						if part == "@timeout" && j+1 < len(parts) {
-							if d, err := time.ParseDuration(parts[j+1]); err == nil {
-								methodTimeout = d
-							}
-							break
-						}
-					}
-					break
-				}
-			}
+	case KindAsync:
+		if len(results) != 1 || results[0] != "error" {
+			diag.errorf(method.Pos(), "%s.%s: @async method must return error", typeName, methodName)
+		}
+		if len(params) == 0 {
+			diag.errorf(method.Pos(), "%s.%s: @async method requires a callback func(T, error) as the last parameter", typeName, methodName)
+		} else if cbResp, ok := parseCallback(params[len(params)-1]); ok {
+			respTypeStr = cbResp
+			params = params[:len(params)-1]
+		} else {
+			diag.errorf(method.Pos(), "%s.%s: @async callback must be func(T, error), got %s", typeName, methodName, params[len(params)-1])
+			return Method{Name: methodName, Path: path, Pos: pos}, false
 		}
+	case KindNotify:
+		if len(results) != 1 || results[0] != "error" {
+			diag.errorf(method.Pos(), "%s.%s: @notify method must return error", typeName, methodName)
+		}
+	}
 
-		i.Methods = append(i.Methods, Method{
-			Name:        methodName,
-			OriginalReq: originalReqStr,
-			ReqType:     coreReq,
-			IsWrapper:   isWrapper,
-			RespType:    respTypeStr,
-			Path:        path,
-			Timeout:     methodTimeout,
-			HasParam:    hasParam,
-		})
+	if len(params) > 1 {
+		diag.errorf(method.Pos(), "%s.%s must have 0 or 1 request parameter", typeName, methodName)
+	} else if kind != KindCall && len(params) == 0 {
+		diag.errorf(method.Pos(), "%s.%s: @async and @notify methods require a request parameter", typeName, methodName)
 	}
 
-	return i
+	if len(diag.errs) > errCount {
+		return Method{Name: methodName, Path: path, Pos: pos}, false
+	}
+
+	var originalReqStr, coreReq string
+	var isWrapper bool
+	hasParam := len(params) == 1
+
+	if hasParam {
+		originalReqStr, coreReq, isWrapper = parseReqType(params[0])
+	}
+
+	return Method{
+		Name:        methodName,
+		OriginalReq: originalReqStr,
+		ReqType:     coreReq,
+		IsWrapper:   isWrapper,
+		RespType:    respTypeStr,
+		Path:        path,
+		Timeout:     methodTimeout,
+		HasParam:    hasParam,
+		HasCtx:      hasCtx,
+		Kind:        kind,
+		Retry:       retry,
+		Pos:         pos,
+	}, true
 }
 
 // --- Helpers ---
 
+func parseRetry(pos token.Pos, methodName string, args []string, diag *diagnostics) *Retry {
+	if len(args) == 0 {
+		diag.errorf(pos, "%s: @retry requires max attempts", methodName)
+		return nil
+	}
+
+	retry := &Retry{}
+	if _, err := fmt.Sscanf(args[0], "%d", &retry.MaxAttempts); err != nil || retry.MaxAttempts < 1 {
+		diag.errorf(pos, "%s: invalid @retry max attempts %q", methodName, args[0])
+		return nil
+	}
+
+	durations := []*time.Duration{&retry.InitialBackoff, &retry.MaxBackoff}
+	for k := 1; k < len(args) && k <= len(durations); k++ {
+		d, err := time.ParseDuration(args[k])
+		if err != nil {
+			diag.errorf(pos, "%s: invalid @retry backoff %q", methodName, args[k])
+			return nil
+		}
+		*durations[k-1] = d
+	}
+
+	return retry
+}
+
+func hasAnnotation(text, annotation string) bool {
+	_, ok := annotationArgs(text, annotation)
+	return ok
+}
+
+// 返回注释中annotation之后的参数
+func annotationArgs(text, annotation string) ([]string, bool) {
+	parts := strings.Fields(strings.TrimPrefix(text, "//"))
+	for j, part := range parts {
+		if part == annotation {
+			return parts[j+1:], true
+		}
+	}
+	return nil, false
+}
+
+// 按参数个数展开, 如(a, b T)展开为两个T
+func flattenFields(fset *token.FileSet, fl *ast.FieldList) []string {
+	if fl == nil {
+		return nil
+	}
+
+	var types []string
+	for _, f := range fl.List {
+		typ := astString(fset, f.Type)
+		n := len(f.Names)
+		if n == 0 {
+			n = 1
+		}
+		for k := 0; k < n; k++ {
+			types = append(types, typ)
+		}
+	}
+	return types
+}
+
+// func(T, error) -> T
+func parseCallback(cb string) (string, bool) {
+	s := strings.TrimSpace(cb)
+	if !strings.HasPrefix(s, "func(") || !strings.HasSuffix(s, ", error)") {
+		return "", false
+	}
+
+	return strings.TrimSpace(s[len("func(") : len(s)-len(", error)")]), true
+}
+
 func astString(fset *token.FileSet, expr ast.Expr) string {
 	var buf bytes.Buffer
 	if err := printer.Fprint(&buf, fset, expr); err != nil {
@@ -273,10 +650,18 @@ const tmpl = `// Code generated by rpcgen. DO NOT EDIT.
 package {{.PkgName}}
 
 import (
+{{- if .UsesCtx}}
+	"context"
+{{- end}}
+{{- if .UsesAsync}}
+	"github.com/lesismal/arpc"
+{{- end}}
 	"{{.PkgPrefix}}/pkg/server/srpc/rclient"
 	"{{.PkgPrefix}}/pkg/server/srpc/rclient/rcfactory"
 	"{{.PkgPrefix}}/pkg/server/srpc/rpccall"
+{{- if .UsesTime}}
 	"time"
+{{- end}}
 )
 
 type {{.ImplName}} struct {
@@ -289,8 +674,8 @@ func New{{.Name}}(clientFactory rcfactory.ArpcClientFactory) {{.Name}} {
 	}
 }
 
-{{range .Methods}}
-func (p *{{$.ImplName}}) {{.Name}}({{if .HasParam}}req {{.OriginalReq}}{{end}}) ({{.RespType}}, error) {
+{{range .Methods}}{{if .IsCall}}
+func (p *{{$.ImplName}}) {{.Name}}({{if .HasCtx}}ctx context.Context{{if .HasParam}}, {{end}}{{end}}{{if .HasParam}}req {{.OriginalReq}}{{end}}) ({{.RespType}}, error) {
 	cp := p.acquireClientProxy()
 	var resp {{.RespType}}
 	var reqForCall interface{}
@@ -299,18 +684,141 @@ func (p *{{$.ImplName}}) {{.Name}}({{if .HasParam}}req {{.OriginalReq}}{{end}})
 	{{else}}
 	reqForCall = nil
 	{{end}}
+	{{- if .HasCtx}}
+	if err := cp.CallContext(ctx, "{{.Path}}", reqForCall, &resp, {{durationToGoExpr .Timeout}}); err != nil {
+	{{- else}}
 	if err := cp.Call("{{.Path}}", reqForCall, &resp, {{durationToGoExpr .Timeout}}); err != nil {
+	{{- end}}
 		return resp, err
 	}
 	return resp, nil
 }
-{{end}}
+{{else if .IsAsync}}
+func (p *{{$.ImplName}}) {{.Name}}({{if .HasCtx}}ctx context.Context, {{end}}req {{.OriginalReq}}, cb func({{.RespType}}, error)) error {
+	cp := p.acquireClientProxy()
+	handler := func(c *arpc.Context, err error) {
+		var resp {{.RespType}}
+		if err == nil {
+			err = c.Bind(&resp)
+		}
+		cb(resp, err)
+	}
+	{{- if .HasCtx}}
+	return cp.CallAsyncContext(ctx, "{{.Path}}", &req, handler, {{durationToGoExpr .Timeout}})
+	{{- else}}
+	return cp.CallAsync("{{.Path}}", &req, handler, {{durationToGoExpr .Timeout}})
+	{{- end}}
+}
+{{else}}
+func (p *{{$.ImplName}}) {{.Name}}({{if .HasCtx}}ctx context.Context, {{end}}req {{.OriginalReq}}) error {
+	cp := p.acquireClientProxy()
+	{{- if .HasCtx}}
+	return cp.NotifyContext(ctx, "{{.Path}}", &req)
+	{{- else}}
+	return cp.Notify("{{.Path}}", &req, {{durationToGoExpr .Timeout}})
+	{{- end}}
+}
+{{end}}{{end}}
 
 func (p *{{.ImplName}}) acquireClientProxy() rclient.ArpcClientProxy {
 	return p.clientFactory.AcquireClient("{{.ServerName}}")
 }
+{{if .HasRetry}}
+func init() {
+{{- range .Methods}}{{if .Retry}}
+	rclient.RegisterRetryPolicy("{{$.ServerName}}", "{{.Path}}", rclient.RetryPolicy{
+		MaxAttempts:    {{.Retry.MaxAttempts}},
+		InitialBackoff: {{durationToGoExpr .Retry.InitialBackoff}},
+		MaxBackoff:     {{durationToGoExpr .Retry.MaxBackoff}},
+	})
+{{- end}}{{end}}
+}
+{{end}}`
+
+const serverTmpl = `// Code generated by rpcgen. DO NOT EDIT.
+
+package {{.PkgName}}
+
+import (
+	"context"
+	"github.com/lesismal/arpc"
+	"{{.PkgPrefix}}/pkg/server/srpc"
+{{- if .UsesRpccall}}
+	"{{.PkgPrefix}}/pkg/server/srpc/rpccall"
+{{- end}}
+)
+
+// {{.ServerIface}} server side of {{.Name}}, ctx carries the caller's deadline, request id and baggage
+type {{.ServerIface}} interface {
+{{- range .Methods}}
+	// {{.Name}} {{.Path}}{{if not .IsCall}} @{{.Kind}}{{end}}
+	{{- if .IsNotify}}
+	{{.Name}}(ctx context.Context, req {{.OriginalReq}}) error
+	{{- else}}
+	{{.Name}}(ctx context.Context{{if .HasParam}}, req {{.OriginalReq}}{{end}}) ({{.RespType}}, error)
+	{{- end}}
+{{end -}}
+}
+
+// BindArpc{{.ServerIface}} registers every path of {{.Name}} on srv, interceptors apply to all of them
+func BindArpc{{.ServerIface}}(srv *arpc.Server, impl {{.ServerIface}}, interceptors ...srpc.Interceptor) {
+{{- range .Methods}}
+	srv.Handler.Handle("{{.Path}}", srpc.Chain({{if .IsNotify}}srpc.OneWay(impl.{{.Name}}){{else if .HasParam}}srpc.Unary(impl.{{.Name}}){{else}}srpc.UnaryNoReq(impl.{{.Name}}){{end}}, interceptors...))
+{{- end}}
+}
 `
 
+// e.g. OneRpcProvider -> OneRpcServer
+func (i *Interface) ServerIface() string {
+	return strings.TrimSuffix(i.Name, "Provider") + "Server"
+}
+
+func (i *Interface) UsesRpccall() bool {
+	for _, m := range i.Methods {
+		if strings.Contains(m.OriginalReq, "rpccall.") || strings.Contains(m.RespType, "rpccall.") {
+			return true
+		}
+	}
+	return false
+}
+
+func (i *Interface) UsesCtx() bool {
+	for _, m := range i.Methods {
+		if m.HasCtx {
+			return true
+		}
+	}
+	return false
+}
+
+// NotifyContext不需要超时时间
+func (i *Interface) UsesTime() bool {
+	for _, m := range i.Methods {
+		if !m.IsNotify() || !m.HasCtx {
+			return true
+		}
+	}
+	return false
+}
+
+func (i *Interface) UsesAsync() bool {
+	for _, m := range i.Methods {
+		if m.IsAsync() {
+			return true
+		}
+	}
+	return false
+}
+
+func (i *Interface) HasRetry() bool {
+	for _, m := range i.Methods {
+		if m.Retry != nil {
+			return true
+		}
+	}
+	return false
+}
+
 func (i *Interface) ImplName() string {
 	name := i.Name
 	if len(name) == 0 {
@@ -319,7 +827,12 @@ func (i *Interface) ImplName() string {
 	return strings.ToLower(string(name[0])) + name[1:]
 }
 
-func generate(i *Interface) {
+type output struct {
+	FileName string
+	Src      []byte
+}
+
+func render(i *Interface) output {
 	data := struct {
 		*Interface
 		ImplName string
@@ -333,17 +846,46 @@ func generate(i *Interface) {
 	}
 	t := template.Must(template.New("rpc").Funcs(funcMap).Parse(tmpl))
 
-	outputFile := toSnakeCase(i.Name) + "_gen.go"
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, data); err != nil {
+		log.Fatalf("execute template: %v", err)
+	}
 
-	f, err := os.Create(outputFile)
-	if err != nil {
-		log.Fatalf("create %s: %v", outputFile, err)
+	return output{FileName: toSnakeCase(i.Name) + "_gen.go", Src: buf.Bytes()}
+}
+
+func renderServer(i *Interface) output {
+	data := struct {
+		*Interface
+		ServerIface string
+	}{
+		Interface:   i,
+		ServerIface: i.ServerIface(),
 	}
-	defer f.Close()
 
-	if err := t.Execute(f, data); err != nil {
-		log.Fatalf("execute template: %v", err)
+	t := template.Must(template.New("rpc_server").Parse(serverTmpl))
+
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, data); err != nil {
+		log.Fatalf("execute server template: %v", err)
 	}
 
-	fmt.Printf("Generated %s\n", outputFile)
+	src, err := format.Source(buf.Bytes())
+	if err != nil {
+		log.Fatalf("format server source: %v", err)
+	}
+
+	return output{FileName: toSnakeCase(i.Name) + "_server_gen.go", Src: src}
+}
+
+// 与磁盘上的文件比较, 返回不一致或不存在的文件名
+func staleOutputs(dir string, outputs []output) []string {
+	var stale []string
+	for _, out := range outputs {
+		existing, err := os.ReadFile(filepath.Join(dir, out.FileName))
+		if err != nil || !bytes.Equal(existing, out.Src) {
+			stale = append(stale, out.FileName)
+		}
+	}
+	return stale
 }
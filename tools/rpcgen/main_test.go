@@ -0,0 +1,171 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "rewrite golden files under testdata")
+
+// 将testdata下的包复制到临时目录, 生成的文件不写入testdata
+func copyFixture(t *testing.T, name string) string {
+	t.Helper()
+
+	src := filepath.Join("testdata", name)
+	entries, err := os.ReadDir(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), ".go") {
+			continue
+		}
+
+		data, err := os.ReadFile(filepath.Join(src, e.Name()))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err = os.WriteFile(filepath.Join(dir, e.Name()), data, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return dir
+}
+
+func runGen(t *testing.T, dir string, args ...string) (int, string) {
+	t.Helper()
+
+	var stdout, stderr bytes.Buffer
+	code := run(dir, args, &stdout, &stderr)
+	return code, stderr.String()
+}
+
+// 生成结果与testdata/<fixture>/golden一致, go test -update重新生成
+func TestGenerateGolden(t *testing.T) {
+	dir := copyFixture(t, "provider")
+
+	if code, stderr := runGen(t, dir, "-manifest"); code != 0 {
+		t.Fatalf("generate failed, code:%d, stderr:%s", code, stderr)
+	}
+
+	generated, err := filepath.Glob(filepath.Join(dir, "*"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var names []string
+	for _, path := range generated {
+		if name := filepath.Base(path); name != "provider.go" {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	goldenDir := filepath.Join("testdata", "provider", "golden")
+	if *update {
+		_ = os.RemoveAll(goldenDir)
+		if err = os.MkdirAll(goldenDir, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, name := range names {
+		got, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		golden := filepath.Join(goldenDir, name+".golden")
+		if *update {
+			if err = os.WriteFile(golden, got, 0644); err != nil {
+				t.Fatal(err)
+			}
+			continue
+		}
+
+		want, err := os.ReadFile(golden)
+		if err != nil {
+			t.Fatalf("unexpected output %s: %v", name, err)
+		}
+
+		if !bytes.Equal(got, want) {
+			t.Errorf("%s differs from %s, run go test -update if intended:\n%s", name, golden, got)
+		}
+	}
+
+	goldens, err := filepath.Glob(filepath.Join(goldenDir, "*.golden"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(goldens) != len(names) {
+		t.Fatalf("expect %d outputs, got:%v", len(goldens), names)
+	}
+}
+
+func TestCheckStale(t *testing.T) {
+	dir := copyFixture(t, "provider")
+
+	if code, stderr := runGen(t, dir, "-check"); code == 0 {
+		t.Fatal("expect -check fail before generate")
+	} else if !strings.Contains(stderr, "user_rpc_provider_gen.go is stale") {
+		t.Fatalf("unexpected stderr:%s", stderr)
+	}
+
+	if code, stderr := runGen(t, dir); code != 0 {
+		t.Fatalf("generate failed, code:%d, stderr:%s", code, stderr)
+	}
+
+	if code, stderr := runGen(t, dir, "-check"); code != 0 {
+		t.Fatalf("expect -check pass after generate, stderr:%s", stderr)
+	}
+
+	// 修改接口后已生成的文件过期
+	src := filepath.Join(dir, "provider.go")
+	data, err := os.ReadFile(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data = bytes.Replace(data, []byte("@path /v1/user_ctx"), []byte("@path /v2/user_ctx"), 1)
+	if err = os.WriteFile(src, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	code, stderr := runGen(t, dir, "-check")
+	if code == 0 {
+		t.Fatal("expect -check fail when output is stale")
+	}
+
+	if !strings.Contains(stderr, "user_rpc_provider_gen.go is stale") || !strings.Contains(stderr, "user_rpc_provider_server_gen.go is stale") {
+		t.Fatalf("unexpected stderr:%s", stderr)
+	}
+}
+
+func TestDuplicatePath(t *testing.T) {
+	dir := copyFixture(t, "duplicate")
+
+	code, stderr := runGen(t, dir)
+	if code == 0 {
+		t.Fatal("expect duplicate @path rejected")
+	}
+
+	want := filepath.Join(dir, "duplicate.go") + ":17:2: duplicate @path /v1/same for server dup_service, already declared by FirstRpcProvider.First at " +
+		filepath.Join(dir, "duplicate.go") + ":11:2"
+	if !strings.Contains(stderr, want) {
+		t.Fatalf("unexpected diagnostics:%s", stderr)
+	}
+
+	if matches, _ := filepath.Glob(filepath.Join(dir, "*_gen.go")); len(matches) != 0 {
+		t.Fatalf("expect nothing generated, got:%v", matches)
+	}
+}
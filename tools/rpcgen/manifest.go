@@ -0,0 +1,308 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"go/ast"
+	"go/importer"
+	"go/token"
+	"go/types"
+	"log"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// Manifest 一个服务通过arpc暴露的全部接口, 可用于版本间对比和调试工具
+type Manifest struct {
+	Server      string             `json:"server"`
+	Package     string             `json:"package"`
+	Methods     []ManifestMethod   `json:"methods"`
+	Definitions map[string]*Schema `json:"definitions,omitempty"`
+}
+
+type ManifestMethod struct {
+	Interface string         `json:"interface"`
+	Name      string         `json:"name"`
+	Path      string         `json:"path"`
+	Kind      string         `json:"kind"`
+	TimeoutMs int64          `json:"timeout_ms"`
+	Retry     *ManifestRetry `json:"retry,omitempty"`
+	Request   *Schema        `json:"request,omitempty"`
+	Response  *Schema        `json:"response,omitempty"`
+}
+
+type ManifestRetry struct {
+	MaxAttempts      int   `json:"max_attempts"`
+	InitialBackoffMs int64 `json:"initial_backoff_ms,omitempty"`
+	MaxBackoffMs     int64 `json:"max_backoff_ms,omitempty"`
+}
+
+// Schema JSON schema的子集
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Nullable             bool               `json:"nullable,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+}
+
+// 对包做类型检查, 以获取跨包类型(如rpccall.RpcReqWrapper[T])的结构
+// rpcgen生成的文件可能已过期, 不参与检查
+func typeCheck(fset *token.FileSet, pkg *ast.Package) (*types.Package, []string) {
+	var files []*ast.File
+	for _, f := range pkg.Files {
+		if ast.IsGenerated(f) && strings.Contains(f.Comments[0].Text(), "rpcgen") {
+			continue
+		}
+		files = append(files, f)
+	}
+
+	var typeErrs []string
+	conf := types.Config{
+		Importer: importer.ForCompiler(fset, "source", nil),
+		Error: func(err error) {
+			typeErrs = append(typeErrs, err.Error())
+		},
+	}
+
+	checked, _ := conf.Check(pkg.Name, fset, files, nil)
+	return checked, typeErrs
+}
+
+// 按服务生成manifest, 包含该服务在包内的全部接口
+func renderManifests(checked *types.Package, ifaces []*Interface, servers []string, diag *diagnostics) []output {
+	var outputs []output
+	for _, server := range servers {
+		sb := &schemaBuilder{
+			defs: make(map[string]*Schema),
+			qualifier: func(p *types.Package) string {
+				return p.Name()
+			},
+		}
+
+		mf := Manifest{
+			Server:  server,
+			Package: checked.Name(),
+		}
+
+		for _, iface := range ifaces {
+			if iface.ServerName != server {
+				continue
+			}
+
+			obj := checked.Scope().Lookup(iface.Name)
+			if obj == nil {
+				diag.errorf(iface.Pos, "%s: type not resolved", iface.Name)
+				continue
+			}
+			it, ok := obj.Type().Underlying().(*types.Interface)
+			if !ok {
+				diag.errorf(iface.Pos, "%s: not an interface", iface.Name)
+				continue
+			}
+
+			funcs := make(map[string]*types.Func, it.NumMethods())
+			for k := 0; k < it.NumMethods(); k++ {
+				funcs[it.Method(k).Name()] = it.Method(k)
+			}
+
+			for _, m := range iface.Methods {
+				fn, ok := funcs[m.Name]
+				if !ok {
+					diag.errorf(m.Pos, "%s.%s: method not resolved", iface.Name, m.Name)
+					continue
+				}
+
+				mf.Methods = append(mf.Methods, sb.method(iface.Name, m, fn.Type().(*types.Signature)))
+			}
+		}
+
+		if len(sb.defs) > 0 {
+			mf.Definitions = sb.defs
+		}
+
+		src, err := json.MarshalIndent(mf, "", "  ")
+		if err != nil {
+			log.Fatalf("marshal manifest of %s: %v", server, err)
+		}
+
+		outputs = append(outputs, output{FileName: server + "_rpc_manifest.json", Src: append(src, '\n')})
+	}
+
+	return outputs
+}
+
+type schemaBuilder struct {
+	defs      map[string]*Schema
+	qualifier types.Qualifier
+}
+
+func (sb *schemaBuilder) method(ifaceName string, m Method, sig *types.Signature) ManifestMethod {
+	mm := ManifestMethod{
+		Interface: ifaceName,
+		Name:      m.Name,
+		Path:      m.Path,
+		Kind:      m.Kind,
+		TimeoutMs: m.Timeout.Milliseconds(),
+	}
+
+	if m.Retry != nil {
+		mm.Retry = &ManifestRetry{
+			MaxAttempts:      m.Retry.MaxAttempts,
+			InitialBackoffMs: m.Retry.InitialBackoff.Milliseconds(),
+			MaxBackoffMs:     m.Retry.MaxBackoff.Milliseconds(),
+		}
+	}
+
+	params := sig.Params()
+	idx := 0
+	if m.HasCtx {
+		idx++
+	}
+	if m.HasParam && idx < params.Len() {
+		mm.Request = sb.of(params.At(idx).Type())
+	}
+
+	switch {
+	case m.IsCall() && sig.Results().Len() > 0:
+		mm.Response = sb.of(sig.Results().At(0).Type())
+	case m.IsAsync() && params.Len() > 0:
+		// 回调的第一个参数
+		if cb, ok := params.At(params.Len() - 1).Type().Underlying().(*types.Signature); ok && cb.Params().Len() > 0 {
+			mm.Response = sb.of(cb.Params().At(0).Type())
+		}
+	}
+
+	return mm
+}
+
+func (sb *schemaBuilder) of(t types.Type) *Schema {
+	switch tt := t.(type) {
+	case *types.Named:
+		if obj := tt.Obj(); obj.Pkg() != nil && obj.Pkg().Path() == "time" {
+			switch obj.Name() {
+			case "Time":
+				return &Schema{Type: "string", Format: "date-time"}
+			case "Duration":
+				return &Schema{Type: "integer", Format: "int64"}
+			}
+		}
+
+		st, ok := tt.Underlying().(*types.Struct)
+		if !ok {
+			return sb.of(tt.Underlying())
+		}
+
+		name := types.TypeString(tt, sb.qualifier)
+		if _, ok := sb.defs[name]; !ok {
+			// 先占位, 处理递归类型
+			def := &Schema{}
+			sb.defs[name] = def
+			*def = *sb.structSchema(st)
+		}
+		return &Schema{Ref: "#/definitions/" + name}
+	case *types.Alias:
+		return sb.of(types.Unalias(tt))
+	case *types.Pointer:
+		s := *sb.of(tt.Elem())
+		s.Nullable = true
+		return &s
+	case *types.Basic:
+		return basicSchema(tt)
+	case *types.Slice:
+		if b, ok := tt.Elem().Underlying().(*types.Basic); ok && b.Kind() == types.Byte {
+			return &Schema{Type: "string", Format: "byte"}
+		}
+		return &Schema{Type: "array", Items: sb.of(tt.Elem())}
+	case *types.Array:
+		return &Schema{Type: "array", Items: sb.of(tt.Elem())}
+	case *types.Map:
+		return &Schema{Type: "object", AdditionalProperties: sb.of(tt.Elem())}
+	case *types.Struct:
+		return sb.structSchema(tt)
+	default:
+		// interface, any等
+		return &Schema{}
+	}
+}
+
+// 按encoding/json的规则处理字段, 未导出和json:"-"的字段忽略, 匿名结构体字段展开
+func (sb *schemaBuilder) structSchema(st *types.Struct) *Schema {
+	s := &Schema{Type: "object", Properties: make(map[string]*Schema)}
+
+	for k := 0; k < st.NumFields(); k++ {
+		field := st.Field(k)
+		if !field.Exported() && !field.Embedded() {
+			continue
+		}
+
+		tag := reflect.StructTag(st.Tag(k)).Get("json")
+		if tag == "-" {
+			continue
+		}
+
+		name, opts, _ := strings.Cut(tag, ",")
+		omitempty := strings.Contains(","+opts+",", ",omitempty,")
+
+		if field.Embedded() && name == "" {
+			ft := field.Type()
+			if p, ok := ft.(*types.Pointer); ok {
+				ft = p.Elem()
+			}
+			if est, ok := ft.Underlying().(*types.Struct); ok {
+				embedded := sb.structSchema(est)
+				for pn, ps := range embedded.Properties {
+					if _, exists := s.Properties[pn]; !exists {
+						s.Properties[pn] = ps
+					}
+				}
+				s.Required = append(s.Required, embedded.Required...)
+				continue
+			}
+			if !field.Exported() {
+				continue
+			}
+		}
+
+		if name == "" {
+			name = field.Name()
+		}
+
+		s.Properties[name] = sb.of(field.Type())
+		if !omitempty {
+			s.Required = append(s.Required, name)
+		}
+	}
+
+	sort.Strings(s.Required)
+
+	return s
+}
+
+func basicSchema(b *types.Basic) *Schema {
+	info := b.Info()
+	switch {
+	case info&types.IsBoolean != 0:
+		return &Schema{Type: "boolean"}
+	case info&types.IsString != 0:
+		return &Schema{Type: "string"}
+	case info&types.IsInteger != 0:
+		switch b.Kind() {
+		case types.Int8, types.Int16, types.Int32, types.Uint8, types.Uint16:
+			return &Schema{Type: "integer", Format: "int32"}
+		default:
+			return &Schema{Type: "integer", Format: "int64"}
+		}
+	case info&types.IsFloat != 0:
+		if b.Kind() == types.Float32 {
+			return &Schema{Type: "number", Format: "float"}
+		}
+		return &Schema{Type: "number", Format: "double"}
+	default:
+		return &Schema{Type: fmt.Sprintf("unsupported(%s)", b.Name())}
+	}
+}
@@ -0,0 +1,19 @@
+package duplicate
+
+import "github.com/sweemingdow/gmicro_pkg/pkg/server/srpc/rpccall"
+
+type Req struct {
+	Name string `json:"name"`
+}
+
+// @rpc_server dup_service
+type FirstRpcProvider interface {
+	// @path /v1/same
+	First(req rpccall.RpcReqWrapper[Req]) (rpccall.RpcRespSimple, error)
+}
+
+// @rpc_server dup_service
+type SecondRpcProvider interface {
+	// @path /v1/same
+	Second(req rpccall.RpcReqWrapper[Req]) (rpccall.RpcRespSimple, error)
+}
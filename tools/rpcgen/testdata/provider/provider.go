@@ -0,0 +1,48 @@
+package provider
+
+import (
+	"context"
+	"github.com/sweemingdow/gmicro_pkg/pkg/server/srpc/rpccall"
+	"time"
+)
+
+type Base struct {
+	Id int64 `json:"id"`
+}
+
+type UserReq struct {
+	Base
+	Name     string            `json:"name"`
+	Tags     []string          `json:"tags,omitempty"`
+	Extra    map[string]string `json:"extra,omitempty"`
+	Avatar   []byte            `json:"avatar,omitempty"`
+	Parent   *UserReq          `json:"parent,omitempty"`
+	internal string
+	Ignored  string `json:"-"`
+}
+
+type UserResp struct {
+	Uid       string    `json:"uid"`
+	Score     float32   `json:"score"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// @rpc_server user_service
+type UserRpcProvider interface {
+	// @path /v1/user
+	// @timeout 3s
+	// @retry 3 50ms 500ms
+	User(req rpccall.RpcReqWrapper[UserReq]) (rpccall.RpcRespWrapper[UserResp], error)
+
+	// @path /v1/user_ctx
+	UserCtx(ctx context.Context, req rpccall.RpcReqWrapper[UserReq]) (rpccall.RpcRespSimple, error)
+
+	// @path /v1/user_async
+	// @timeout 2s
+	// @async
+	UserAsync(ctx context.Context, req rpccall.RpcReqWrapper[UserReq], cb func(rpccall.RpcRespWrapper[UserResp], error)) error
+
+	// @path /v1/user_notify
+	// @notify
+	UserNotify(req rpccall.RpcReqWrapper[UserReq]) error
+}